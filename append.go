@@ -0,0 +1,164 @@
+package phpserialize
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AppendMarshal works the same way as Marshal, but appends the encoded value
+// to dst and returns the extended slice. A buffer can be reused for each value
+// by passing it again with a length of zero:
+//
+//	buf, err = phpserialize.AppendMarshal(buf[:0], v, nil)
+//
+// If there is an error the returned slice is dst, without any part of the
+// value.
+func AppendMarshal(dst []byte, input interface{}, options *MarshalOptions) ([]byte, error) {
+	result, err := appendMarshal(dst, input, options)
+	if err != nil {
+		return dst, err
+	}
+
+	return result, nil
+}
+
+// AppendBool appends a PHP serialized bool value to dst. See MarshalBool.
+func AppendBool(dst []byte, value bool) []byte {
+	if value {
+		return append(dst, "b:1;"...)
+	}
+
+	return append(dst, "b:0;"...)
+}
+
+// AppendInt appends a PHP serialized integer value to dst. See MarshalInt.
+func AppendInt(dst []byte, value int64) []byte {
+	dst = append(dst, "i:"...)
+	dst = strconv.AppendInt(dst, value, 10)
+
+	return append(dst, ';')
+}
+
+// AppendUint appends a PHP serialized integer value to dst. See MarshalUint.
+func AppendUint(dst []byte, value uint64) []byte {
+	dst = append(dst, "i:"...)
+	dst = strconv.AppendUint(dst, value, 10)
+
+	return append(dst, ';')
+}
+
+// AppendFloat appends a PHP serialized floating-point value to dst. See
+// MarshalFloat.
+func AppendFloat(dst []byte, value float64, bitSize int) []byte {
+	dst = append(dst, "d:"...)
+
+	// PHP writes infinity and NaN in its own way, which strconv can not.
+	switch {
+	case math.IsInf(value, 1):
+		dst = append(dst, "INF"...)
+	case math.IsInf(value, -1):
+		dst = append(dst, "-INF"...)
+	case math.IsNaN(value):
+		dst = append(dst, "NAN"...)
+	default:
+		dst = strconv.AppendFloat(dst, value, 'f', -1, bitSize)
+	}
+
+	return append(dst, ';')
+}
+
+// AppendString appends a PHP serialized string value to dst. See
+// MarshalString.
+func AppendString(dst []byte, value string) []byte {
+	// Single quotes are escaped, and the length includes the escapes.
+	quotes := strings.Count(value, "'")
+
+	dst = append(dst, "s:"...)
+	dst = strconv.AppendInt(dst, int64(len(value)+quotes), 10)
+	dst = append(dst, ':', '"')
+
+	if quotes == 0 {
+		dst = append(dst, value...)
+	} else {
+		for i := 0; i < len(value); i++ {
+			if value[i] == '\'' {
+				dst = append(dst, '\\')
+			}
+
+			dst = append(dst, value[i])
+		}
+	}
+
+	return append(dst, '"', ';')
+}
+
+// AppendBytes appends a PHP serialized string value that contains binary data
+// to dst. See MarshalBytes.
+func AppendBytes(dst []byte, value []byte) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, "s:"...)
+	dst = strconv.AppendInt(dst, int64(len(value)), 10)
+	dst = append(dst, ':', '"')
+
+	for _, c := range value {
+		dst = append(dst, '\\', 'x', hex[c>>4], hex[c&0xf])
+	}
+
+	return append(dst, '"', ';')
+}
+
+// AppendNil appends a PHP serialized null value to dst. See MarshalNil.
+func AppendNil(dst []byte) []byte {
+	return append(dst, "N;"...)
+}
+
+// appendArrayHeader appends the start of an array with length elements, such
+// as a:2:{
+func appendArrayHeader(dst []byte, length int) []byte {
+	dst = append(dst, "a:"...)
+	dst = strconv.AppendInt(dst, int64(length), 10)
+
+	return append(dst, ':', '{')
+}
+
+// appendObjectHeader appends the start of an object with length properties,
+// such as O:3:"Foo":2:{
+func appendObjectHeader(dst []byte, className string, length int) []byte {
+	dst = append(dst, "O:"...)
+	dst = strconv.AppendInt(dst, int64(len(className)), 10)
+	dst = append(dst, ':', '"')
+	dst = append(dst, className...)
+	dst = append(dst, '"', ':')
+	dst = strconv.AppendInt(dst, int64(length), 10)
+
+	return append(dst, ':', '{')
+}
+
+// maxScratchSize is the largest buffer that is put back into scratchPool, so
+// that one very large value does not keep its memory forever.
+const maxScratchSize = 64 << 10
+
+// scratchPool holds the buffers that objects are encoded into before the
+// number of properties that they have is known.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+func getScratch() *[]byte {
+	return scratchPool.Get().(*[]byte)
+}
+
+func putScratch(b *[]byte) {
+	if cap(*b) > maxScratchSize {
+		return
+	}
+
+	*b = (*b)[:0]
+	scratchPool.Put(b)
+}
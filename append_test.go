@@ -0,0 +1,92 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+func TestAppendMarshal(t *testing.T) {
+	for testName, test := range marshalTests {
+		t.Run(testName, func(t *testing.T) {
+			prefix := []byte("prefix")
+
+			result, err := phpserialize.AppendMarshal(prefix, test.input, test.options)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != "prefix"+string(test.output) {
+				t.Errorf("Expected 'prefix%s', got '%s'", test.output, result)
+			}
+		})
+	}
+}
+
+func TestAppendMarshalError(t *testing.T) {
+	dst := []byte("abc")
+
+	result, err := phpserialize.AppendMarshal(dst, []interface{}{1, uintptr(13)}, nil)
+	expectErrorToEqual(t, err, errors.New("can not encode: uintptr"))
+
+	if string(result) != "abc" {
+		t.Errorf("Expected 'abc', got '%s'", result)
+	}
+}
+
+func TestAppendScalars(t *testing.T) {
+	var buf []byte
+	buf = phpserialize.AppendBool(buf, true)
+	buf = phpserialize.AppendInt(buf, -5)
+	buf = phpserialize.AppendUint(buf, 18446744073709551615)
+	buf = phpserialize.AppendFloat(buf, 1.5, 64)
+	buf = phpserialize.AppendString(buf, "it's")
+	buf = phpserialize.AppendBytes(buf, []byte{0, 255})
+	buf = phpserialize.AppendNil(buf)
+
+	expected := `b:1;i:-5;i:18446744073709551615;d:1.5;s:5:"it\'s";s:2:"\x00\xff";N;`
+	if string(buf) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buf)
+	}
+}
+
+func TestAppendFloatSpecialValues(t *testing.T) {
+	var buf []byte
+	buf = phpserialize.AppendFloat(buf, math.Inf(1), 64)
+	buf = phpserialize.AppendFloat(buf, math.Inf(-1), 64)
+	buf = phpserialize.AppendFloat(buf, math.NaN(), 32)
+
+	expected := `d:INF;d:-INF;d:NAN;`
+	if string(buf) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buf)
+	}
+
+	// They can be decoded again.
+	var f float64
+	expectErrorToNotHaveOccurred(t, phpserialize.Unmarshal([]byte("d:-INF;"), &f))
+	if !math.IsInf(f, -1) {
+		t.Errorf("Expected -Inf, got %v", f)
+	}
+}
+
+func TestAppendMarshalReusesBuffer(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+
+	allocations := testing.AllocsPerRun(100, func() {
+		var err error
+		buf, err = phpserialize.AppendMarshal(buf[:0], map[string]int{"a": 1}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	marshalAllocations := testing.AllocsPerRun(100, func() {
+		if _, err := phpserialize.Marshal(map[string]int{"a": 1}, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if allocations >= marshalAllocations {
+		t.Errorf("Expected fewer than %v allocations, got %v", marshalAllocations, allocations)
+	}
+}
@@ -0,0 +1,135 @@
+package phpserialize_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type benchmarkUser struct {
+	ID        int64
+	Name      string
+	Email     string `php:"email_address"`
+	Active    bool
+	Score     float64
+	Tags      []string
+	Ignored   string  `php:"-"`
+	Nickname  *string `php:",omitempty"`
+	CreatedBy benchmarkAudit
+}
+
+type benchmarkAudit struct {
+	UserID int64
+	Source string
+}
+
+var benchmarkUserValue = benchmarkUser{
+	ID:        12345,
+	Name:      "Björk",
+	Email:     "bjork@example.com",
+	Active:    true,
+	Score:     98.6,
+	Tags:      []string{"admin", "beta"},
+	CreatedBy: benchmarkAudit{1, "import"},
+}
+
+func BenchmarkMarshalStruct(b *testing.B) {
+	options := phpserialize.DefaultMarshalOptions()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := phpserialize.Marshal(benchmarkUserValue, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalStruct(b *testing.B) {
+	data, err := phpserialize.Marshal(benchmarkUserValue, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var result benchmarkUser
+		if err := phpserialize.Unmarshal(data, &result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalStructParallel(b *testing.B) {
+	options := phpserialize.DefaultMarshalOptions()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := phpserialize.Marshal(benchmarkUserValue, options); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUnmarshalNestedAssociative(b *testing.B) {
+	data := []byte(nestedMixedArray(20))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := phpserialize.UnmarshalAssociativeArray(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalWideAssociative(b *testing.B) {
+	var s strings.Builder
+	s.WriteString("a:100:{")
+	for i := 0; i < 100; i++ {
+		// Each nested array is a list until its last key.
+		s.WriteString(`i:` + strconv.Itoa(i) + `;a:3:{i:0;i:1;i:1;i:2;s:1:"x";a:2:{i:0;i:1;i:2;i:2;}}`)
+	}
+	s.WriteString("}")
+	data := []byte(s.String())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := phpserialize.UnmarshalAssociativeArray(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalStructZeroCopy(b *testing.B) {
+	data, err := phpserialize.Marshal(benchmarkUserValue, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.ZeroCopy = true
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var result benchmarkUser
+		if err := phpserialize.UnmarshalWithOptions(data, &result, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendMarshalStruct(b *testing.B) {
+	options := phpserialize.DefaultMarshalOptions()
+	buf := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = phpserialize.AppendMarshal(buf[:0], benchmarkUserValue, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,834 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+const (
+	phpserializePath = "github.com/jamteacoffee/phpserialize"
+	orderedMapPath   = "github.com/elliotchance/orderedmap/v3"
+)
+
+// generator holds the declarations of the package that methods are generated
+// for, and the code that has been generated so far.
+type generator struct {
+	pkgName string
+	structs map[string]*ast.TypeSpec
+
+	// methods holds the names of the methods declared for each type.
+	methods map[string]map[string]bool
+
+	// generated is the set of types that methods are being generated for.
+	// Fields of these types call the generated methods directly.
+	generated map[string]bool
+
+	// orderedMaps is the name that the orderedmap package is imported as
+	// in the file that declares each struct.
+	orderedMaps map[string]string
+
+	imports  map[string]bool
+	warnings []string
+	buf      bytes.Buffer
+}
+
+// parsePackage reads the declarations of the package in dir. Test files and
+// the file at outputPath, which may be an older version of the generated code,
+// are ignored.
+func parsePackage(dir, outputPath string) (*generator, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	absOutput, err := filepath.Abs(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		structs:     map[string]*ast.TypeSpec{},
+		methods:     map[string]map[string]bool{},
+		orderedMaps: map[string]string{},
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		if abs, err := filepath.Abs(path); err == nil && abs == absOutput {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		if g.pkgName == "" {
+			g.pkgName = file.Name.Name
+		} else if g.pkgName != file.Name.Name {
+			return nil, fmt.Errorf("found packages %s and %s in %s", g.pkgName, file.Name.Name, dir)
+		}
+
+		g.addDecls(file)
+	}
+
+	if g.pkgName == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	return g, nil
+}
+
+func (g *generator) addDecls(file *ast.File) {
+	orderedMapName := ""
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == orderedMapPath {
+			orderedMapName = "orderedmap"
+			if spec.Name != nil {
+				orderedMapName = spec.Name.Name
+			}
+		}
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := ts.Type.(*ast.StructType); ok {
+					g.structs[ts.Name.Name] = ts
+					g.orderedMaps[ts.Name.Name] = orderedMapName
+				}
+			}
+
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) != 1 {
+				continue
+			}
+
+			name := receiverName(d.Recv.List[0].Type)
+			if g.methods[name] == nil {
+				g.methods[name] = map[string]bool{}
+			}
+
+			g.methods[name][d.Name.Name] = true
+		}
+	}
+}
+
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	}
+
+	return ""
+}
+
+// generate returns the formatted source of a file with the methods for each of
+// the types.
+func (g *generator) generate(typeNames []string) ([]byte, error) {
+	g.generated = map[string]bool{}
+	for _, name := range typeNames {
+		ts, ok := g.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s is not a struct in package %s", name, g.pkgName)
+		}
+
+		if ts.TypeParams != nil {
+			return nil, fmt.Errorf("type %s has type parameters", name)
+		}
+
+		// These methods would be used instead of the generated ones, or
+		// the other way around.
+		for _, method := range []string{"MarshalText", "UnmarshalText", "MarshalPHP", "UnmarshalPHP"} {
+			if g.methods[name][method] {
+				return nil, fmt.Errorf("type %s already has a %s method", name, method)
+			}
+		}
+
+		g.generated[name] = true
+	}
+
+	g.imports = map[string]bool{phpserializePath: true}
+	g.buf.Reset()
+
+	for _, name := range typeNames {
+		s := g.analyze(name)
+		if s.reflect != "" {
+			g.warnings = append(g.warnings, fmt.Sprintf("%s uses reflection because %s", name, s.reflect))
+		} else if s.reflectDecode != "" {
+			g.warnings = append(g.warnings, fmt.Sprintf("%s is decoded with reflection because %s", name, s.reflectDecode))
+		}
+
+		g.writeMarshal(s)
+		g.writeUnmarshal(s)
+	}
+
+	// The standard library is imported first, in its own group.
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(path, ".") {
+			other = append(other, strconv.Quote(path))
+		} else {
+			std = append(std, strconv.Quote(path))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by phpserialize-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n%s\n\n%s\n)\n", g.pkgName, strings.Join(std, "\n"), strings.Join(other, "\n"))
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("can not format generated code: %v", err)
+	}
+
+	return src, nil
+}
+
+type fieldKind int
+
+const (
+	kindOther fieldKind = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindBytes
+	kindStruct
+)
+
+var basicKinds = map[string]fieldKind{
+	"bool":    kindBool,
+	"int":     kindInt,
+	"int8":    kindInt,
+	"int16":   kindInt,
+	"int32":   kindInt,
+	"int64":   kindInt,
+	"rune":    kindInt,
+	"uint":    kindUint,
+	"uint8":   kindUint,
+	"uint16":  kindUint,
+	"uint32":  kindUint,
+	"uint64":  kindUint,
+	"byte":    kindUint,
+	"float32": kindFloat,
+	"float64": kindFloat,
+	"string":  kindString,
+}
+
+// structInfo is what is known about a struct that methods are generated for.
+type structInfo struct {
+	name   string
+	fields []*fieldInfo
+
+	// reflect is the reason that the struct has to be encoded and decoded
+	// with reflection. It is empty if it does not.
+	reflect string
+
+	// reflectDecode is the reason that the struct has to be decoded with
+	// reflection, even though it can be encoded without it.
+	reflectDecode string
+
+	hasAliases bool
+}
+
+type fieldInfo struct {
+	goName  string
+	name    string
+	aliases []string
+
+	kind fieldKind
+
+	// typeName is the type of a basic or generated struct field, or the
+	// type that it points to.
+	typeName string
+	ptr      bool
+
+	// shape is "slice", "map", "interface" or "pointer" for the fields of
+	// other types that have one of those shapes.
+	shape string
+
+	// keep are the conditions that must all be true for the field to be
+	// included in the output.
+	keep []string
+
+	asString bool
+
+	// orderedMap is true for a *orderedmap.OrderedMap field, which is
+	// empty if it has no entries.
+	orderedMap bool
+}
+
+func (f *fieldInfo) target() string {
+	return "x." + f.goName
+}
+
+// value is the expression for the value of the field, after it has been
+// checked for nil if it is a pointer.
+func (f *fieldInfo) value() string {
+	if f.ptr {
+		return "*" + f.target()
+	}
+
+	return f.target()
+}
+
+func (g *generator) analyze(name string) *structInfo {
+	s := &structInfo{name: name}
+	st := g.structs[name].Type.(*ast.StructType)
+
+	for _, field := range st.Fields.List {
+		tag := ""
+		if field.Tag != nil {
+			unquoted, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(unquoted).Get("php")
+		}
+
+		tagName, options, _ := strings.Cut(tag, ",")
+
+		if len(field.Names) == 0 {
+			if tagName != "-" {
+				s.reflect = fmt.Sprintf("it embeds %s", exprString(field.Type))
+			}
+
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() || tagName == "-" {
+				continue
+			}
+
+			f := &fieldInfo{goName: ident.Name, name: tagName}
+			if f.name == "" {
+				f.name = phpserialize.LowerFirstNamer(ident.Name)
+			}
+
+			g.classify(f, field.Type)
+			f.orderedMap = isOrderedMap(field.Type, g.orderedMaps[name])
+
+			if reason := g.applyOptions(s, f, options); reason != "" {
+				s.reflect = reason
+			}
+
+			s.fields = append(s.fields, f)
+		}
+	}
+
+	if s.reflect == "" {
+		s.reflect = duplicateNames(s.fields)
+	}
+
+	if s.reflectDecode == "" {
+		s.reflectDecode = duplicateAliases(s.fields)
+	}
+
+	if g.methods[name]["ResolvePHPClass"] {
+		s.reflectDecode = "it implements phpserialize.ClassResolver"
+	}
+
+	return s
+}
+
+// classify works out the kind of a field from its type.
+func (g *generator) classify(f *fieldInfo, expr ast.Expr) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		f.ptr = true
+		f.shape = "pointer"
+		expr = star.X
+
+		// Only pointers to basic and generated types are handled
+		// directly.
+		if _, ok := expr.(*ast.Ident); !ok {
+			f.ptr = false
+			return
+		}
+	}
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if kind, ok := basicKinds[t.Name]; ok {
+			f.kind = kind
+			f.typeName = t.Name
+		} else if g.generated[t.Name] {
+			f.kind = kindStruct
+			f.typeName = t.Name
+		} else if t.Name == "any" {
+			f.shape = "interface"
+		} else if f.ptr {
+			// A pointer to some other type.
+			f.ptr = false
+		}
+
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return
+		}
+
+		if elem, ok := t.Elt.(*ast.Ident); ok && (elem.Name == "byte" || elem.Name == "uint8") {
+			f.kind = kindBytes
+			f.typeName = "[]byte"
+			return
+		}
+
+		f.shape = "slice"
+
+	case *ast.MapType:
+		f.shape = "map"
+
+	case *ast.InterfaceType:
+		f.shape = "interface"
+	}
+}
+
+// isOrderedMap reports whether expr is a pointer to an OrderedMap from the
+// orderedmap package, which is imported as name.
+func isOrderedMap(expr ast.Expr, name string) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok || name == "" {
+		return false
+	}
+
+	var generic ast.Expr
+	switch t := star.X.(type) {
+	case *ast.IndexExpr:
+		generic = t.X
+	case *ast.IndexListExpr:
+		generic = t.X
+	}
+
+	sel, ok := generic.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "OrderedMap" {
+		return false
+	}
+
+	pkg, ok := sel.X.(*ast.Ident)
+
+	return ok && pkg.Name == name
+}
+
+// applyOptions handles the options in the tag of a field. It returns the reason
+// that the struct must use reflection if one of them can not be handled.
+func (g *generator) applyOptions(s *structInfo, f *fieldInfo, options string) string {
+	if options == "" {
+		return ""
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		var keep string
+		var ok bool
+
+		switch {
+		case option == "omitnilptr":
+			if f.shape == "pointer" {
+				keep, ok = f.target()+" != nil", true
+			} else {
+				ok = true
+			}
+
+		case option == "omitempty":
+			keep, ok = g.emptyKeep(f)
+
+		case option == "omitzero":
+			keep, ok = g.zeroKeep(f)
+
+		case option == "string":
+			// The string option is decoded differently from every
+			// other field.
+			s.reflectDecode = fmt.Sprintf("field %s has the string option", f.goName)
+
+			switch f.kind {
+			case kindBool, kindInt, kindUint, kindFloat:
+				f.asString = true
+				ok = true
+			case kindString, kindBytes:
+				ok = true
+			}
+
+		case strings.HasPrefix(option, "alias="):
+			f.aliases = strings.Split(strings.TrimPrefix(option, "alias="), "|")
+			s.hasAliases = true
+			ok = true
+
+		case option == "":
+			ok = true
+		}
+
+		if !ok {
+			return fmt.Sprintf("field %s has the %s option", f.goName, option)
+		}
+
+		if keep != "" {
+			f.keep = append(f.keep, keep)
+		}
+	}
+
+	return ""
+}
+
+// emptyKeep returns the condition for a field with omitempty to be kept. The
+// condition is empty if the field is never omitted. The second return value is
+// false if the condition can not be worked out.
+func (g *generator) emptyKeep(f *fieldInfo) (string, bool) {
+	t := f.target()
+
+	if f.orderedMap {
+		return t + " != nil && " + t + ".Len() != 0", true
+	}
+
+	if f.shape == "pointer" || f.shape == "interface" {
+		return t + " != nil", true
+	}
+
+	if f.shape == "slice" || f.shape == "map" || f.kind == kindBytes {
+		return "len(" + t + ") != 0", true
+	}
+
+	switch f.kind {
+	case kindBool:
+		return t, true
+	case kindInt, kindUint, kindFloat:
+		return t + " != 0", true
+	case kindString:
+		return t + ` != ""`, true
+	case kindStruct:
+		// Structs are never empty.
+		return "", true
+	}
+
+	return "", false
+}
+
+// zeroKeep returns the condition for a field with omitzero to be kept, in the
+// same way as emptyKeep.
+func (g *generator) zeroKeep(f *fieldInfo) (string, bool) {
+	t := f.target()
+
+	if f.ptr && (f.kind != kindStruct || !g.methods[f.typeName]["IsZero"]) {
+		return t + " != nil", true
+	}
+
+	if f.shape == "slice" || f.shape == "map" || f.kind == kindBytes {
+		return t + " != nil", true
+	}
+
+	switch f.kind {
+	case kindBool:
+		return t, true
+	case kindInt, kindUint, kindFloat:
+		return t + " != 0", true
+	case kindString:
+		return t + ` != ""`, true
+	}
+
+	return "", false
+}
+
+func duplicateNames(fields []*fieldInfo) string {
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if seen[f.name] {
+			return fmt.Sprintf("more than one field is named %q", f.name)
+		}
+
+		seen[f.name] = true
+	}
+
+	return ""
+}
+
+// duplicateAliases returns a reason if a property name would be used by more
+// than one field, which can not be decoded with a switch.
+func duplicateAliases(fields []*fieldInfo) string {
+	seen := map[string]bool{}
+	for _, f := range fields {
+		for _, name := range append([]string{f.name}, f.aliases...) {
+			if seen[name] {
+				return fmt.Sprintf("more than one field can be decoded from %q", name)
+			}
+
+			seen[name] = true
+		}
+	}
+
+	return ""
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), expr); err != nil {
+		return "?"
+	}
+
+	return buf.String()
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) writeMarshal(s *structInfo) {
+	g.printf("\n// MarshalPHP implements phpserialize.Marshaler.\n")
+	g.printf("func (x %s) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {\n", s.name)
+	g.printf("if options == nil {\noptions = phpserialize.DefaultMarshalOptions()\n}\n\n")
+
+	fallback := "m, err := phpserialize.MarshalStruct(x, options)\nif err != nil {\nreturn nil, err\n}\n\nreturn append(dst, m...), nil\n"
+	if s.reflect != "" {
+		g.printf("%s}\n", fallback)
+		return
+	}
+
+	// The names of the fields are only known for the default FieldNamer.
+	g.printf("if options.FieldNamer != nil {\n%s}\n", fallback)
+
+	always := 0
+	for _, f := range s.fields {
+		if len(f.keep) == 0 {
+			always++
+		}
+	}
+
+	g.printf("\nn := %d\n", always)
+	for _, f := range s.fields {
+		if len(f.keep) > 0 {
+			g.printf("if %s {\nn++\n}\n", strings.Join(f.keep, " && "))
+		}
+	}
+
+	g.imports["strconv"] = true
+	g.printf("\nif options.OnlyStdClass {\ndst = append(dst, %s...)\n} else {\ndst = append(dst, %s...)\n}\n",
+		strconv.Quote(`O:8:"stdClass":`), strconv.Quote(fmt.Sprintf(`O:%d:"%s":`, len(s.name), s.name)))
+	g.printf("\ndst = strconv.AppendInt(dst, int64(n), 10)\ndst = append(dst, ':', '{')\n")
+
+	needsErr := false
+	var body bytes.Buffer
+	for _, f := range s.fields {
+		code, usesErr := g.encodeField(f)
+		needsErr = needsErr || usesErr
+
+		if len(f.keep) > 0 {
+			code = fmt.Sprintf("if %s {\n%s}\n", strings.Join(f.keep, " && "), code)
+		}
+
+		body.WriteString("\n" + code)
+	}
+
+	if needsErr {
+		g.printf("\nvar err error\n")
+	}
+
+	g.buf.Write(body.Bytes())
+	g.printf("\nreturn append(dst, '}'), nil\n}\n")
+}
+
+// encodeField returns the code that appends the name and value of a field. The
+// second return value is true if the code assigns to err.
+func (g *generator) encodeField(f *fieldInfo) (string, bool) {
+	var code strings.Builder
+	fmt.Fprintf(&code, "dst = append(dst, %s...)\n", strconv.Quote(string(phpserialize.MarshalString(f.name))))
+
+	// A pointer that is omitted when it is nil does not need to be checked
+	// again.
+	value, usesErr := g.encodeValue(f)
+	if f.ptr && !slices.Contains(f.keep, f.target()+" != nil") {
+		fmt.Fprintf(&code, "if %s == nil {\ndst = phpserialize.AppendNil(dst)\n} else {\n%s}\n", f.target(), value)
+	} else {
+		code.WriteString(value)
+	}
+
+	return code.String(), usesErr
+}
+
+func (g *generator) encodeValue(f *fieldInfo) (string, bool) {
+	v := f.value()
+
+	if f.asString {
+		var s string
+		switch f.kind {
+		case kindBool:
+			s = fmt.Sprintf("strconv.FormatBool(%s)", v)
+		case kindInt:
+			s = fmt.Sprintf("strconv.FormatInt(int64(%s), 10)", v)
+		case kindUint:
+			s = fmt.Sprintf("strconv.FormatUint(uint64(%s), 10)", v)
+		case kindFloat:
+			s = fmt.Sprintf("strconv.FormatFloat(float64(%s), 'f', -1, %s)", v, strings.TrimPrefix(f.typeName, "float"))
+		}
+
+		return fmt.Sprintf("dst = phpserialize.AppendString(dst, %s)\n", s), false
+	}
+
+	switch f.kind {
+	case kindBool:
+		return fmt.Sprintf("dst = phpserialize.AppendBool(dst, %s)\n", v), false
+	case kindInt:
+		return fmt.Sprintf("dst = phpserialize.AppendInt(dst, int64(%s))\n", v), false
+	case kindUint:
+		return fmt.Sprintf("dst = phpserialize.AppendUint(dst, uint64(%s))\n", v), false
+	case kindFloat:
+		return fmt.Sprintf("dst = phpserialize.AppendFloat(dst, float64(%s), %s)\n", v, strings.TrimPrefix(f.typeName, "float")), false
+	case kindString:
+		return fmt.Sprintf("dst = phpserialize.AppendString(dst, %s)\n", v), false
+	case kindBytes:
+		return fmt.Sprintf("dst = phpserialize.AppendBytes(dst, %s)\n", v), false
+	case kindStruct:
+		return fmt.Sprintf("dst, err = %s.MarshalPHP(dst, options)\nif err != nil {\nreturn nil, err\n}\n", f.target()), true
+	}
+
+	return fmt.Sprintf("dst, err = phpserialize.AppendMarshal(dst, %s, options)\nif err != nil {\nreturn nil, err\n}\n", f.target()), true
+}
+
+func (g *generator) writeUnmarshal(s *structInfo) {
+	g.printf("\n// UnmarshalPHP implements phpserialize.Unmarshaler.\n")
+	g.printf("func (x *%s) UnmarshalPHP(r *phpserialize.Reader) error {\n", s.name)
+
+	if s.reflect != "" || s.reflectDecode != "" {
+		g.printf("return r.Decode(x)\n}\n")
+		return
+	}
+
+	// Properties are only matched exactly by the generated code. Unknown
+	// properties are not worked out in the same way for aliases.
+	conditions := []string{"r.Peek() != 'O'", "options.CaseInsensitive", "options.FieldNamer != nil"}
+	if s.hasAliases {
+		conditions = append(conditions, "options.DisallowUnknownFields")
+	}
+
+	g.printf("options := r.Options()\nif %s {\nreturn r.Decode(x)\n}\n\n", strings.Join(conditions, " || "))
+	g.printf("_, n, err := r.ReadObjectHeader()\nif err != nil {\nreturn err\n}\n\n")
+	g.printf("unknown := \"\"\nhasUnknown := false\n")
+
+	// An alias is only used if the property with the name of the field, or
+	// an alias before it, does not exist, even if that property is null. So
+	// the value of an alias is skipped over and decoded after every property
+	// has been seen. Each rank is the position of the name that was found,
+	// starting at 1 for the name of the field.
+	for _, f := range s.fields {
+		if len(f.aliases) > 0 {
+			g.printf("%sRank, %sOffset := 0, 0\n", lowerFirst(f.goName), lowerFirst(f.goName))
+		}
+	}
+
+	g.printf("\nfor i := 0; i < n; i++ {\nname, err := r.ReadString()\nif err != nil {\nreturn err\n}\n\nswitch name {\n")
+	for _, f := range s.fields {
+		decode := g.decodeField(f)
+
+		if len(f.aliases) == 0 {
+			g.printf("case %s:\n%s", strconv.Quote(f.name), decode)
+			continue
+		}
+
+		rank, offset := lowerFirst(f.goName)+"Rank", lowerFirst(f.goName)+"Offset"
+		g.printf("case %s:\n%s = 1\n%s", strconv.Quote(f.name), rank, decode)
+
+		for i, alias := range f.aliases {
+			g.printf("case %s:\nif %s == 0 || %s >= %d {\n%s, %s = %d, r.Offset()\n}\n\n", strconv.Quote(alias), rank, rank, i+2, rank, offset, i+2)
+			g.printf("if err := r.Skip(); err != nil {\nreturn err\n}\n")
+		}
+	}
+
+	g.printf("default:\nif options.DisallowUnknownFields && !hasUnknown {\nunknown, hasUnknown = name, true\n}\n\n")
+	g.printf("if err := r.Skip(); err != nil {\nreturn err\n}\n}\n}\n\n")
+	g.printf("if err := r.ReadEnd(); err != nil {\nreturn err\n}\n\n")
+
+	for _, f := range s.fields {
+		if len(f.aliases) == 0 {
+			continue
+		}
+
+		rank, offset := lowerFirst(f.goName)+"Rank", lowerFirst(f.goName)+"Offset"
+		g.printf("if %s > 1 {\nend := r.Offset()\nr.Seek(%s)\n\n%s\nr.Seek(end)\n}\n\n", rank, offset, g.decodeField(f))
+	}
+
+	g.imports["fmt"] = true
+	g.printf("if hasUnknown {\nreturn fmt.Errorf(\"unknown property %%v for type %%T\", unknown, *x)\n}\n\nreturn nil\n}\n")
+}
+
+// readers are the Reader methods, and the character that starts the value,
+// used to decode a field of a basic type without reflection.
+var readers = map[string]struct {
+	char   string
+	method string
+
+	// convert is true if the type that the method returns is not the type
+	// of the field.
+	convert bool
+}{
+	"bool":    {"'b'", "ReadBool", false},
+	"int":     {"'i'", "ReadInt", true},
+	"int64":   {"'i'", "ReadInt", false},
+	"float64": {"'d'", "ReadFloat", false},
+	"string":  {"'s'", "ReadString", false},
+	"[]byte":  {"'s'", "ReadBytes", false},
+}
+
+// decodeField returns the code that decodes the value of a property into a
+// field. Anything that is not read directly is decoded with Reader.Decode.
+func (g *generator) decodeField(f *fieldInfo) string {
+	t := f.target()
+	fallback := fmt.Sprintf("if err := r.Decode(&%s); err != nil {\nreturn phpserialize.FieldError(err, %q)\n}\n", t, f.goName)
+
+	if f.kind == kindStruct {
+		decode := fmt.Sprintf("if err := %s.UnmarshalPHP(r); err != nil {\nreturn phpserialize.FieldError(err, %q)\n}\n", t, f.goName)
+		if !f.ptr {
+			return decode
+		}
+
+		return fmt.Sprintf("if r.Peek() == 'O' {\nif %s == nil {\n%s = new(%s)\n}\n\n%s} else %s", t, t, f.typeName, decode, fallback)
+	}
+
+	reader, ok := readers[f.typeName]
+	if !ok || f.kind == kindOther {
+		return fallback
+	}
+
+	assign := fmt.Sprintf("%s = v\n", t)
+	if reader.convert {
+		assign = fmt.Sprintf("%s = %s(v)\n", t, f.typeName)
+	}
+
+	if f.ptr {
+		assign = fmt.Sprintf("if %s == nil {\n%s = new(%s)\n}\n\n*%s", t, t, f.typeName, assign)
+	}
+
+	return fmt.Sprintf("if r.Peek() == %s {\nv, err := r.%s()\nif err != nil {\nreturn err\n}\n\n%s} else %s",
+		reader.char, reader.method, assign, fallback)
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The generated code in internal/gentest is tested against the reflection
+// path. This makes sure that it is up to date.
+func TestGenerateMatchesGentest(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	outputPath := filepath.Join(dir, "profile_phpserialize.go")
+
+	g, err := parsePackage(dir, outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := g.generate([]string{"Profile", "Address", "Counter", "Legacy", "Embedding"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(src) != string(expected) {
+		t.Errorf("%s is out of date, run go generate", outputPath)
+	}
+
+	expectedWarnings := []string{
+		"Counter is decoded with reflection because field Enable has the string option",
+		"Legacy uses reflection because field When has the unix option",
+		"Embedding uses reflection because it embeds Base",
+	}
+
+	if strings.Join(g.warnings, "\n") != strings.Join(expectedWarnings, "\n") {
+		t.Errorf("Expected warnings %q, got %q", expectedWarnings, g.warnings)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for source, expected := range map[string]string{
+		"type T int":                                 "type T is not a struct in package p",
+		"type T[V any] struct{ V V }":                "type T has type parameters",
+		"type T struct{}\nfunc (*T) MarshalPHP() {}": "type T already has a MarshalPHP method",
+		"type T struct{}\nfunc (T) MarshalText() ([]byte, error) { return nil, nil }": "type T already has a MarshalText method",
+	} {
+		t.Run(expected, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\n"+source+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			g, err := parsePackage(dir, filepath.Join(dir, "t_phpserialize.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = g.generate([]string{"T"})
+			if err == nil || err.Error() != expected {
+				t.Errorf("Expected error '%s', got '%v'", expected, err)
+			}
+		})
+	}
+}
+
+func TestRunWritesOutput(t *testing.T) {
+	dir := t.TempDir()
+	source := "package p\n\ntype T struct {\n\tA int\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputPath := filepath.Join(dir, "t_phpserialize.go")
+	if err := run(dir, []string{"T"}, outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running again must ignore the file that was generated before.
+	if err := run(dir, []string{"T"}, outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(src), "func (x T) MarshalPHP(") || !strings.Contains(string(src), "func (x *T) UnmarshalPHP(") {
+		t.Errorf("Expected both methods, got:\n%s", src)
+	}
+}
@@ -0,0 +1,71 @@
+// Command phpserialize-gen generates MarshalPHP and UnmarshalPHP methods for
+// structs, so that they can be encoded and decoded by phpserialize without
+// reflection. The output is the same as the reflection path, including the
+// "php" tags of the fields.
+//
+// It is intended to be used with go:generate:
+//
+//	//go:generate phpserialize-gen -type User,Address
+//
+// The methods are written to <type>_phpserialize.go, where <type> is the first
+// type in lowercase, unless -output is used. The directory of the package is
+// the current directory unless one is given as an argument.
+//
+// Structs with fields that the generated code can not handle in exactly the
+// same way, such as embedded structs or a "rest" field, still get both methods
+// but they fall back to reflection. A warning is printed for each one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_phpserialize.go")
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	types := strings.Split(*typeNames, ",")
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = filepath.Join(dir, strings.ToLower(types[0])+"_phpserialize.go")
+	}
+
+	if err := run(dir, types, outputPath); err != nil {
+		fmt.Fprintln(os.Stderr, "phpserialize-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, types []string, outputPath string) error {
+	g, err := parsePackage(dir, outputPath)
+	if err != nil {
+		return err
+	}
+
+	src, err := g.generate(types)
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range g.warnings {
+		fmt.Fprintln(os.Stderr, "phpserialize-gen:", warning)
+	}
+
+	return os.WriteFile(outputPath, src, 0o644)
+}
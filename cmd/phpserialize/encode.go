@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+// toPHP decodes JSON data and returns it as serialized PHP.
+func (c *config) toPHP(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	value, err := c.decodeJSON(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+
+	options := phpserialize.DefaultMarshalOptions()
+	options.NormalizeKeys = true
+
+	var out []byte
+	if c.session {
+		out, err = marshalSession(value, options)
+	} else {
+		out, err = phpserialize.Marshal(value, options)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.forceBase64 {
+		out = []byte(base64.StdEncoding.EncodeToString(out))
+	}
+
+	return out, nil
+}
+
+// decodeJSON reads the next JSON value. Objects are decoded into an
+// *orderedmap.OrderedMap[any, any], or an *phpserialize.Object if they have a
+// class name, so that the order of their keys is kept.
+func (c *config) decodeJSON(d *json.Decoder) (interface{}, error) {
+	token, err := d.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '[' {
+			return c.decodeJSONArray(d)
+		}
+
+		return c.decodeJSONObject(d)
+
+	case json.Number:
+		return c.number(t)
+	}
+
+	// A string, bool or nil.
+	return token, nil
+}
+
+func (c *config) decodeJSONArray(d *json.Decoder) (interface{}, error) {
+	list := []interface{}{}
+	for d.More() {
+		v, err := c.decodeJSON(d)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, v)
+	}
+
+	// The closing ']'.
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (c *config) decodeJSONObject(d *json.Decoder) (interface{}, error) {
+	m := orderedmap.NewOrderedMap[any, any]()
+	var className interface{}
+
+	for d.More() {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key := token.(string)
+
+		v, err := c.decodeJSON(d)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.classKey != "" && key == c.classKey {
+			className = v
+			continue
+		}
+
+		m.Set(key, v)
+	}
+
+	// The closing '}'.
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+
+	if className == nil {
+		return m, nil
+	}
+
+	name, ok := className.(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("the %s key must be a class name", c.classKey)
+	}
+
+	return &phpserialize.Object{ClassName: name, Properties: m}, nil
+}
+
+// number converts a JSON number to an int64 or float64. PHP turns a number
+// that is too large for a float into INF, which ParseFloat also returns along
+// with an error.
+func (c *config) number(n json.Number) (interface{}, error) {
+	if c.numbers == "auto" && !strings.ContainsAny(string(n), ".eE") {
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return i, nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// marshalSession encodes a JSON object in the format that PHP stores sessions
+// in by default, which is each variable name followed by "|" and its
+// serialized value.
+func marshalSession(value interface{}, options *phpserialize.MarshalOptions) ([]byte, error) {
+	m, ok := value.(*orderedmap.OrderedMap[any, any])
+	if !ok {
+		return nil, errors.New("a session must be a JSON object without a class name")
+	}
+
+	var out []byte
+	for key, v := range m.AllFromFront() {
+		name := key.(string)
+
+		// PHP can not read a name with either of these in it.
+		if strings.ContainsAny(name, "|!") {
+			return nil, fmt.Errorf("can not use %q as a session variable name", name)
+		}
+
+		out = append(out, name...)
+		out = append(out, '|')
+
+		var err error
+		out, err = phpserialize.AppendMarshal(out, v, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	for testName, test := range map[string]struct {
+		args   []string
+		input  string
+		output string
+	}{
+		"scalars": {
+			nil,
+			`[null, true, "it's", -3, 0.5]`,
+			`a:5:{i:0;N;i:1;b:1;i:2;s:5:"it\'s";i:3;i:-3;i:4;d:0.5;}`,
+		},
+		"numbers like json_decode": {
+			nil,
+			`[1, 1.0, 1e2, -0, 9223372036854775807, 9223372036854775808, 1e400]`,
+			`a:7:{i:0;i:1;i:1;d:1;i:2;d:100;i:3;i:0;i:4;i:9223372036854775807;i:5;d:9223372036854776000;i:6;d:INF;}`,
+		},
+		"float numbers": {
+			[]string{"-numbers", "float"},
+			`[1, 2.5]`,
+			`a:2:{i:0;d:1;i:1;d:2.5;}`,
+		},
+		"key order is kept": {
+			nil,
+			`{"z": 1, "a": 2, "m": 3}`,
+			`a:3:{s:1:"z";i:1;s:1:"a";i:2;s:1:"m";i:3;}`,
+		},
+		"numeric keys": {
+			nil,
+			`{"5": "a", "-1": "b", "05": "c", "1.5": "d"}`,
+			`a:4:{i:5;s:1:"a";i:-1;s:1:"b";s:2:"05";s:1:"c";s:3:"1.5";s:1:"d";}`,
+		},
+		"backslash keys": {
+			nil,
+			`{"C:\\x41\\n": 1, "a\\x41": 2, "aA": 3}`,
+			`a:3:{s:8:"C:\x41\n";i:1;s:5:"a\x41";i:2;s:2:"aA";i:3;}`,
+		},
+		"object": {
+			nil,
+			`{"id": 1, "__class": "User", "address": {"__class": "Address", "5": "x"}}`,
+			`O:4:"User":2:{s:2:"id";i:1;s:7:"address";O:7:"Address":1:{s:1:"5";s:1:"x";}}`,
+		},
+		"class key": {
+			[]string{"-class-key", "@type"},
+			`{"@type": "User", "__class": "x"}`,
+			`O:4:"User":1:{s:7:"__class";s:1:"x";}`,
+		},
+		"no class key": {
+			[]string{"-class-key", ""},
+			`{"__class": "User"}`,
+			`a:1:{s:7:"__class";s:4:"User";}`,
+		},
+		"session": {
+			[]string{"-session"},
+			`{"user": {"__class": "User", "id": 1}, "count": 2, "flash": []}`,
+			`user|O:4:"User":1:{s:2:"id";i:1;}count|i:2;flash|a:0:{}`,
+		},
+		"base64": {
+			[]string{"-base64"},
+			`"hi"`,
+			`czoyOiJoaSI7`,
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			stdout, stderr, status := runCommand(t, test.input, append([]string{"-encode"}, test.args...)...)
+			if status != 0 {
+				t.Fatalf("Expected status 0, got %d: %s", status, stderr)
+			}
+
+			if stdout != test.output {
+				t.Errorf("Expected:\n%s\ngot:\n%s", test.output, stdout)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for testName, test := range map[string]struct {
+		args   []string
+		input  string
+		stderr string
+	}{
+		"empty":          {nil, "", "phpserialize: stdin: unexpected EOF\n"},
+		"trailing data":  {nil, "1 2", "phpserialize: stdin: unexpected data after the JSON value\n"},
+		"invalid":        {nil, "{1}", "phpserialize: stdin: object member name must be a string\n"},
+		"class name":     {nil, `{"__class": 5}`, "phpserialize: stdin: the __class key must be a class name\n"},
+		"session value":  {[]string{"-session"}, `[1]`, "phpserialize: stdin: a session must be a JSON object without a class name\n"},
+		"session name":   {[]string{"-session"}, `{"a|b": 1}`, "phpserialize: stdin: can not use \"a|b\" as a session variable name\n"},
+		"session object": {[]string{"-session"}, `{"__class": "A"}`, "phpserialize: stdin: a session must be a JSON object without a class name\n"},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			_, stderr, status := runCommand(t, test.input, append([]string{"-encode"}, test.args...)...)
+			if status != 1 || stderr != test.stderr {
+				t.Errorf("Expected 1 %q, got %d %q", test.stderr, status, stderr)
+			}
+		})
+	}
+
+	_, stderr, status := runCommand(t, "{}", "-session")
+	if status != 2 || stderr != "phpserialize: -session can only be used with -encode\n" {
+		t.Errorf("Expected a usage error, got %d %q", status, stderr)
+	}
+}
+
+// Converting to JSON and back gives the same data, as long as it has no
+// binary strings or numbers that JSON changes.
+func TestRoundTrip(t *testing.T) {
+	input := `a:3:{s:1:"b";O:4:"User":2:{s:2:"id";i:5;s:4:"tags";a:2:{i:0;s:1:"x";i:1;d:1.5;}}i:7;a:0:{}s:1:"a";N;}`
+
+	for _, args := range [][]string{nil, {"-compact"}, {"-keys", "object"}} {
+		json, stderr, status := runCommand(t, input, args...)
+		if status != 0 {
+			t.Fatalf("Expected status 0, got %d: %s", status, stderr)
+		}
+
+		output, stderr, status := runCommand(t, json, "-encode")
+		if status != 0 {
+			t.Fatalf("Expected status 0, got %d: %s", status, stderr)
+		}
+
+		// -keys object turns the empty list into an empty object,
+		// which is the same PHP array.
+		if output != input {
+			t.Errorf("%v: expected:\n%s\ngot:\n%s", args, input, output)
+		}
+	}
+}
+
+func TestEncodeSeveralFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.json")
+	if err := os.WriteFile(path, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, status := runCommand(t, `"x"`, "-encode", path, "-", path)
+	if status != 0 {
+		t.Fatalf("Expected status 0, got %d: %s", status, stderr)
+	}
+
+	expected := "i:1;\ns:1:\"x\";\ni:1;"
+	if stdout != expected {
+		t.Errorf("Expected %q, got %q", expected, stdout)
+	}
+}
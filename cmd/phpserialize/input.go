@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// unwrap returns the serialized data inside input, which may be base64
+// encoded, compressed, or both. Whitespace around text, such as the newline at
+// the end of a file, is ignored.
+func unwrap(input []byte, forceBase64 bool) ([]byte, error) {
+	data := input
+	text := bytes.TrimSpace(input)
+
+	// Compressed data is very unlikely to be valid base64, so anything that
+	// is not clearly something else is tried as base64 first. Input that
+	// is not valid base64 is left for the decoder to explain what is wrong
+	// with it.
+	if forceBase64 || (!looksSerialized(text) && !isGzip(input)) {
+		decoded, err := decodeBase64(text)
+		if err == nil {
+			data = decoded
+		} else if forceBase64 {
+			return nil, err
+		}
+	}
+
+	switch {
+	case isGzip(data):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = decompress(r)
+		if err != nil {
+			return nil, err
+		}
+
+	case isZlib(data):
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = decompress(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if !looksSerialized(data) {
+		return nil, errors.New("input is not serialized PHP data")
+	}
+
+	return data, nil
+}
+
+func decompress(r io.ReadCloser) ([]byte, error) {
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("can not decompress input: %v", err)
+	}
+
+	return data, nil
+}
+
+// looksSerialized is true if data starts in the same way as a serialized PHP
+// value, such as "a:" or "N;". Neither ':' nor ';' are used by base64.
+func looksSerialized(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+
+	switch data[0] {
+	case 'a', 'b', 'd', 'i', 's', 'N', 'O', 'C', 'r', 'R':
+		return data[1] == ':' || data[1] == ';'
+	}
+
+	return false
+}
+
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// isZlib checks the header that PHP's gzcompress() writes. The first byte is
+// the compression method and the two bytes are a multiple of 31.
+func isZlib(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// decodeBase64 accepts standard and URL-safe base64, with or without padding.
+// Line breaks, which some tools add, are ignored.
+func decodeBase64(data []byte) ([]byte, error) {
+	data = bytes.Join(bytes.Fields(data), nil)
+
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		if decoded, err := encoding.DecodeString(string(data)); err == nil {
+			return decoded, nil
+		}
+	}
+
+	return nil, errors.New("input is not valid base64")
+}
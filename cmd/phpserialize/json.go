@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+// toJSON decodes serialized PHP data and returns it as JSON.
+func (c *config) toJSON(data []byte) ([]byte, error) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+	options.UseNumber = true
+
+	r := phpserialize.NewReader(data, options)
+
+	var value interface{}
+	if err := r.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if r.Offset() != len(data) {
+		return nil, fmt.Errorf("unexpected data after the value at offset %d", r.Offset())
+	}
+
+	w := &jsonWriter{config: c, visiting: map[*orderedmap.OrderedMap[any, any]]bool{}}
+	if err := w.write(value, 0); err != nil {
+		return nil, err
+	}
+
+	return w.buf.Bytes(), nil
+}
+
+// jsonWriter writes JSON itself, rather than with encoding/json, so that the
+// order of array keys and object properties is kept.
+type jsonWriter struct {
+	config *config
+	buf    bytes.Buffer
+
+	// visiting holds the arrays and object properties that are being
+	// written. An object can refer to itself with r:, which JSON can not.
+	visiting map[*orderedmap.OrderedMap[any, any]]bool
+}
+
+func (w *jsonWriter) write(value interface{}, depth int) error {
+	switch v := value.(type) {
+	case nil:
+		w.buf.WriteString("null")
+
+	case bool:
+		w.buf.WriteString(strconv.FormatBool(v))
+
+	case int64:
+		w.buf.WriteString(strconv.FormatInt(v, 10))
+
+	case phpserialize.Number:
+		w.writeNumber(string(v))
+
+	case string:
+		w.writeString(v)
+
+	case []interface{}:
+		return w.writeList(v, depth)
+
+	case *orderedmap.OrderedMap[any, any]:
+		return w.writeArray(v, depth)
+
+	case *phpserialize.Object:
+		return w.writeObject(v, depth)
+
+	default:
+		return fmt.Errorf("can not convert %T to JSON", value)
+	}
+
+	return nil
+}
+
+// writeNumber writes the text of a PHP number, which keeps all of its digits.
+// PHP accepts text that JSON does not, such as "007", ".5" and "1.", so it is
+// changed to the same number in the form that JSON allows.
+func (w *jsonWriter) writeNumber(s string) {
+	switch s {
+	case "INF", "-INF", "NAN":
+		w.writeString(s)
+		return
+	}
+
+	n := jsonNumber(s)
+	if !json.Valid([]byte(n)) {
+		// Anything else that PHP accepts is converted to a float.
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			w.writeString(s)
+			return
+		}
+
+		n = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	w.buf.WriteString(n)
+}
+
+// jsonNumber removes leading zeros and an empty fraction from
+// the text of a number, and adds a zero before a fraction with no whole part.
+func jsonNumber(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i:]
+	}
+
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+
+	whole = strings.TrimLeft(whole, "0")
+	if whole == "" {
+		whole = "0"
+	}
+
+	if fraction != "" {
+		fraction = "." + fraction
+	}
+
+	return sign + whole + fraction + exponent
+}
+
+func (w *jsonWriter) writeString(s string) {
+	if !utf8.ValidString(s) {
+		if w.config.binary == "base64" {
+			s = base64.StdEncoding.EncodeToString([]byte(s))
+		} else {
+			s = escapeBinary(s)
+		}
+	}
+
+	// encoding/json escapes the string correctly. It can not fail for a
+	// string.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+
+	w.buf.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// escapeBinary replaces each byte that is not part of a valid UTF-8 sequence
+// with the text \xNN.
+func escapeBinary(s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, `\x%02x`, s[0])
+		} else {
+			b.WriteString(s[:size])
+		}
+
+		s = s[size:]
+	}
+
+	return b.String()
+}
+
+func (w *jsonWriter) writeList(list []interface{}, depth int) error {
+	if w.config.keys == "auto" {
+		w.buf.WriteByte('[')
+		for i, v := range list {
+			w.separator(i, depth+1)
+			if err := w.write(v, depth+1); err != nil {
+				return err
+			}
+		}
+
+		w.close(']', len(list) == 0, depth)
+
+		return nil
+	}
+
+	m := orderedmap.NewOrderedMapWithCapacity[any, any](len(list))
+	for i, v := range list {
+		m.Set(int64(i), v)
+	}
+
+	return w.writeArray(m, depth)
+}
+
+// writeArray writes a PHP array that has keys other than 0, 1, 2...
+func (w *jsonWriter) writeArray(m *orderedmap.OrderedMap[any, any], depth int) error {
+	if w.visiting[m] {
+		return errors.New("can not convert a value that contains itself to JSON")
+	}
+
+	w.visiting[m] = true
+	defer delete(w.visiting, m)
+
+	if w.config.keys != "pairs" {
+		return w.writeProperties("", m, depth)
+	}
+
+	w.buf.WriteByte('[')
+
+	i := 0
+	for key, v := range m.AllFromFront() {
+		w.separator(i, depth+1)
+		w.buf.WriteByte('[')
+
+		if err := w.write(key, depth+1); err != nil {
+			return err
+		}
+
+		w.buf.WriteByte(',')
+		if !w.config.compact {
+			w.buf.WriteByte(' ')
+		}
+
+		if err := w.write(v, depth+1); err != nil {
+			return err
+		}
+
+		w.buf.WriteByte(']')
+		i++
+	}
+
+	w.close(']', m.Len() == 0, depth)
+
+	return nil
+}
+
+func (w *jsonWriter) writeObject(o *phpserialize.Object, depth int) error {
+	if w.visiting[o.Properties] {
+		return errors.New("can not convert a value that contains itself to JSON")
+	}
+
+	w.visiting[o.Properties] = true
+	defer delete(w.visiting, o.Properties)
+
+	className := o.ClassName
+	if w.config.classKey == "" {
+		className = ""
+	}
+
+	return w.writeProperties(className, o.Properties, depth)
+}
+
+// writeProperties writes a JSON object. The class name is written first if
+// it is not empty.
+func (w *jsonWriter) writeProperties(className string, m *orderedmap.OrderedMap[any, any], depth int) error {
+	empty := m.Len() == 0 && className == ""
+	w.buf.WriteByte('{')
+
+	i := 0
+	if className != "" {
+		w.separator(i, depth+1)
+		w.writeKey(w.config.classKey)
+		w.writeString(className)
+		i++
+	}
+
+	for key, v := range m.AllFromFront() {
+		// The key would be read as the class name, so the JSON could not be
+		// converted back.
+		name := fmt.Sprint(key)
+		if w.config.classKey != "" && name == w.config.classKey {
+			return fmt.Errorf("the key %q is the same as the -class-key", name)
+		}
+
+		w.separator(i, depth+1)
+		w.writeKey(name)
+
+		if err := w.write(v, depth+1); err != nil {
+			return err
+		}
+
+		i++
+	}
+
+	w.close('}', empty, depth)
+
+	return nil
+}
+
+func (w *jsonWriter) writeKey(key string) {
+	w.writeString(key)
+
+	if w.config.compact {
+		w.buf.WriteByte(':')
+	} else {
+		w.buf.WriteString(": ")
+	}
+}
+
+// separator is written before the element at index i.
+func (w *jsonWriter) separator(i, depth int) {
+	if i > 0 {
+		w.buf.WriteByte(',')
+	}
+
+	w.newline(depth)
+}
+
+func (w *jsonWriter) close(c byte, empty bool, depth int) {
+	if !empty {
+		w.newline(depth)
+	}
+
+	w.buf.WriteByte(c)
+}
+
+func (w *jsonWriter) newline(depth int) {
+	if w.config.compact {
+		return
+	}
+
+	w.buf.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		w.buf.WriteString("  ")
+	}
+}
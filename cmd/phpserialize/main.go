@@ -0,0 +1,200 @@
+// Command phpserialize converts serialized PHP values to JSON, for reading the
+// blobs that PHP applications store in databases and caches.
+//
+// It reads each file given as an argument, or stdin if there are none (or the
+// file is "-"), and prints one JSON document for each:
+//
+//	redis-cli GET session:abc | phpserialize
+//	phpserialize -binary base64 -keys pairs dump1.txt dump2.txt
+//
+// Input that is gzip or zlib compressed (such as the output of PHP's
+// gzcompress()) is decompressed, and input that is base64 encoded is decoded
+// first. Base64 is detected automatically, or it can be forced with -base64.
+//
+// The flags control how the PHP values that JSON does not have are written:
+//
+//	-class-key name  the key that holds the class name of an object, such as
+//	                 {"__class": "User", "id": 1}. The default is "__class".
+//	                 An empty name leaves the class name out. An object
+//	                 property or array key with the same name is an error.
+//	-binary mode     how strings that are not valid UTF-8 are written:
+//	                 "escape" (the default) writes each invalid byte as the
+//	                 text \xNN, and "base64" writes the whole string in
+//	                 base64.
+//	-keys mode       how arrays are written: "auto" (the default) writes an
+//	                 array with the keys 0, 1, 2... as a JSON array and any
+//	                 other as a JSON object. "object" always writes a JSON
+//	                 object. "pairs" writes a JSON array of [key, value]
+//	                 pairs, so that integer and string keys can be told apart
+//	                 and their order is kept by any JSON parser.
+//	-compact         write each document on one line instead of indenting it.
+//
+// Numbers are written exactly as they are in the PHP data. INF, -INF and NAN,
+// which JSON can not represent, are written as strings.
+//
+// With -encode it works the other way around, turning JSON into serialized PHP
+// for seeding caches and fixtures:
+//
+//	phpserialize -encode user.json | redis-cli -x SET user:1
+//
+// The order of the keys of JSON objects is kept. An object with the -class-key
+// key is written as a PHP object of that class, and any other JSON object as
+// an associative array. Numeric keys, such as "5", become integer keys in the
+// same way as they do in PHP. These flags also apply:
+//
+//	-numbers mode    how JSON numbers are written: "auto" (the default) writes
+//	                 a number without a fraction or exponent as an integer if
+//	                 it fits, and anything else as a float, which is the same
+//	                 as PHP's json_decode(). "float" writes every number as a
+//	                 float.
+//	-session         write the PHP session format, name|value for each key of
+//	                 a JSON object, instead of a single value.
+//	-base64          write the output in base64.
+//
+// The output does not end with a newline, so that it can be stored as it is.
+// The outputs for several files are separated by newlines.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// config holds the command-line flags.
+type config struct {
+	classKey    string
+	binary      string
+	keys        string
+	compact     bool
+	forceBase64 bool
+
+	encode  bool
+	numbers string
+	session bool
+}
+
+// run runs the command and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var c config
+
+	flags := flag.NewFlagSet("phpserialize", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.classKey, "class-key", "__class", "key for the class name of objects; empty to leave it out")
+	flags.StringVar(&c.binary, "binary", "escape", "how to write strings that are not UTF-8: escape or base64")
+	flags.StringVar(&c.keys, "keys", "auto", "how to write arrays: auto, object or pairs")
+	flags.BoolVar(&c.compact, "compact", false, "write each document on one line")
+	flags.BoolVar(&c.forceBase64, "base64", false, "decode the input from base64 even if it does not look like base64; with -encode, write the output in base64")
+	flags.BoolVar(&c.encode, "encode", false, "convert JSON to serialized PHP instead")
+	flags.StringVar(&c.numbers, "numbers", "auto", "with -encode, how to write JSON numbers: auto or float")
+	flags.BoolVar(&c.session, "session", false, "with -encode, write the PHP session format")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := c.validate(); err != nil {
+		fmt.Fprintln(stderr, "phpserialize:", err)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	status := 0
+	written := 0
+	for _, path := range paths {
+		out, err := c.convertFile(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "phpserialize: %s: %v\n", displayName(path), err)
+			status = 1
+			continue
+		}
+
+		// JSON always ends with a newline. Serialized PHP only has one
+		// between the outputs.
+		if !c.encode {
+			out = append(out, '\n')
+		} else if written > 0 {
+			out = append([]byte{'\n'}, out...)
+		}
+
+		if _, err := stdout.Write(out); err != nil {
+			fmt.Fprintln(stderr, "phpserialize:", err)
+			return 1
+		}
+
+		written++
+	}
+
+	return status
+}
+
+func (c *config) validate() error {
+	switch c.binary {
+	case "escape", "base64":
+	default:
+		return fmt.Errorf("unknown -binary mode %q", c.binary)
+	}
+
+	switch c.keys {
+	case "auto", "object", "pairs":
+	default:
+		return fmt.Errorf("unknown -keys mode %q", c.keys)
+	}
+
+	switch c.numbers {
+	case "auto", "float":
+	default:
+		return fmt.Errorf("unknown -numbers mode %q", c.numbers)
+	}
+
+	if c.session && !c.encode {
+		return errors.New("-session can only be used with -encode")
+	}
+
+	return nil
+}
+
+func displayName(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+
+	return path
+}
+
+// convertFile returns the converted contents of the file at path.
+func (c *config) convertFile(path string, stdin io.Reader) ([]byte, error) {
+	var data []byte
+	var err error
+
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.encode {
+		return c.toPHP(data)
+	}
+
+	data, err = unwrap(data, c.forceBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.toJSON(data)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const user = `O:4:"User":2:{s:2:"id";i:5;s:4:"tags";a:2:{i:0;s:1:"a";i:1;s:1:"b";}}`
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return stdout.String(), stderr.String(), status
+}
+
+func TestConvert(t *testing.T) {
+	for testName, test := range map[string]struct {
+		args   []string
+		input  string
+		output string
+	}{
+		"scalars": {
+			[]string{"-compact"},
+			`a:5:{i:0;N;i:1;b:1;i:2;i:-3;i:3;d:0.1;i:4;s:5:"a"b<c";}`,
+			`[null,true,-3,0.1,"a\"b<c"]`,
+		},
+		"infinity": {
+			[]string{"-compact"},
+			`a:3:{i:0;d:INF;i:1;d:-INF;i:2;d:NAN;}`,
+			`["INF","-INF","NAN"]`,
+		},
+		"big numbers are kept": {
+			[]string{"-compact"},
+			`a:2:{i:0;i:9223372036854775807;i:1;d:1.0E+25;}`,
+			`[9223372036854775807,1.0E+25]`,
+		},
+		"numbers that are not valid JSON": {
+			[]string{"-compact"},
+			`a:6:{i:0;d:.5;i:1;d:1.;i:2;i:007;i:3;i:-007;i:4;d:-.5e3;i:5;d:00.25;}`,
+			`[0.5,1,7,-7,-0.5e3,0.25]`,
+		},
+		"object": {
+			[]string{"-compact"},
+			user,
+			`{"__class":"User","id":5,"tags":["a","b"]}`,
+		},
+		"class key": {
+			[]string{"-compact", "-class-key", "@type"},
+			user,
+			`{"@type":"User","id":5,"tags":["a","b"]}`,
+		},
+		"no class key": {
+			[]string{"-compact", "-class-key", ""},
+			user,
+			`{"id":5,"tags":["a","b"]}`,
+		},
+		"associative array order is kept": {
+			[]string{"-compact"},
+			`a:3:{s:1:"z";i:1;i:5;i:2;s:1:"a";i:3;}`,
+			`{"z":1,"5":2,"a":3}`,
+		},
+		"object keys": {
+			[]string{"-compact", "-keys", "object"},
+			`a:2:{i:0;s:1:"a";i:1;a:0:{}}`,
+			`{"0":"a","1":{}}`,
+		},
+		"pair keys": {
+			[]string{"-compact", "-keys", "pairs"},
+			`a:2:{i:0;s:1:"a";s:1:"0";a:1:{i:3;b:0;}}`,
+			`[[0,"a"],["0",[[3,false]]]]`,
+		},
+		"escaped binary": {
+			[]string{"-compact"},
+			"s:4:\"\x00\xffé\";",
+			`"\u0000\\xffé"`,
+		},
+		"base64 binary": {
+			[]string{"-compact", "-binary", "base64"},
+			"a:2:{i:0;s:2:\"\x00\xff\";i:1;s:2:\"ok\";}",
+			`["AP8=","ok"]`,
+		},
+		"indented": {
+			nil,
+			`a:2:{i:0;a:0:{}i:1;O:1:"A":1:{s:1:"b";a:1:{s:1:"c";i:1;}}}`,
+			"[\n  [],\n  {\n    \"__class\": \"A\",\n    \"b\": {\n      \"c\": 1\n    }\n  }\n]",
+		},
+		"indented pairs": {
+			[]string{"-keys", "pairs"},
+			`a:1:{s:1:"a";i:1;}`,
+			"[\n  [\"a\", 1]\n]",
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			stdout, stderr, status := runCommand(t, test.input, test.args...)
+			if status != 0 {
+				t.Fatalf("Expected status 0, got %d: %s", status, stderr)
+			}
+
+			if stdout != test.output+"\n" {
+				t.Errorf("Expected:\n%s\ngot:\n%s", test.output, stdout)
+			}
+		})
+	}
+}
+
+func gzipped(s string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+
+	return buf.String()
+}
+
+func zlibbed(s string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+
+	return buf.String()
+}
+
+// wrapped splits s into lines of 20 characters.
+func wrapped(s string) string {
+	var b strings.Builder
+	for len(s) > 20 {
+		b.WriteString(s[:20] + "\n")
+		s = s[20:]
+	}
+
+	return b.String() + s + "\n"
+}
+
+func TestWrappedInput(t *testing.T) {
+	for testName, input := range map[string]string{
+		"newline":                user + "\n",
+		"base64":                 base64.StdEncoding.EncodeToString([]byte(user)) + "\n",
+		"wrapped base64":         wrapped(base64.StdEncoding.EncodeToString([]byte(user))),
+		"unpadded base64":        base64.RawURLEncoding.EncodeToString([]byte(user)),
+		"gzip":                   gzipped(user),
+		"zlib":                   zlibbed(user),
+		"base64 of gzip":         base64.StdEncoding.EncodeToString([]byte(gzipped(user))),
+		"base64 of zlib":         base64.StdEncoding.EncodeToString([]byte(zlibbed(user))),
+		"gzip with trailing new": gzipped(user + "\n"),
+	} {
+		t.Run(testName, func(t *testing.T) {
+			stdout, stderr, status := runCommand(t, input, "-compact")
+			if status != 0 {
+				t.Fatalf("Expected status 0, got %d: %s", status, stderr)
+			}
+
+			expected := `{"__class":"User","id":5,"tags":["a","b"]}` + "\n"
+			if stdout != expected {
+				t.Errorf("Expected %s, got %s", expected, stdout)
+			}
+		})
+	}
+}
+
+func TestForceBase64(t *testing.T) {
+	// Serialized data is never valid base64.
+	_, stderr, status := runCommand(t, "N;", "-base64")
+	if status != 1 || stderr != "phpserialize: stdin: input is not valid base64\n" {
+		t.Errorf("Expected an error, got %d: %s", status, stderr)
+	}
+
+	stdout, _, status := runCommand(t, base64.StdEncoding.EncodeToString([]byte("b:1;")), "-base64")
+	if status != 0 || stdout != "true\n" {
+		t.Errorf("Expected true, got %d: %s", status, stdout)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	bad := filepath.Join(dir, "bad.txt")
+
+	if err := os.WriteFile(good, []byte("i:1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(bad, []byte("i:1;i:2;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every file is converted, even after one of them fails.
+	stdout, stderr, status := runCommand(t, "s:1:\"x\";", good, bad, "-", good)
+	if status != 1 {
+		t.Errorf("Expected status 1, got %d", status)
+	}
+
+	if stdout != "1\n\"x\"\n1\n" {
+		t.Errorf("Expected three documents, got %q", stdout)
+	}
+
+	expected := "phpserialize: " + bad + ": unexpected data after the value at offset 4\n"
+	if stderr != expected {
+		t.Errorf("Expected %q, got %q", expected, stderr)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for testName, test := range map[string]struct {
+		args   []string
+		input  string
+		status int
+		stderr string
+	}{
+		"not serialized": {nil, "hello world", 1, "phpserialize: stdin: input is not serialized PHP data\n"},
+		"corrupt":        {nil, "a:1:{i:0;", 1, "phpserialize: stdin: corrupt\n"},
+		"binary mode":    {[]string{"-binary", "hex"}, "N;", 2, "phpserialize: unknown -binary mode \"hex\"\n"},
+		"keys mode":      {[]string{"-keys", "list"}, "N;", 2, "phpserialize: unknown -keys mode \"list\"\n"},
+		"missing file":   {[]string{"does-not-exist"}, "", 1, "phpserialize: does-not-exist: open does-not-exist: no such file or directory\n"},
+		"class key property": {
+			nil, `O:3:"Foo":1:{s:7:"__class";s:3:"Bar";}`, 1,
+			"phpserialize: stdin: the key \"__class\" is the same as the -class-key\n",
+		},
+		"recursive object": {
+			nil, `O:8:"stdClass":1:{s:4:"self";r:1;}`, 1,
+			"phpserialize: stdin: can not convert a value that contains itself to JSON\n",
+		},
+		"class key array": {
+			[]string{"-class-key", "type"}, `a:1:{s:4:"type";i:1;}`, 1,
+			"phpserialize: stdin: the key \"type\" is the same as the -class-key\n",
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			_, stderr, status := runCommand(t, test.input, test.args...)
+			if status != test.status || stderr != test.stderr {
+				t.Errorf("Expected %d %q, got %d %q", test.status, test.stderr, status, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package phpserialize
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// CoercionMode controls how a decoded PHP value is converted when its type does
+// not match the Go type it is being decoded into.
+type CoercionMode int
+
+const (
+	// CoerceStrict only performs conversions that can not lose information,
+	// such as an integer into a float64 or an int8. Any other mismatch
+	// returns an *UnmarshalTypeError.
+	CoerceStrict CoercionMode = iota
+
+	// CoercePHP follows the type juggling rules PHP uses when casting
+	// between types. For example, the string "42" decodes into an int as 42,
+	// the integer 1 decodes into a bool as true and the float 3.9 decodes
+	// into an int as 3.
+	CoercePHP
+)
+
+// UnmarshalTypeError describes a PHP value that could not be decoded into a
+// specific Go type.
+type UnmarshalTypeError struct {
+	// Value is the PHP type of the value, such as "string" or "array".
+	Value string
+
+	// Type is the Go type that the value could not be decoded into.
+	Type reflect.Type
+
+	// Field is the path of the struct field that was being decoded, such as
+	// "Foo.Bar". It will be empty if the value was not inside a struct.
+	Field string
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Field != "" {
+		return "can not unmarshal PHP " + e.Value + " into struct field " +
+			e.Field + " of type " + e.Type.String()
+	}
+
+	return "can not unmarshal PHP " + e.Value + " into type " + e.Type.String()
+}
+
+func newUnmarshalTypeError(value interface{}, t reflect.Type) *UnmarshalTypeError {
+	return &UnmarshalTypeError{
+		Value: phpTypeName(value),
+		Type:  t,
+	}
+}
+
+// phpTypeName returns the name PHP would use for the type of a decoded value.
+func phpTypeName(value interface{}) string {
+	if n, ok := value.(Number); ok {
+		value = n.value()
+	}
+
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []interface{}, *orderedmap.OrderedMap[any, any]:
+		return "array"
+	case *Object:
+		return "object of class " + value.(*Object).ClassName
+	}
+
+	return reflect.TypeOf(value).String()
+}
+
+// coerceInt converts a decoded value into an integer. The second return value
+// is false if the conversion is not permitted by the mode.
+func coerceInt(value interface{}, mode CoercionMode) (int64, bool) {
+	if n, ok := value.(Number); ok {
+		value = n.value()
+	}
+
+	switch v := value.(type) {
+	case int64:
+		return v, true
+
+	case float64:
+		if mode == CoercePHP {
+			// PHP always casts NaN and Infinity to zero.
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return 0, true
+			}
+
+			if v >= -(1<<63) && v < 1<<63 {
+				return int64(v), true
+			}
+		}
+
+	case bool:
+		if mode == CoercePHP {
+			if v {
+				return 1, true
+			}
+
+			return 0, true
+		}
+
+	case string:
+		if mode == CoercePHP {
+			return phpStringToInt(v), true
+		}
+
+	case []interface{}, *orderedmap.OrderedMap[any, any]:
+		if mode == CoercePHP {
+			if phpArrayLen(v) == 0 {
+				return 0, true
+			}
+
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// coerceFloat converts a decoded value into a float. The second return value
+// is false if the conversion is not permitted by the mode.
+func coerceFloat(value interface{}, mode CoercionMode) (float64, bool) {
+	if n, ok := value.(Number); ok {
+		value = n.value()
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, true
+
+	case int64:
+		return float64(v), true
+
+	case string:
+		if mode == CoercePHP {
+			return phpStringToFloat(v), true
+		}
+
+	default:
+		if mode == CoercePHP {
+			i, ok := coerceInt(v, mode)
+			return float64(i), ok
+		}
+	}
+
+	return 0, false
+}
+
+// coerceBool converts a decoded value into a bool. The second return value is
+// false if the conversion is not permitted by the mode.
+func coerceBool(value interface{}, mode CoercionMode) (bool, bool) {
+	if n, ok := value.(Number); ok {
+		value = n.value()
+	}
+
+	if v, ok := value.(bool); ok {
+		return v, true
+	}
+
+	if mode != CoercePHP {
+		return false, false
+	}
+
+	switch v := value.(type) {
+	case int64:
+		return v != 0, true
+
+	case float64:
+		return v != 0, true
+
+	case string:
+		return v != "" && v != "0", true
+
+	case []interface{}, *orderedmap.OrderedMap[any, any]:
+		return phpArrayLen(v) != 0, true
+
+	case *Object:
+		return true, true
+	}
+
+	return false, false
+}
+
+// coerceString converts a decoded value into a string. The second return value
+// is false if the conversion is not permitted by the mode.
+func coerceString(value interface{}, mode CoercionMode) (string, bool) {
+	if n, ok := value.(Number); ok {
+		value = n.value()
+	}
+
+	if v, ok := value.(string); ok {
+		return v, true
+	}
+
+	if mode != CoercePHP {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+
+	case float64:
+		return phpFloatToString(v), true
+
+	case bool:
+		if v {
+			return "1", true
+		}
+
+		return "", true
+
+	case []interface{}, *orderedmap.OrderedMap[any, any]:
+		return "Array", true
+	}
+
+	return "", false
+}
+
+func phpArrayLen(value interface{}) int {
+	switch v := value.(type) {
+	case []interface{}:
+		return len(v)
+
+	case *orderedmap.OrderedMap[any, any]:
+		return v.Len()
+	}
+
+	return 0
+}
+
+// phpNumericPrefix returns the leading numeric part of a string, following the
+// same rules PHP uses when casting a string to a number. Leading whitespace is
+// ignored and anything after the number is discarded. The second return value
+// is true if the number contains a decimal point or an exponent.
+func phpNumericPrefix(s string) (string, bool) {
+	s = strings.TrimLeft(s, " \t\n\r\v\f")
+
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+
+	digits := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		digits++
+	}
+
+	isFloat := false
+	if i < len(s) && s[i] == '.' {
+		j := i + 1
+		for ; j < len(s) && s[j] >= '0' && s[j] <= '9'; j++ {
+			digits++
+		}
+
+		if digits > 0 {
+			i = j
+			isFloat = true
+		}
+	}
+
+	if digits == 0 {
+		return "", false
+	}
+
+	// The exponent is only part of the number if it has at least one digit.
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for ; j < len(s) && s[j] >= '0' && s[j] <= '9'; j++ {
+			}
+
+			i = j
+			isFloat = true
+		}
+	}
+
+	return s[:i], isFloat
+}
+
+// phpStringToInt is the equivalent of (int) "..." in PHP. Integers that are
+// too large are capped to the minimum or maximum value.
+func phpStringToInt(s string) int64 {
+	number, isFloat := phpNumericPrefix(s)
+	if number == "" {
+		return 0
+	}
+
+	if isFloat {
+		f, _ := strconv.ParseFloat(number, 64)
+		if math.IsNaN(f) || math.IsInf(f, 0) || f < -(1<<63) || f >= 1<<63 {
+			return 0
+		}
+
+		return int64(f)
+	}
+
+	// ParseInt returns the capped value along with a range error.
+	i, _ := strconv.ParseInt(number, 10, 64)
+
+	return i
+}
+
+// phpStringToFloat is the equivalent of (float) "..." in PHP.
+func phpStringToFloat(s string) float64 {
+	number, _ := phpNumericPrefix(s)
+	if number == "" {
+		return 0
+	}
+
+	f, _ := strconv.ParseFloat(number, 64)
+
+	return f
+}
+
+// phpFloatToString is the equivalent of (string) 1.23 in PHP, which uses 14
+// significant digits.
+func phpFloatToString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NAN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+
+	s := strconv.FormatFloat(f, 'G', 14, 64)
+
+	i := strings.IndexByte(s, 'E')
+	if i < 0 {
+		return s
+	}
+
+	// PHP always includes a decimal point in the mantissa and does not pad
+	// the exponent, for example 1.0E+25 and 1.5E-7.
+	mantissa, exponent := s[:i], s[i+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+
+	sign := exponent[0]
+	exponent = strings.TrimLeft(exponent[1:], "0")
+
+	return mantissa + "E" + string(sign) + exponent
+}
@@ -0,0 +1,237 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type juggled struct {
+	Int    int
+	Uint   uint8
+	Float  float64
+	Bool   bool
+	String string
+	Ints   []int
+}
+
+type stringTagged struct {
+	Int   int      `php:"int,string"`
+	Float float64  `php:"float,string"`
+	Bool  bool     `php:"bool,string"`
+	Ptr   *int64   `php:"ptr,string"`
+	Other []string `php:"other,string"`
+}
+
+func getPHPCoercion() *phpserialize.UnmarshalOptions {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.Coercion = phpserialize.CoercePHP
+
+	return options
+}
+
+func TestUnmarshalCoercePHP(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		output juggled
+	}{
+		"numeric strings": {
+			`O:7:"juggled":4:{s:3:"int";s:2:"42";s:4:"uint";s:3:" 7 ";s:5:"float";s:4:"1.5x";s:4:"bool";s:1:"0";}`,
+			juggled{Int: 42, Uint: 7, Float: 1.5},
+		},
+		"non-numeric strings": {
+			`O:7:"juggled":3:{s:3:"int";s:3:"abc";s:5:"float";s:0:"";s:4:"bool";s:3:"abc";}`,
+			juggled{Bool: true},
+		},
+		"exponent string to int": {
+			`O:7:"juggled":1:{s:3:"int";s:3:"1e3";}`,
+			juggled{Int: 1000},
+		},
+		"int to bool": {
+			`O:7:"juggled":1:{s:4:"bool";i:1;}`,
+			juggled{Bool: true},
+		},
+		"float to int": {
+			`O:7:"juggled":1:{s:3:"int";d:-3.9;}`,
+			juggled{Int: -3},
+		},
+		"bool to int": {
+			`O:7:"juggled":1:{s:3:"int";b:1;}`,
+			juggled{Int: 1},
+		},
+		"scalars to string": {
+			`O:7:"juggled":1:{s:6:"string";d:0.1;}`,
+			juggled{String: "0.1"},
+		},
+		"large float to string": {
+			`O:7:"juggled":1:{s:6:"string";d:1.0E+25;}`,
+			juggled{String: "1.0E+25"},
+		},
+		"true to string": {
+			`O:7:"juggled":1:{s:6:"string";b:1;}`,
+			juggled{String: "1"},
+		},
+		"array to string": {
+			`O:7:"juggled":1:{s:6:"string";a:0:{}}`,
+			juggled{String: "Array"},
+		},
+		"scalar to slice": {
+			`O:7:"juggled":1:{s:4:"ints";s:1:"5";}`,
+			juggled{Ints: []int{5}},
+		},
+		"associative array to slice": {
+			`O:7:"juggled":1:{s:4:"ints";a:2:{s:1:"a";i:1;s:1:"b";s:1:"2";}}`,
+			juggled{Ints: []int{1, 2}},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result juggled
+			err := phpserialize.UnmarshalWithOptions([]byte(test.input), &result,
+				getPHPCoercion())
+			expectErrorToNotHaveOccurred(t, err)
+
+			if !reflect.DeepEqual(result, test.output) {
+				t.Errorf("Expected %+v, got %+v", test.output, result)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCoerceStrict(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		field string
+		value string
+	}{
+		"string to int":  {`O:7:"juggled":1:{s:3:"int";s:2:"42";}`, "Int", "string"},
+		"int to bool":    {`O:7:"juggled":1:{s:4:"bool";i:1;}`, "Bool", "int"},
+		"float to int":   {`O:7:"juggled":1:{s:3:"int";d:1.5;}`, "Int", "float"},
+		"int overflow":   {`O:7:"juggled":1:{s:4:"uint";i:256;}`, "Uint", "int"},
+		"negative uint":  {`O:7:"juggled":1:{s:4:"uint";i:-1;}`, "Uint", "int"},
+		"int to string":  {`O:7:"juggled":1:{s:6:"string";i:1;}`, "String", "int"},
+		"scalar to list": {`O:7:"juggled":1:{s:4:"ints";i:1;}`, "Ints", "int"},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result juggled
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+
+			var typeErr *phpserialize.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("Expected *UnmarshalTypeError, got %v", err)
+			}
+
+			if typeErr.Field != test.field {
+				t.Errorf("Expected field %s, got %s", test.field, typeErr.Field)
+			}
+
+			if typeErr.Value != test.value {
+				t.Errorf("Expected value %s, got %s", test.value, typeErr.Value)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCoerceStrictAllowsWidening(t *testing.T) {
+	var result juggled
+	err := phpserialize.Unmarshal([]byte(`O:7:"juggled":2:{s:4:"uint";i:200;s:5:"float";i:3;}`), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Uint != 200 || result.Float != 3 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestUnmarshalCoerceStrictNestedFieldPath(t *testing.T) {
+	var result struct1
+	err := phpserialize.Unmarshal([]byte(`O:7:"struct1":1:{s:3:"bar";O:7:"Struct2":1:{s:3:"qux";s:1:"x";}}`), &result)
+
+	expected := "can not unmarshal PHP string into struct field Bar.Qux of type float64"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected '%s', got '%v'", expected, err)
+	}
+}
+
+func TestUnmarshalCoercePHPTopLevel(t *testing.T) {
+	var result int
+	err := phpserialize.UnmarshalWithOptions([]byte(`s:3:"-12";`), &result,
+		getPHPCoercion())
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result != -12 {
+		t.Errorf("Expected %v, got %v", -12, result)
+	}
+}
+
+func TestMarshalStringTagOption(t *testing.T) {
+	i := int64(-5)
+	input := stringTagged{42, 1.5, true, &i, []string{"a"}}
+	expected := `O:12:"stringTagged":5:{s:3:"int";s:2:"42";s:5:"float";s:3:"1.5";s:4:"bool";s:4:"true";s:3:"ptr";s:2:"-5";s:5:"other";a:1:{i:0;s:1:"a";}}`
+
+	result, err := phpserialize.Marshal(input, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var decoded stringTagged
+	err = phpserialize.Unmarshal(result, &decoded)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !reflect.DeepEqual(decoded, input) {
+		t.Errorf("Expected %+v, got %+v", input, decoded)
+	}
+}
+
+func TestStringTagOptionMaxUint64(t *testing.T) {
+	type uintTagged struct {
+		Uint uint64 `php:"uint,string"`
+		Byte uint8  `php:"byte,string"`
+	}
+
+	input := uintTagged{math.MaxUint64, math.MaxUint8}
+	expected := `O:10:"uintTagged":2:{s:4:"uint";s:20:"18446744073709551615";s:4:"byte";s:3:"255";}`
+
+	result, err := phpserialize.Marshal(input, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var decoded uintTagged
+	err = phpserialize.Unmarshal(result, &decoded)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if decoded != input {
+		t.Errorf("Expected %+v, got %+v", input, decoded)
+	}
+
+	// Negative numbers and values that overflow are rejected.
+	for _, data := range []string{
+		`O:10:"uintTagged":1:{s:4:"uint";s:2:"-1";}`,
+		`O:10:"uintTagged":1:{s:4:"byte";s:3:"256";}`,
+	} {
+		var typeErr *phpserialize.UnmarshalTypeError
+		if err := phpserialize.Unmarshal([]byte(data), &decoded); !errors.As(err, &typeErr) {
+			t.Errorf("Expected *UnmarshalTypeError for %s, got %v", data, err)
+		}
+	}
+}
+
+func TestUnmarshalStringTagOptionInvalid(t *testing.T) {
+	var result stringTagged
+	err := phpserialize.Unmarshal([]byte(`O:12:"stringTagged":1:{s:3:"int";s:3:"abc";}`), &result)
+
+	var typeErr *phpserialize.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field != "Int" {
+		t.Errorf("Expected *UnmarshalTypeError for Int, got %v", err)
+	}
+}
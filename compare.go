@@ -1,66 +1,410 @@
 package phpserialize
 
 import (
+	"cmp"
+	"encoding"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// numericalValue returns the float64 representation of a value if it is a
-// numerical type - integer, unsigned integer or float. If the value is not a
-// numerical type then the second argument is false and the value returned
-// should be disregarded.
-func numericalValue(value reflect.Value) (float64, bool) {
-	switch value.Type().Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
-		reflect.Int64:
-		return float64(value.Int()), true
+// KeyOrder is the order that the keys of a Go map are encoded in. Go
+// randomises the order of maps, so unless KeyOrderNone is used the keys are
+// sorted to always produce the same output.
+type KeyOrder int
 
-	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return float64(value.Uint()), true
+const (
+	// KeyOrderDefault puts null first, then bools (false before true), then
+	// numbers ordered by value and finally strings ordered byte by byte.
+	KeyOrderDefault KeyOrder = iota
+
+	// KeyOrderKsort orders keys in the same way as ksort() with SORT_REGULAR
+	// in PHP 8. Numbers and numeric strings are compared by value. A number
+	// and a non-numeric string are compared as strings.
+	KeyOrderKsort
+
+	// KeyOrderNatural orders keys in the same way as ksort() with
+	// SORT_NATURAL, so "img2" comes before "img10".
+	KeyOrderNatural
+
+	// KeyOrderNone does not sort the keys at all. This is the fastest, but
+	// the output of the same map can be different each time.
+	KeyOrderNone
+)
+
+// keyComparators compare map keys after they have been reduced by keyScalar.
+// Each one falls back to compareDefault for keys that are equal, so that the
+// order is always the same.
+var keyComparators = map[KeyOrder]func(a, b interface{}) int{
+	KeyOrderDefault: compareDefault,
+	KeyOrderKsort:   compareKsort,
+	KeyOrderNatural: compareNatural,
+}
+
+// sortEntries sorts the keys of a map, and their values, by the KeyOrder or
+// KeyCompare of the options.
+func sortEntries(keys, values []interface{}, options *MarshalOptions) error {
+	compare := options.KeyCompare
+
+	var sortKeys []interface{}
+
+	if compare == nil {
+		if options.KeyOrder == KeyOrderNone {
+			return nil
+		}
+
+		var ok bool
+		compare, ok = keyComparators[options.KeyOrder]
+		if !ok {
+			return fmt.Errorf("can not sort map keys with unknown KeyOrder %d", options.KeyOrder)
+		}
+
+		// Each key is only converted once, rather than every time it is
+		// compared.
+		sortKeys = make([]interface{}, len(keys))
+		for i, key := range keys {
+			scalar, err := keyScalar(key)
+			if err != nil {
+				return err
+			}
+
+			sortKeys[i] = scalar
+		}
+	}
+
+	if sortKeys == nil {
+		sortKeys = make([]interface{}, len(keys))
+		copy(sortKeys, keys)
+	}
+
+	sort.Stable(entries{sortKeys, keys, values, compare})
+
+	return nil
+}
+
+// entries sorts the keys and values of a map by sortKeys, which are either a
+// copy of the keys or the scalars they become.
+type entries struct {
+	sortKeys, keys, values []interface{}
+	compare                func(a, b interface{}) int
+}
+
+func (e entries) Len() int { return len(e.keys) }
+
+func (e entries) Less(i, j int) bool {
+	return e.compare(e.sortKeys[i], e.sortKeys[j]) < 0
+}
+
+func (e entries) Swap(i, j int) {
+	e.sortKeys[i], e.sortKeys[j] = e.sortKeys[j], e.sortKeys[i]
+	e.keys[i], e.keys[j] = e.keys[j], e.keys[i]
+	e.values[i], e.values[j] = e.values[j], e.values[i]
+}
+
+// keyScalar reduces a map key to the PHP scalar it is encoded as: nil, a bool,
+// an int64, a float64 or a string. Keys that implement encoding.TextMarshaler
+// are their text. Any other key, such as a struct, can not be sorted and
+// returns an error.
+func keyScalar(key interface{}) (interface{}, error) {
+	scalar, ok, err := mapKeyScalar(key)
+	if err == nil && !ok {
+		err = fmt.Errorf("can not sort map keys of type %s", reflect.TypeOf(key))
+	}
+
+	return scalar, err
+}
+
+// mapKeyScalar is keyScalar, except that a key that is not a scalar returns
+// false instead of an error.
+func mapKeyScalar(key interface{}) (interface{}, bool, error) {
+	switch k := key.(type) {
+	case nil, bool, int64, float64, string:
+		return k, true, nil
+
+	case int:
+		return int64(k), true, nil
+
+	case Number:
+		return k.value(), true, nil
+	}
+
+	v := reflect.ValueOf(key)
+	if v.Kind() == reflect.Ptr && !v.Type().Implements(textMarshalerType) {
+		// A pointer is encoded as the value it points to.
+		if v.IsNil() {
+			return nil, true, nil
+		}
+
+		return mapKeyScalar(v.Elem().Interface())
+	}
+
+	// The same as Marshal, a TextMarshaler with a pointer receiver is used
+	// through a copy of the key.
+	if v.Kind() != reflect.Ptr && v.Type().PkgPath() != "" && !v.Type().Implements(textMarshalerType) &&
+		reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v, key = p, p.Interface()
+	}
+
+	if v.Type().Implements(textMarshalerType) && !isSpecialType(v.Type()) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, true, nil
+		}
+
+		text, err := key.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, false, err
+		}
+
+		return string(text), true, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u > 1<<63-1 {
+			return float64(u), true, nil
+		}
+
+		return int64(v.Uint()), true, nil
 
 	case reflect.Float32, reflect.Float64:
-		return value.Float(), true
-
-	default:
-		return 0, false
-	}
-}
-
-// lessValue compares two reflect.Value instances and returns true if a is
-// considered to be less than b.
-//
-// This function is used to sort keys for what amounts to associate arrays and
-// objects in PHP. These are represented as slices and maps in Go. Since Go
-// randomised map iterators we need to make sure we always return the keys of an
-// associative array or object in a predicable order.
-//
-// The keys can be numerical, strings or a combination of both. We treat numbers
-// (integers, unsigned integers and floats) as always less than strings. Numbers
-// are ordered by magnitude (ignoring types) and strings are orders
-// lexicographically.
-//
-// If keys are of any other type the behavior of the comparison is undefined. If
-// there is a legitimate reason why keys could be other types then this function
-// should be updated accordingly.
-func lessValue(a, b reflect.Value) bool {
-	aValue, aNumerical := numericalValue(a)
-	bValue, bNumerical := numericalValue(b)
-
-	if aNumerical && bNumerical {
-		return aValue < bValue
-	}
-
-	if !aNumerical && !bNumerical {
-		// In theory this should mean they are both strings. In reality
-		// they could be any other type and the String() representation
-		// will be something like "<bool>" if it is not a string. Since
-		// distinct values of non-strings still return the same value
-		// here that's what makes the ordering undefined.
-		return strings.Compare(a.String(), b.String()) < 0
-	}
-
-	// Numerical values are always treated as less than other types
-	// (including strings that might represent numbers themselves). The
-	// inverse is also true.
-	return aNumerical && !bNumerical
+		return v.Float(), true, nil
+
+	case reflect.String:
+		return v.String(), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// scalarRank is the position of each type of scalar in the default order.
+func scalarRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, float64:
+		return 2
+	}
+
+	return 3
+}
+
+// compareDefault is the order of KeyOrderDefault. An integer and a float with
+// the same value are ordered by type so that they are never equal.
+func compareDefault(a, b interface{}) int {
+	if c := cmp.Compare(scalarRank(a), scalarRank(b)); c != 0 {
+		return c
+	}
+
+	switch a := a.(type) {
+	case bool:
+		return compareBools(a, b.(bool))
+
+	case string:
+		return strings.Compare(a, b.(string))
+
+	case int64, float64:
+		if c := compareNumbers(a, b); c != 0 {
+			return c
+		}
+
+		_, aIsFloat := a.(float64)
+		_, bIsFloat := b.(float64)
+
+		return compareBools(aIsFloat, bIsFloat)
+	}
+
+	return 0
+}
+
+// compareKsort is the order of KeyOrderKsort, which uses the rules of the <=>
+// operator in PHP 8.
+func compareKsort(a, b interface{}) int {
+	if c := phpCompare(a, b); c != 0 {
+		return c
+	}
+
+	return compareDefault(a, b)
+}
+
+func phpCompare(a, b interface{}) int {
+	aString, aIsString := a.(string)
+	bString, bIsString := b.(string)
+
+	switch {
+	case a == nil && b == nil:
+		return 0
+
+	// Null is the same as an empty string when compared to a string.
+	case a == nil && bIsString:
+		return strings.Compare("", bString)
+	case aIsString && b == nil:
+		return strings.Compare(aString, "")
+
+	// Otherwise null or a bool compares both sides as bools.
+	case a == nil, b == nil:
+		aBool, _ := coerceBool(a, CoercePHP)
+		bBool, _ := coerceBool(b, CoercePHP)
+
+		return compareBools(aBool, bBool)
+	}
+
+	if _, ok := a.(bool); ok {
+		aBool, _ := coerceBool(a, CoercePHP)
+		bBool, _ := coerceBool(b, CoercePHP)
+
+		return compareBools(aBool, bBool)
+	}
+
+	if _, ok := b.(bool); ok {
+		return -phpCompare(b, a)
+	}
+
+	switch {
+	case aIsString && bIsString:
+		aNumber, aNumeric := phpNumericString(aString)
+		bNumber, bNumeric := phpNumericString(bString)
+		if aNumeric && bNumeric {
+			return compareNumbers(aNumber, bNumber)
+		}
+
+		return strings.Compare(aString, bString)
+
+	case aIsString:
+		return -phpCompare(b, a)
+
+	case bIsString:
+		// A number is only compared to a string as a number if the string
+		// is numeric.
+		if bNumber, ok := phpNumericString(bString); ok {
+			return compareNumbers(a, bNumber)
+		}
+
+		aString, _ = coerceString(a, CoercePHP)
+
+		return strings.Compare(aString, bString)
+	}
+
+	return compareNumbers(a, b)
+}
+
+// compareNatural is the order of KeyOrderNatural. Every key is compared as a
+// string.
+func compareNatural(a, b interface{}) int {
+	aString, _ := coerceString(a, CoercePHP)
+	bString, _ := coerceString(b, CoercePHP)
+
+	if c := naturalCompare(aString, bString); c != 0 {
+		return c
+	}
+
+	return compareDefault(a, b)
+}
+
+// naturalCompare compares two strings in the same way as strnatcmp() in PHP.
+// Runs of digits are compared by their value and everything else is compared
+// byte by byte. Leading whitespace is ignored.
+func naturalCompare(a, b string) int {
+	a = strings.TrimLeft(a, " \t\n\r\v\f")
+	b = strings.TrimLeft(b, " \t\n\r\v\f")
+
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			aDigits, bDigits := digitRun(a), digitRun(b)
+			a, b = a[len(aDigits):], b[len(bDigits):]
+
+			// Leading zeros do not change the value of a run.
+			aValue := strings.TrimLeft(aDigits, "0")
+			bValue := strings.TrimLeft(bDigits, "0")
+			if c := cmp.Compare(len(aValue), len(bValue)); c != 0 {
+				return c
+			}
+
+			if c := strings.Compare(aValue, bValue); c != 0 {
+				return c
+			}
+
+			continue
+		}
+
+		if c := cmp.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+
+		a, b = a[1:], b[1:]
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func digitRun(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	return s[:i]
+}
+
+// phpNumericString returns the int64 or float64 value of a string if PHP
+// considers the whole string to be numeric. Whitespace is allowed before and
+// after the number.
+func phpNumericString(s string) (interface{}, bool) {
+	trimmed := strings.TrimLeft(s, " \t\n\r\v\f")
+
+	number, isFloat := phpNumericPrefix(trimmed)
+	if number == "" || strings.TrimRight(trimmed[len(number):], " \t\n\r\v\f") != "" {
+		return nil, false
+	}
+
+	if !isFloat {
+		if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return i, true
+		}
+	}
+
+	f, _ := strconv.ParseFloat(number, 64)
+
+	return f, true
+}
+
+// compareNumbers compares two int64 or float64 values. Two integers are
+// compared exactly, rather than as floats.
+func compareNumbers(a, b interface{}) int {
+	aInt, aIsInt := a.(int64)
+	bInt, bIsInt := b.(int64)
+	if aIsInt && bIsInt {
+		return cmp.Compare(aInt, bInt)
+	}
+
+	aFloat, _ := coerceFloat(a, CoercePHP)
+	bFloat, _ := coerceFloat(b, CoercePHP)
+
+	return cmp.Compare(aFloat, bFloat)
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+
+	return -1
 }
@@ -0,0 +1,134 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+func TestMarshalKeyOrder(t *testing.T) {
+	tests := map[string]struct {
+		order  phpserialize.KeyOrder
+		input  interface{}
+		output string
+	}{
+		"default": {
+			phpserialize.KeyOrderDefault,
+			map[interface{}]int{"b": 1, 10: 2, "10": 3, 2.5: 4, "a": 5},
+			`a:5:{d:2.5;i:4;i:10;i:2;s:2:"10";i:3;s:1:"a";i:5;s:1:"b";i:1;}`,
+		},
+		"default bools": {
+			phpserialize.KeyOrderDefault,
+			map[bool]int{true: 1, false: 0},
+			`a:2:{b:0;i:0;b:1;i:1;}`,
+		},
+		"ksort": {
+			phpserialize.KeyOrderKsort,
+			map[interface{}]int{"b": 1, 10: 2, "9": 3, "a": 4, 2.5: 5},
+			`a:5:{d:2.5;i:5;s:1:"9";i:3;i:10;i:2;s:1:"a";i:4;s:1:"b";i:1;}`,
+		},
+		"ksort numeric strings": {
+			phpserialize.KeyOrderKsort,
+			map[string]int{"10": 1, "9": 2, "1e1": 3, "x": 4},
+			`a:4:{s:1:"9";i:2;s:2:"10";i:1;s:3:"1e1";i:3;s:1:"x";i:4;}`,
+		},
+		"natural": {
+			phpserialize.KeyOrderNatural,
+			map[string]int{"img10": 1, "img2": 2, "img1": 3, "IMG3": 4},
+			`a:4:{s:4:"IMG3";i:4;s:4:"img1";i:3;s:4:"img2";i:2;s:5:"img10";i:1;}`,
+		},
+		"natural numbers": {
+			phpserialize.KeyOrderNatural,
+			map[interface{}]int{"10a": 1, 9: 2, "009": 3},
+			`a:3:{i:9;i:2;s:3:"009";i:3;s:3:"10a";i:1;}`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			options := phpserialize.DefaultMarshalOptions()
+			options.KeyOrder = test.order
+
+			result, err := phpserialize.Marshal(test.input, options)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.output {
+				t.Errorf("Expected '%s', got '%s'", test.output, result)
+			}
+		})
+	}
+}
+
+func TestMarshalKeyOrderNone(t *testing.T) {
+	options := phpserialize.DefaultMarshalOptions()
+	options.KeyOrder = phpserialize.KeyOrderNone
+
+	// Any order is allowed, so only the contents can be checked.
+	result, err := phpserialize.Marshal(map[string]int{"a": 1, "b": 2}, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != `a:2:{s:1:"a";i:1;s:1:"b";i:2;}` && string(result) != `a:2:{s:1:"b";i:2;s:1:"a";i:1;}` {
+		t.Errorf("Unexpected result '%s'", result)
+	}
+
+	// Keys that can not be sorted are allowed when they are not sorted.
+	result, err = phpserialize.Marshal(map[Circle]int{{}: 1}, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !strings.HasPrefix(string(result), `a:1:{O:6:"Circle"`) {
+		t.Errorf("Unexpected result '%s'", result)
+	}
+}
+
+func TestMarshalKeyCompare(t *testing.T) {
+	options := phpserialize.DefaultMarshalOptions()
+	options.KeyCompare = func(a, b interface{}) int {
+		// Longest first.
+		return len(b.(string)) - len(a.(string))
+	}
+
+	result, err := phpserialize.Marshal(map[string]int{"a": 1, "ccc": 3, "bb": 2}, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `a:3:{s:3:"ccc";i:3;s:2:"bb";i:2;s:1:"a";i:1;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	// The comparator is given the keys after they are normalized.
+	options.NormalizeKeys = true
+	options.KeyCompare = func(a, b interface{}) int {
+		_, aIsInt := a.(int64)
+		_, bIsInt := b.(int64)
+
+		switch {
+		case aIsInt == bIsInt:
+			return 0
+		case aIsInt:
+			return 1
+		}
+
+		return -1
+	}
+
+	result, err = phpserialize.Marshal(map[string]int{"1": 1, "x": 2}, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected = `a:2:{s:1:"x";i:2;i:1;i:1;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestMarshalKeyOrderErrors(t *testing.T) {
+	_, err := phpserialize.Marshal(map[Circle]int{{}: 1, {Radius: 1}: 2}, nil)
+	expectErrorToEqual(t, err, errors.New("can not sort map keys of type phpserialize_test.Circle"))
+
+	options := phpserialize.DefaultMarshalOptions()
+	options.KeyOrder = phpserialize.KeyOrder(99)
+
+	_, err = phpserialize.Marshal(map[string]int{"a": 1}, options)
+	expectErrorToEqual(t, err, errors.New("can not sort map keys with unknown KeyOrder 99"))
+}
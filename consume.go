@@ -2,8 +2,10 @@ package phpserialize
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/elliotchance/orderedmap/v3"
 )
@@ -18,13 +20,21 @@ import (
 // should not be used to capture anything other that ASCII data that is
 // terminated by a single byte.
 func consumeStringUntilByte(data []byte, lookingFor byte, offset int) (s string, newOffset int) {
-	newOffset = findByte(data, lookingFor, offset)
+	b, newOffset := consumeBytesUntilByte(data, lookingFor, offset)
+
+	return string(b), newOffset
+}
+
+// consumeBytesUntilByte works the same way as consumeStringUntilByte but
+// returns the bytes without copying them. It is used to parse numbers without
+// allocating a string for them first.
+func consumeBytesUntilByte(data []byte, lookingFor byte, offset int) ([]byte, int) {
+	newOffset := findByte(data, lookingFor, offset)
 	if newOffset < 0 {
-		return "", -1
+		return nil, -1
 	}
 
-	s = string(data[offset:newOffset])
-	return
+	return data[offset:newOffset], newOffset
 }
 
 func consumeInt(data []byte, offset int) (int64, int, error) {
@@ -32,8 +42,8 @@ func consumeInt(data []byte, offset int) (int64, int, error) {
 		return 0, -1, errors.New("not an integer")
 	}
 
-	alphaNumber, newOffset := consumeStringUntilByte(data, ';', offset+2)
-	i, err := strconv.Atoi(alphaNumber)
+	alphaNumber, newOffset := consumeBytesUntilByte(data, ';', offset+2)
+	i, err := strconv.Atoi(bytesToString(alphaNumber))
 	if err != nil {
 		return 0, -1, err
 	}
@@ -47,8 +57,8 @@ func consumeFloat(data []byte, offset int) (float64, int, error) {
 		return 0, -1, errors.New("not a float")
 	}
 
-	alphaNumber, newOffset := consumeStringUntilByte(data, ';', offset+2)
-	v, err := strconv.ParseFloat(alphaNumber, 64)
+	alphaNumber, newOffset := consumeBytesUntilByte(data, ';', offset+2)
+	v, err := strconv.ParseFloat(bytesToString(alphaNumber), 64)
 	if err != nil {
 		return 0, -1, err
 	}
@@ -68,8 +78,8 @@ func consumeString(data []byte, offset int) (string, int, error) {
 // This is used in many places to describe the number of elements or an upcoming
 // length.
 func consumeIntPart(data []byte, offset int) (int, int, error) {
-	rawValue, newOffset := consumeStringUntilByte(data, ':', offset)
-	value, err := strconv.Atoi(rawValue)
+	rawValue, newOffset := consumeBytesUntilByte(data, ':', offset)
+	value, err := strconv.Atoi(bytesToString(rawValue))
 	if err != nil {
 		return 0, -1, err
 	}
@@ -79,20 +89,48 @@ func consumeIntPart(data []byte, offset int) (int, int, error) {
 }
 
 func consumeStringRealPart(data []byte, offset int) (string, int, error) {
-	length, newOffset, err := consumeIntPart(data, offset)
+	raw, offset, err := consumeStringRealBytes(data, offset)
 	if err != nil {
 		return "", -1, err
 	}
 
+	return DecodePHPString(raw), offset, nil
+}
+
+// consumeStringRealBytes returns the bytes of a string before they have been
+// decoded with DecodePHPString.
+func consumeStringRealBytes(data []byte, offset int) ([]byte, int, error) {
+	length, newOffset, err := consumeIntPart(data, offset)
+	if err != nil {
+		return nil, -1, err
+	}
+
 	// Skip over the '"' at the start of the string. I'm not sure why they
 	// decided to wrap the string in double quotes since it's totally
 	// redundant.
 	offset = newOffset + 1
 
-	s := DecodePHPString(data[offset : length+offset])
+	if length < 0 || offset+length+2 > len(data) {
+		return nil, -1, fmt.Errorf("can not decode string with length %d", length)
+	}
 
 	// The +2 is to skip over the final '";'
-	return s, offset + length + 2, nil
+	return data[offset : offset+length], offset + length + 2, nil
+}
+
+// consumeString reads a string in the same way as the consumeString function,
+// but without copying it when the ZeroCopy option is used.
+func (d *decoder) consumeString(data []byte, offset int) (string, int, error) {
+	if !checkType(data, 's', offset) {
+		return "", -1, errors.New("not a string")
+	}
+
+	raw, offset, err := consumeStringRealBytes(data, offset+2)
+	if err != nil {
+		return "", -1, err
+	}
+
+	return d.decodeString(raw), offset, nil
 }
 
 func consumeNil(data []byte, offset int) (interface{}, int, error) {
@@ -111,19 +149,100 @@ func consumeBool(data []byte, offset int) (bool, int, error) {
 	return data[offset+2] == '1', offset + 4, nil
 }
 
-func consumeObjectAsMap(data []byte, offset int) (
+// decoder holds the options and state used while consuming a serialized value.
+type decoder struct {
+	options *UnmarshalOptions
+
+	// keepObjects consumes PHP objects as *Object instead of an OrderedMap so
+	// that their class name is still available when filling in a struct.
+	// They are converted back to an OrderedMap (see plainValue) if they end
+	// up in an interface{} and DecodeObjects is false.
+	keepObjects bool
+
+	// numbers consumes integers and floats as a Number so that their text is
+	// kept. See hasNumberType.
+	numbers bool
+
+	// refs holds the values that r: and R: can refer to. It is nil if the
+	// data does not contain any, which is found out when the first value
+	// is consumed.
+	refs        *references
+	refsChecked bool
+
+	// cycles allows r: and R: to refer to an object that is still being
+	// decoded, so that the value contains itself. This is only possible
+	// if the value is not converted by setField into a type that would
+	// have to contain itself as well.
+	cycles bool
+}
+
+func newDecoder(options *UnmarshalOptions, keepObjects bool) *decoder {
+	return &decoder{
+		options:     options,
+		keepObjects: keepObjects || options.DecodeObjects,
+		numbers:     options.UseNumber,
+		cycles:      true,
+	}
+}
+
+// newTypedDecoder creates a decoder for a value that will be assigned to type
+// t with setField.
+func newTypedDecoder(options *UnmarshalOptions, t reflect.Type) *decoder {
+	d := newDecoder(options, true)
+	d.numbers = d.numbers || hasNumberType(t)
+	d.cycles = t.Kind() == reflect.Interface && t.NumMethod() == 0
+
+	return d
+}
+
+// consumeObjectAsMap returns the properties of an object. The class name is
+// discarded.
+func (d *decoder) consumeObjectAsMap(data []byte, offset int) (
 	*orderedmap.OrderedMap[any, any], int, error) {
+	o, offset, err := d.consumeObjectWithClass(data, offset)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return o.Properties, offset, nil
+}
+
+// consumeObjectValue returns an object as either an *Object or an OrderedMap,
+// depending on keepObjects.
+func (d *decoder) consumeObjectValue(data []byte, offset int) (interface{}, int, error) {
+	o, offset, err := d.consumeObjectWithClass(data, offset)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	if d.keepObjects {
+		return o, offset, nil
+	}
+
+	return o.Properties, offset, nil
+}
+
+func (d *decoder) consumeObjectWithClass(data []byte, offset int) (*Object, int, error) {
 	result := orderedmap.NewOrderedMap[any, any]()
+	o := &Object{Properties: result}
+
+	if d.keepObjects {
+		d.startObject(o)
+	} else {
+		d.startObject(result)
+	}
 
 	// Read the class name. The class name follows the same format as a
 	// string. We could just ignore the length and hope that no class name
 	// ever had a non-ascii characters in it, but this is safer - and
 	// probably easier.
-	_, offset, err := consumeStringRealPart(data, offset+2)
+	rawClassName, offset, err := consumeStringRealBytes(data, offset+2)
 	if err != nil {
 		return nil, -1, err
 	}
 
+	o.ClassName = d.decodeString(rawClassName)
+
 	// Read the number of elements in the object.
 	length, offset, err := consumeIntPart(data, offset)
 	if err != nil {
@@ -140,38 +259,27 @@ func consumeObjectAsMap(data []byte, offset int) (
 
 		// The key should always be a string. I am not completely sure
 		// about this.
-		key, offset, err = consumeString(data, offset)
+		key, offset, err = d.consumeString(data, offset)
 		if err != nil {
 			return nil, -1, err
 		}
 
-		// If the next item is an object we can't simply consume it,
-		// rather we send the reflect.Value back through consumeObject
-		// so the recursion can be handled correctly.
-		if data[offset] == 'O' {
-			var subMap interface{}
-
-			subMap, offset, err = consumeObjectAsMap(data, offset)
-			if err != nil {
-				return nil, -1, err
-			}
-
-			result.Set(key, subMap)
-		} else {
-			value, offset, err = consumeNext(data, offset)
-			if err != nil {
-				return nil, -1, err
-			}
-
-			result.Set(key, value)
+		value, offset, err = d.consumeNext(data, offset)
+		if err != nil {
+			return nil, -1, err
 		}
+
+		result.Set(key, value)
 	}
 
 	// The +1 is for the final '}'
-	return result, offset + 1, nil
+	return o, offset + 1, nil
 }
 
-func setField(structFieldValue reflect.Value, value interface{}) error {
+// setField converts a decoded value into the type of structFieldValue and
+// assigns it. A nil value leaves structFieldValue unchanged. If the conversion
+// is not possible an *UnmarshalTypeError is returned.
+func setField(structFieldValue reflect.Value, value interface{}, options *UnmarshalOptions) error {
 	if !structFieldValue.IsValid() {
 		return nil
 	}
@@ -182,132 +290,651 @@ func setField(structFieldValue reflect.Value, value interface{}) error {
 		return nil
 	}
 
-	switch structFieldValue.Type().Kind() {
+	t := structFieldValue.Type()
+
+	// A reference is decoded in the same way as the value that it refers
+	// to, unless it is kept in an interface{}.
+	if ref, ok := value.(*Reference); ok && !(t.Kind() == reflect.Interface && t.NumMethod() == 0) {
+		return setField(structFieldValue, ref.Value, options)
+	}
+
+	// A string can be decoded by any type that implements
+	// encoding.TextUnmarshaler, unless it is handled specially.
+	if ok, err := setText(structFieldValue, value); ok {
+		return err
+	}
+
+	switch t.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		structFieldValue.SetInt(val.Int())
+		i, ok := coerceInt(value, options.Coercion)
+		if !ok || structFieldValue.OverflowInt(i) {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		structFieldValue.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Only a string field is parsed as a uint64, which may not fit in
+		// an int64.
+		if u, ok := value.(uint64); ok {
+			if structFieldValue.OverflowUint(u) {
+				return newUnmarshalTypeError(value, t)
+			}
+
+			structFieldValue.SetUint(u)
+			break
+		}
 
-	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		structFieldValue.SetUint(val.Uint())
+		i, ok := coerceInt(value, options.Coercion)
+		if !ok || i < 0 || structFieldValue.OverflowUint(uint64(i)) {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		structFieldValue.SetUint(uint64(i))
 
 	case reflect.Float32, reflect.Float64:
-		structFieldValue.SetFloat(val.Float())
+		f, ok := coerceFloat(value, options.Coercion)
+		if !ok {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		structFieldValue.SetFloat(f)
+
+	case reflect.Bool:
+		b, ok := coerceBool(value, options.Coercion)
+		if !ok {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		structFieldValue.SetBool(b)
+
+	case reflect.String:
+		if t == numberType {
+			return setNumber(structFieldValue, value, options)
+		}
+
+		s, ok := coerceString(value, options.Coercion)
+		if !ok {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		structFieldValue.SetString(s)
 
 	case reflect.Struct:
-		m := val.Interface().(*orderedmap.OrderedMap[any, any])
-		fillStruct(structFieldValue, m)
+		switch t {
+		case timeType:
+			return setTime(structFieldValue, value, timeFormat{}, options)
 
-	case reflect.Slice:
-		l := val.Len()
-		arrayOfObjects := reflect.MakeSlice(structFieldValue.Type(), l, l)
+		case dateIntervalType:
+			return setDateInterval(structFieldValue, value, options)
 
-		for i := 0; i < l; i++ {
-			if m, ok := val.Index(i).Interface().(*orderedmap.OrderedMap[any, any]); ok {
-				obj := arrayOfObjects.Index(i)
-				fillStruct(obj, m)
-			} else {
-				switch arrayOfObjects.Index(i).Kind() {
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					arrayOfObjects.Index(i).SetInt(val.Index(i).Elem().Int())
-				case reflect.Float32, reflect.Float64:
-					arrayOfObjects.Index(i).SetFloat(val.Index(i).Elem().Float())
-				default:
-					arrayOfObjects.Index(i).Set(val.Index(i).Elem())
-				}
+		case bigIntType, bigFloatType, bigRatType:
+			return setBigNumber(structFieldValue, value, options)
+		}
 
-			}
+		switch v := value.(type) {
+		case *Object:
+			return fillStruct(structFieldValue, v.Properties, options)
+
+		case *orderedmap.OrderedMap[any, any]:
+			return fillStruct(structFieldValue, v, options)
 		}
 
-		structFieldValue.Set(arrayOfObjects)
+		return newUnmarshalTypeError(value, t)
+
+	case reflect.Slice:
+		return setSlice(structFieldValue, value, options)
+
+	case reflect.Map:
+		return setMap(structFieldValue, value, options)
+
 	case reflect.Ptr:
+		if t == orderedMapType {
+			return setOrderedMap(structFieldValue, value, options)
+		}
+
 		// Instantiate structFieldValue.
-		structFieldValue.Set(reflect.New(structFieldValue.Type().Elem()))
-		return setField(structFieldValue.Elem(), value)
+		structFieldValue.Set(reflect.New(t.Elem()))
+		return setField(structFieldValue.Elem(), value, options)
+
+	case reflect.Interface:
+		return setInterface(structFieldValue, value, options)
+
 	default:
+		if !val.Type().AssignableTo(t) {
+			return newUnmarshalTypeError(value, t)
+		}
+
 		structFieldValue.Set(val)
 	}
 
 	return nil
 }
 
+func setSlice(structFieldValue reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	t := structFieldValue.Type()
+
+	var values []interface{}
+	if o, ok := value.(*Object); ok && options.Coercion == CoercePHP {
+		// Casting an object to an array in PHP returns its properties.
+		value = o.Properties
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+
+	case *orderedmap.OrderedMap[any, any]:
+		// An array that is not zero-indexed can only become a slice by
+		// discarding its keys.
+		if options.Coercion != CoercePHP {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		for item := range v.Values() {
+			values = append(values, item)
+		}
+
+	default:
+		// uint8 is an alias for byte. This means we are trying to pull
+		// a binary string out.
+		if s, ok := value.(string); ok && t.Elem().Kind() == reflect.Uint8 {
+			if options.ZeroCopy {
+				structFieldValue.SetBytes(stringToBytes(s))
+			} else {
+				structFieldValue.SetBytes([]byte(s))
+			}
+
+			return nil
+		}
+
+		// Casting a scalar to an array in PHP wraps the value.
+		if options.Coercion != CoercePHP {
+			return newUnmarshalTypeError(value, t)
+		}
+
+		values = []interface{}{value}
+	}
+
+	l := len(values)
+	arrayOfObjects := reflect.MakeSlice(t, l, l)
+
+	for i := 0; i < l; i++ {
+		if err := setField(arrayOfObjects.Index(i), values[i], options); err != nil {
+			return err
+		}
+	}
+
+	structFieldValue.Set(arrayOfObjects)
+
+	return nil
+}
+
+func setMap(structFieldValue reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	t := structFieldValue.Type()
+	result := reflect.MakeMap(t)
+
+	setEntry := func(key, item interface{}) error {
+		k := reflect.New(t.Key()).Elem()
+		if err := setField(k, key, options); err != nil {
+			return err
+		}
+
+		v := reflect.New(t.Elem()).Elem()
+		if err := setField(v, item, options); err != nil {
+			return err
+		}
+
+		result.SetMapIndex(k, v)
+
+		return nil
+	}
+
+	// The properties of an object can be decoded into a map.
+	if o, ok := value.(*Object); ok {
+		value = o.Properties
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			if err := setEntry(int64(i), item); err != nil {
+				return err
+			}
+		}
+
+	case *orderedmap.OrderedMap[any, any]:
+		for key, item := range v.AllFromFront() {
+			if err := setEntry(key, item); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return newUnmarshalTypeError(value, t)
+	}
+
+	structFieldValue.Set(result)
+
+	return nil
+}
+
+// setOrderedMap decodes an array or the properties of an object into an
+// *orderedmap.OrderedMap[any, any]. An indexed array uses its indexes as keys.
+func setOrderedMap(structFieldValue reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	var m *orderedmap.OrderedMap[any, any]
+
+	switch v := plainValue(value, options).(type) {
+	case *orderedmap.OrderedMap[any, any]:
+		m = v
+
+	case *Object:
+		m = v.Properties
+
+	case []interface{}:
+		m = orderedmap.NewOrderedMapWithCapacity[any, any](len(v))
+		for i, item := range v {
+			m.Set(int64(i), item)
+		}
+
+	default:
+		return newUnmarshalTypeError(value, structFieldValue.Type())
+	}
+
+	structFieldValue.Set(reflect.ValueOf(m))
+
+	return nil
+}
+
+// setStringField decodes a field with the "string" tag option. The PHP value
+// is expected to be a string that contains the Go value, which is how
+// MarshalStruct encodes these fields.
+func setStringField(structFieldValue reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	s, ok := value.(string)
+	if !ok {
+		return setField(structFieldValue, value, options)
+	}
+
+	t := structFieldValue.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err = strconv.ParseInt(s, 10, 64)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err = strconv.ParseUint(s, 10, 64)
+
+	case reflect.Float32, reflect.Float64:
+		parsed, err = strconv.ParseFloat(s, 64)
+
+	case reflect.Bool:
+		if s == "" {
+			parsed = false
+		} else {
+			parsed, err = strconv.ParseBool(s)
+		}
+
+	default:
+		return setField(structFieldValue, value, options)
+	}
+
+	if err != nil {
+		return newUnmarshalTypeError(value, structFieldValue.Type())
+	}
+
+	return setField(structFieldValue, parsed, options)
+}
+
 // https://stackoverflow.com/questions/26744873/converting-map-to-struct
-func fillStruct(obj reflect.Value, m *orderedmap.OrderedMap[any, any]) error {
-	tt := obj.Type()
-	for i := 0; i < obj.NumField(); i++ {
-		field := obj.Field(i)
-		if !field.CanSet() {
+func fillStruct(obj reflect.Value, m *orderedmap.OrderedMap[any, any], options *UnmarshalOptions) error {
+	fields := cachedTypeFields(obj.Type(), options.FieldNamer)
+	properties := newPropertyLookup(m, options.CaseInsensitive,
+		fields.rest != nil || options.DisallowUnknownFields)
+
+	// A ClassResolver only applies to the fields of its own struct, so it
+	// must not be passed down to nested structs.
+	resolver := classResolverFor(obj)
+	if resolver == nil && options.resolveClass != nil {
+		fieldOptions := *options
+		fieldOptions.resolveClass = nil
+		options = &fieldOptions
+	}
+
+	for i := range fields.list {
+		f := &fields.list[i]
+
+		v, ok := properties.get(f)
+		if !ok {
 			continue
 		}
-		var key string
-		if tag := tt.Field(i).Tag.Get("php"); tag == "-" {
+
+		field, ok := fieldByIndex(obj, f.index, true)
+		if !ok || !field.CanSet() {
+			// The field belongs to an unexported embedded struct
+			// pointer that can not be allocated.
 			continue
-		} else if tag != "" {
-			key = tag
-		} else {
-			key = lowerCaseFirstLetter(tt.Field(i).Name)
 		}
-		if v, ok := m.Get(key); ok {
-			setField(field, v)
+
+		fieldOptions := options
+		if resolver != nil {
+			fieldOptions = withClassResolver(options, resolver, f.goName)
+		}
+
+		if err := f.decode(field, v, fieldOptions); err != nil {
+			return withFieldName(err, f.goName)
+		}
+	}
+
+	return fillRest(obj, fields, &properties, options)
+}
+
+// withClassResolver returns a copy of the options that resolves class names for
+// a single field with resolver.
+func withClassResolver(options *UnmarshalOptions, resolver ClassResolver, fieldName string) *UnmarshalOptions {
+	fieldOptions := *options
+	fieldOptions.resolveClass = func(className string) interface{} {
+		return resolver.ResolvePHPClass(fieldName, className)
+	}
+
+	return &fieldOptions
+}
+
+// propertyLookup finds the properties of a PHP object that belong to struct
+// fields. It can also remember which properties have been used so that the
+// remaining ones can be treated as unknown.
+type propertyLookup struct {
+	m               *orderedmap.OrderedMap[any, any]
+	caseInsensitive bool
+
+	// folded maps the lowercase version of each property name to the
+	// first property with that name. It is only created if needed.
+	folded map[string]string
+
+	// used is nil if the properties are not being tracked.
+	used map[any]bool
+}
+
+func newPropertyLookup(m *orderedmap.OrderedMap[any, any], caseInsensitive, track bool) propertyLookup {
+	l := propertyLookup{
+		m:               m,
+		caseInsensitive: caseInsensitive,
+	}
+
+	if track {
+		l.used = map[any]bool{}
+	}
+
+	return l
+}
+
+// get returns the property for a field. The property with the same name as the
+// field is preferred, followed by each of its aliases in order. Only if none of
+// those exist will the names be compared case-insensitively.
+func (l *propertyLookup) get(f *field) (interface{}, bool) {
+	if v, ok := l.getExact(f.name); ok {
+		return v, ok
+	}
+
+	for _, alias := range f.aliases {
+		if v, ok := l.getExact(alias); ok {
+			return v, ok
+		}
+	}
+
+	if !l.caseInsensitive {
+		return nil, false
+	}
+
+	if l.folded == nil {
+		l.folded = map[string]string{}
+		for key := range l.m.Keys() {
+			if name, ok := key.(string); ok {
+				lower := strings.ToLower(name)
+				if _, ok := l.folded[lower]; !ok {
+					l.folded[lower] = name
+				}
+			}
+		}
+	}
+
+	if key, ok := l.folded[strings.ToLower(f.name)]; ok {
+		return l.getExact(key)
+	}
+
+	for _, alias := range f.aliases {
+		if key, ok := l.folded[strings.ToLower(alias)]; ok {
+			return l.getExact(key)
+		}
+	}
+
+	return nil, false
+}
+
+func (l *propertyLookup) getExact(name string) (interface{}, bool) {
+	v, ok := l.m.Get(name)
+	if ok && l.used != nil {
+		l.used[name] = true
+	}
+
+	return v, ok
+}
+
+// fillRest puts any properties that were not used by a field into the "rest"
+// field of the struct. If there is no "rest" field and DisallowUnknownFields is
+// enabled an error is returned for the first unknown property.
+func fillRest(obj reflect.Value, fields *structFields, properties *propertyLookup, options *UnmarshalOptions) error {
+	if fields.rest == nil && !options.DisallowUnknownFields {
+		return nil
+	}
+
+	rest := orderedmap.NewOrderedMap[any, any]()
+	for key, v := range properties.m.AllFromFront() {
+		if properties.used[key] {
+			continue
+		}
+
+		if fields.rest == nil {
+			return fmt.Errorf("unknown property %v for type %s", key, obj.Type())
 		}
+
+		rest.Set(key, plainValue(v, options))
+	}
+
+	if fields.rest == nil {
+		return nil
+	}
+
+	field, ok := fieldByIndex(obj, fields.rest.index, true)
+	if ok && field.CanSet() {
+		field.Set(reflect.ValueOf(rest))
 	}
 
 	return nil
 }
 
-func consumeObject(data []byte, offset int, v reflect.Value) (int, error) {
+// withFieldName adds the name of the struct field to the path of an
+// *UnmarshalTypeError.
+func withFieldName(err error, name string) error {
+	if typeErr, ok := err.(*UnmarshalTypeError); ok {
+		if typeErr.Field == "" {
+			typeErr.Field = name
+		} else {
+			typeErr.Field = name + "." + typeErr.Field
+		}
+	}
+
+	return err
+}
+
+func consumeObject(data []byte, offset int, v reflect.Value, options *UnmarshalOptions) (int, error) {
 	if !checkType(data, 'O', offset) {
 		return -1, errors.New("not an object")
 	}
 
-	m, offset, err := consumeObjectAsMap(data, offset)
+	d := newTypedDecoder(options, v.Type())
+	d.startValue(data, offset)
+
+	o, offset, err := d.consumeObjectWithClass(data, offset)
 	if err != nil {
 		return -1, err
 	}
 
-	return offset, fillStruct(v, m)
+	return offset, fillStruct(v, o.Properties, options)
+}
+
+func (d *decoder) consumeNext(data []byte, offset int) (interface{}, int, error) {
+	if offset >= len(data) {
+		return nil, -1, errors.New("corrupt")
+	}
+
+	if !d.refsChecked {
+		d.initReferences(data, offset, false)
+	}
+
+	if d.refs == nil {
+		return d.consumeValue(data, offset)
+	}
+
+	if data[offset] == 'r' || data[offset] == 'R' {
+		return d.consumeReference(data, offset)
+	}
+
+	id := d.startValue(data, offset)
+	if ref := d.refs.targets[id]; ref != nil && d.cycles {
+		d.refs.values[id-1].value = ref
+	}
+
+	value, offset, err := d.consumeValue(data, offset)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return d.finishValue(id, value), offset, nil
 }
 
-func consumeNext(data []byte, offset int) (interface{}, int, error) {
+// consumeValue reads the value at offset without numbering it for r: and R:.
+func (d *decoder) consumeValue(data []byte, offset int) (interface{}, int, error) {
 	if offset >= len(data) {
 		return nil, -1, errors.New("corrupt")
 	}
 
 	switch data[offset] {
 	case 'a':
-		return consumeIndexedOrAssociativeArray(data, offset)
+		return d.consumeIndexedOrAssociativeArray(data, offset)
 	case 'b':
 		return consumeBool(data, offset)
 	case 'd':
+		if d.numbers {
+			return consumeNumber(data, offset)
+		}
+
 		return consumeFloat(data, offset)
 	case 'i':
+		if d.numbers {
+			return consumeNumber(data, offset)
+		}
+
 		return consumeInt(data, offset)
 	case 's':
-		return consumeString(data, offset)
+		return d.consumeString(data, offset)
 	case 'N':
 		return consumeNil(data, offset)
 	case 'O':
-		return consumeObjectAsMap(data, offset)
+		return d.consumeObjectValue(data, offset)
 	}
 
 	return nil, -1, errors.New("can not consume type: " +
 		string(data[offset:]))
 }
 
-func consumeIndexedOrAssociativeArray(data []byte, offset int) (interface{}, int, error) {
-	// Sometimes we don't know if the array is going to be indexed or
-	// associative until we have already started to consume it.
-	originalOffset := offset
+// consumeKey reads the key of an array element. Integer keys are never a
+// Number, and keys are not numbered for r: and R:.
+func (d *decoder) consumeKey(data []byte, offset int) (interface{}, int, error) {
+	if checkType(data, 'i', offset) {
+		return consumeInt(data, offset)
+	}
 
-	// Try to consume it as an indexed array first.
-	arr, offset, err := consumeIndexedArray(data, originalOffset)
-	if err == nil {
-		return arr, offset, err
+	return d.consumeValue(data, offset)
+}
+
+// consumeIndexedOrAssociativeArray reads an array in a single pass. It is
+// read as a slice for as long as its keys are 0, 1, 2 and so on. The first key
+// that is out of sequence moves the values read so far into an ordered map,
+// which the rest of the array is added to.
+func (d *decoder) consumeIndexedOrAssociativeArray(data []byte, offset int) (interface{}, int, error) {
+	if !checkType(data, 'a', offset) {
+		return nil, -1, errors.New("not an array")
 	}
 
-	// Fallback to consuming an associative array
-	return consumeAssociativeArray(data, originalOffset)
+	rawLength, offset := consumeBytesUntilByte(data, ':', offset+2)
+	length, err := strconv.Atoi(bytesToString(rawLength))
+	if err != nil {
+		return nil, -1, err
+	}
+
+	if length < 0 {
+		return nil, -1, fmt.Errorf("can not decode array with length %d", length)
+	}
+
+	// Skip over the ":{"
+	offset += 2
+
+	// Every element takes at least 4 bytes, so a length that is larger than
+	// the data can not be trusted to size the slice.
+	list := make([]interface{}, 0, max(min(length, (len(data)-offset)/4), 0))
+	var assoc *orderedmap.OrderedMap[any, any]
+
+	for i := 0; i < length; i++ {
+		var key interface{}
+
+		key, offset, err = d.consumeKey(data, offset)
+		if err != nil {
+			return nil, -1, err
+		}
+
+		if assoc == nil {
+			if index, ok := key.(int64); !ok || index != int64(i) {
+				assoc = orderedmap.NewOrderedMap[any, any]()
+				for j, val := range list {
+					assoc.Set(int64(j), val)
+				}
+			}
+		}
+
+		if assoc != nil && d.options.NormalizeKeys {
+			if key, err = normalizeKey(key); err != nil {
+				return nil, -1, err
+			}
+		}
+
+		var val interface{}
+		val, offset, err = d.consumeNext(data, offset)
+		if err != nil {
+			return nil, -1, err
+		}
+
+		if assoc != nil {
+			assoc.Set(key, val)
+		} else {
+			list = append(list, val)
+		}
+	}
+
+	// The +1 is for the final '}'
+	if assoc != nil {
+		return assoc, offset + 1, nil
+	}
+
+	return list, offset + 1, nil
 }
 
-func consumeAssociativeArray(data []byte, offset int) (*orderedmap.OrderedMap[any, any], int, error) {
+func (d *decoder) consumeAssociativeArray(data []byte, offset int) (*orderedmap.OrderedMap[any, any], int, error) {
 	if !checkType(data, 'a', offset) {
 		return orderedmap.NewOrderedMap[any, any](), -1, errors.New("not an array")
 	}
@@ -315,8 +942,8 @@ func consumeAssociativeArray(data []byte, offset int) (*orderedmap.OrderedMap[an
 	// Skip over the "a:"
 	offset += 2
 
-	rawLength, offset := consumeStringUntilByte(data, ':', offset)
-	length, err := strconv.Atoi(rawLength)
+	rawLength, offset := consumeBytesUntilByte(data, ':', offset)
+	length, err := strconv.Atoi(bytesToString(rawLength))
 	if err != nil {
 		return orderedmap.NewOrderedMap[any, any](), -1, err
 	}
@@ -328,13 +955,19 @@ func consumeAssociativeArray(data []byte, offset int) (*orderedmap.OrderedMap[an
 	for i := 0; i < length; i++ {
 		var key interface{}
 
-		key, offset, err = consumeNext(data, offset)
+		key, offset, err = d.consumeKey(data, offset)
 		if err != nil {
 			return orderedmap.NewOrderedMap[any, any](), -1, err
 		}
 
+		if d.options.NormalizeKeys {
+			if key, err = normalizeKey(key); err != nil {
+				return orderedmap.NewOrderedMap[any, any](), -1, err
+			}
+		}
+
 		var val any
-		val, offset, err = consumeNext(data, offset)
+		val, offset, err = d.consumeNext(data, offset)
 		if err != nil {
 			return orderedmap.NewOrderedMap[any, any](), -1, err
 		}
@@ -345,13 +978,13 @@ func consumeAssociativeArray(data []byte, offset int) (*orderedmap.OrderedMap[an
 	return result, offset + 1, nil
 }
 
-func consumeIndexedArray(data []byte, offset int) ([]interface{}, int, error) {
+func (d *decoder) consumeIndexedArray(data []byte, offset int) ([]interface{}, int, error) {
 	if !checkType(data, 'a', offset) {
 		return []interface{}{}, -1, errors.New("not an array")
 	}
 
-	rawLength, offset := consumeStringUntilByte(data, ':', offset+2)
-	length, err := strconv.Atoi(rawLength)
+	rawLength, offset := consumeBytesUntilByte(data, ':', offset+2)
+	length, err := strconv.Atoi(bytesToString(rawLength))
 	if err != nil {
 		return []interface{}{}, -1, err
 	}
@@ -377,7 +1010,7 @@ func consumeIndexedArray(data []byte, offset int) ([]interface{}, int, error) {
 		}
 
 		// Now we consume the value
-		result[i], offset, err = consumeNext(data, offset)
+		result[i], offset, err = d.consumeNext(data, offset)
 		if err != nil {
 			return []interface{}{}, -1, err
 		}
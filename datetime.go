@@ -0,0 +1,359 @@
+package phpserialize
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// dateLayout is the format PHP uses for the "date" property of a DateTime.
+const dateLayout = "2006-01-02 15:04:05.000000"
+
+// These are the values of the "timezone_type" property of a PHP DateTime.
+const (
+	// timezoneOffset is a UTC offset, such as "+05:00".
+	timezoneOffset = 1
+
+	// timezoneAbbreviation is an abbreviation, such as "EST".
+	timezoneAbbreviation = 2
+
+	// timezoneIdentifier is a timezone database name, such as
+	// "Europe/London" or "UTC".
+	timezoneIdentifier = 3
+)
+
+// timezoneAbbreviations are the offsets of the abbreviations that are commonly
+// found in a DateTime with a timezone_type of 2. Go can not look up the offset
+// of an abbreviation by itself.
+var timezoneAbbreviations = map[string]int{
+	"UTC":  0,
+	"GMT":  0,
+	"Z":    0,
+	"WET":  0,
+	"WEST": 1 * 3600,
+	"BST":  1 * 3600,
+	"IST":  1 * 3600,
+	"CET":  1 * 3600,
+	"CEST": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"MSK":  3 * 3600,
+	"JST":  9 * 3600,
+	"KST":  9 * 3600,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+	"NZST": 12 * 3600,
+	"NZDT": 13 * 3600,
+	"AST":  -4 * 3600,
+	"ADT":  -3 * 3600,
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"AKST": -9 * 3600,
+	"AKDT": -8 * 3600,
+	"HST":  -10 * 3600,
+}
+
+// timeFormat describes how a time.Time is encoded. It comes from the tag
+// options of a struct field:
+//
+//	Created time.Time `php:"created"`                    // DateTime object
+//	Updated time.Time `php:"updated,immutable"`          // DateTimeImmutable object
+//	Expires time.Time `php:"expires,unix"`               // Unix timestamp
+//	Birth   time.Time `php:"birth,format=2006-01-02"`    // formatted string
+type timeFormat struct {
+	unix      bool
+	layout    string
+	immutable bool
+}
+
+func newTimeFormat(options tagOptions) timeFormat {
+	layout, _ := options.Get("format")
+
+	return timeFormat{
+		unix:      options.Contains("unix"),
+		layout:    layout,
+		immutable: options.Contains("immutable"),
+	}
+}
+
+// MarshalTime returns the bytes that represent a PHP DateTime object with the
+// same date, time and timezone as t. The OnlyStdClass option is respected.
+//
+// The timezone_type of the object depends on the location of t. UTC and
+// locations loaded from the timezone database (like "Europe/London") are
+// identifiers, fixed zones with a name (like "EST") are abbreviations and
+// anything else (including time.Local) is an offset from UTC.
+func MarshalTime(t time.Time, options *MarshalOptions) []byte {
+	return marshalTime(t, timeFormat{}, options)
+}
+
+func marshalTime(t time.Time, format timeFormat, options *MarshalOptions) []byte {
+	if format.unix {
+		return MarshalInt(t.Unix())
+	}
+
+	if format.layout != "" {
+		return MarshalString(t.Format(format.layout))
+	}
+
+	if options == nil {
+		options = DefaultMarshalOptions()
+	}
+
+	className := "DateTime"
+	if format.immutable {
+		className = "DateTimeImmutable"
+	}
+	if options.OnlyStdClass {
+		className = "stdClass"
+	}
+
+	timezoneType, timezone := phpTimezone(t)
+
+	var buffer bytes.Buffer
+	buffer.Write(MarshalString("date"))
+	buffer.Write(MarshalString(t.Format(dateLayout)))
+	buffer.Write(MarshalString("timezone_type"))
+	buffer.Write(MarshalInt(int64(timezoneType)))
+	buffer.Write(MarshalString("timezone"))
+	buffer.Write(MarshalString(timezone))
+
+	return []byte(fmt.Sprintf("O:%d:\"%s\":3:{%s}", len(className), className,
+		buffer.String()))
+}
+
+// phpTimezone returns the timezone_type and timezone properties for the
+// location of t.
+func phpTimezone(t time.Time) (int, string) {
+	name := t.Location().String()
+	switch {
+	case name == "UTC" || strings.Contains(name, "/"):
+		return timezoneIdentifier, name
+
+	case name != "" && name != "Local":
+		if zone, _ := t.Zone(); zone == name {
+			return timezoneAbbreviation, name
+		}
+	}
+
+	return timezoneOffset, t.Format("-07:00")
+}
+
+// setTime decodes a value into a time.Time. Without a format the value must be
+// a PHP DateTime or DateTimeImmutable object, or any object with the same
+// properties (such as a Carbon instance).
+func setTime(v reflect.Value, value interface{}, format timeFormat, options *UnmarshalOptions) error {
+	if value == nil {
+		return nil
+	}
+
+	var t time.Time
+	var err error
+
+	switch {
+	case format.unix:
+		if n, ok := value.(Number); ok {
+			value = n.value()
+		}
+
+		switch timestamp := value.(type) {
+		case int64:
+			t = time.Unix(timestamp, 0).UTC()
+
+		case float64:
+			seconds := int64(timestamp)
+			t = time.Unix(seconds, int64((timestamp-float64(seconds))*1e9)).UTC()
+
+		default:
+			return newUnmarshalTypeError(value, v.Type())
+		}
+
+	case format.layout != "":
+		s, ok := coerceString(value, options.Coercion)
+		if !ok {
+			return newUnmarshalTypeError(value, v.Type())
+		}
+
+		t, err = time.Parse(format.layout, s)
+
+	default:
+		t, err = parseDateTime(value, v.Type())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.ValueOf(t))
+
+	return nil
+}
+
+// parseDateTime reads the properties of a PHP DateTime object.
+func parseDateTime(value interface{}, t reflect.Type) (time.Time, error) {
+	var properties *orderedmap.OrderedMap[any, any]
+	switch v := value.(type) {
+	case *Object:
+		properties = v.Properties
+
+	case *orderedmap.OrderedMap[any, any]:
+		properties = v
+
+	default:
+		return time.Time{}, newUnmarshalTypeError(value, t)
+	}
+
+	date, ok1 := properties.Get("date")
+	timezoneType, ok2 := properties.Get("timezone_type")
+	timezone, ok3 := properties.Get("timezone")
+	if !ok1 || !ok2 || !ok3 {
+		return time.Time{}, newUnmarshalTypeError(value, t)
+	}
+
+	dateString, ok1 := date.(string)
+	timezoneTypeInt, ok2 := coerceInt(timezoneType, CoerceStrict)
+	timezoneString, ok3 := timezone.(string)
+	if !ok1 || !ok2 || !ok3 {
+		return time.Time{}, newUnmarshalTypeError(value, t)
+	}
+
+	location, err := phpLocation(int(timezoneTypeInt), timezoneString)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The fraction of a second is optional when parsing.
+	result, err := time.ParseInLocation("2006-01-02 15:04:05", dateString, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("can not unmarshal DateTime: %w", err)
+	}
+
+	return result, nil
+}
+
+// phpLocation returns the location for the timezone_type and timezone
+// properties of a PHP DateTime.
+func phpLocation(timezoneType int, timezone string) (*time.Location, error) {
+	switch timezoneType {
+	case timezoneOffset:
+		t, err := time.Parse("-07:00", timezone)
+		if err != nil {
+			return nil, fmt.Errorf("can not unmarshal DateTime timezone: %s", timezone)
+		}
+
+		_, offset := t.Zone()
+
+		return time.FixedZone("", offset), nil
+
+	case timezoneAbbreviation:
+		offset, ok := timezoneAbbreviations[strings.ToUpper(timezone)]
+		if !ok {
+			return nil, fmt.Errorf("can not unmarshal DateTime timezone: %s", timezone)
+		}
+
+		return time.FixedZone(timezone, offset), nil
+
+	case timezoneIdentifier:
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("can not unmarshal DateTime timezone: %s", timezone)
+		}
+
+		return location, nil
+	}
+
+	return nil, fmt.Errorf("can not unmarshal DateTime timezone_type: %d", timezoneType)
+}
+
+// newTimeEncoder returns the field encoder for a time.Time or *time.Time.
+func newTimeEncoder(t reflect.Type, options tagOptions) fieldEncoder {
+	format := newTimeFormat(options)
+
+	return func(dst []byte, v reflect.Value, options *MarshalOptions) ([]byte, error) {
+		if t.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return AppendNil(dst), nil
+			}
+
+			v = v.Elem()
+		}
+
+		return append(dst, marshalTime(v.Interface().(time.Time), format, options)...), nil
+	}
+}
+
+// newTimeDecoder returns the field decoder for a time.Time or *time.Time.
+func newTimeDecoder(t reflect.Type, options tagOptions) fieldDecoder {
+	format := newTimeFormat(options)
+
+	return func(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+		if value == nil {
+			return nil
+		}
+
+		if t.Kind() == reflect.Ptr {
+			v.Set(reflect.New(timeType))
+			v = v.Elem()
+		}
+
+		return setTime(v, value, format, options)
+	}
+}
+
+// DateTimeZone is a PHP DateTimeZone. Use Location to get the *time.Location
+// it represents.
+type DateTimeZone struct {
+	// TimezoneType is 1 for a UTC offset (like "+05:00"), 2 for an
+	// abbreviation (like "EST") or 3 for an identifier (like "Europe/London").
+	TimezoneType int `php:"timezone_type"`
+
+	Timezone string `php:"timezone"`
+}
+
+// NewDateTimeZone creates a DateTimeZone for a location. The timezone_type is
+// chosen in the same way as MarshalTime.
+func NewDateTimeZone(location *time.Location) DateTimeZone {
+	timezoneType, timezone := phpTimezone(time.Now().In(location))
+
+	return DateTimeZone{
+		TimezoneType: timezoneType,
+		Timezone:     timezone,
+	}
+}
+
+// Location returns the location of the timezone. An abbreviation can only be
+// converted if it is one of the commonly used abbreviations.
+func (z DateTimeZone) Location() (*time.Location, error) {
+	return phpLocation(z.TimezoneType, z.Timezone)
+}
+
+// DatePeriod is a PHP DatePeriod, which is a set of dates between Start and End
+// that are Interval apart. The Start, Current and End dates are always encoded
+// as DateTime objects, even if they were a DateTimeImmutable.
+type DatePeriod struct {
+	Start    time.Time     `php:"start"`
+	Current  *time.Time    `php:"current"`
+	End      *time.Time    `php:"end"`
+	Interval *DateInterval `php:"interval"`
+
+	// Recurrences is the number of dates after the start date, if End is
+	// nil.
+	Recurrences int `php:"recurrences"`
+
+	IncludeStartDate bool `php:"include_start_date"`
+
+	// IncludeEndDate was added in PHP 8.2.
+	IncludeEndDate bool `php:"include_end_date"`
+}
@@ -0,0 +1,181 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type event struct {
+	Start    time.Time  `php:"start"`
+	Updated  time.Time  `php:"updated,immutable"`
+	Expires  time.Time  `php:"expires,unix"`
+	Birthday time.Time  `php:"birthday,format=2006-01-02"`
+	Deleted  *time.Time `php:"deleted"`
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location
+}
+
+func TestMarshalTime(t *testing.T) {
+	london := mustLoadLocation(t, "Europe/London")
+
+	tests := map[string]struct {
+		input  time.Time
+		output string
+	}{
+		"UTC": {
+			time.Date(2021, 3, 4, 5, 6, 7, 890000000, time.UTC),
+			`O:8:"DateTime":3:{s:4:"date";s:26:"2021-03-04 05:06:07.890000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`,
+		},
+		"identifier": {
+			time.Date(2021, 7, 1, 12, 0, 0, 0, london),
+			`O:8:"DateTime":3:{s:4:"date";s:26:"2021-07-01 12:00:00.000000";s:13:"timezone_type";i:3;s:8:"timezone";s:13:"Europe/London";}`,
+		},
+		"abbreviation": {
+			time.Date(2021, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*3600)),
+			`O:8:"DateTime":3:{s:4:"date";s:26:"2021-01-02 03:04:05.000000";s:13:"timezone_type";i:2;s:8:"timezone";s:3:"EST";}`,
+		},
+		"offset": {
+			time.Date(2021, 1, 2, 3, 4, 5, 0, time.FixedZone("", 5*3600+1800)),
+			`O:8:"DateTime":3:{s:4:"date";s:26:"2021-01-02 03:04:05.000000";s:13:"timezone_type";i:1;s:8:"timezone";s:6:"+05:30";}`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := phpserialize.Marshal(test.input, nil)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.output {
+				t.Errorf("Expected '%s', got '%s'", test.output, result)
+			}
+
+			var decoded time.Time
+			err = phpserialize.Unmarshal(result, &decoded)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if !decoded.Equal(test.input) {
+				t.Errorf("Expected %v, got %v", test.input, decoded)
+			}
+
+			if decoded.Location().String() != test.input.Location().String() {
+				t.Errorf("Expected location %v, got %v", test.input.Location(), decoded.Location())
+			}
+		})
+	}
+}
+
+func TestMarshalTimeTagOptions(t *testing.T) {
+	day := time.Date(2000, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	result, err := phpserialize.Marshal(event{
+		Start:    day,
+		Updated:  day,
+		Expires:  time.Unix(1700000000, 0),
+		Birthday: day,
+	}, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `O:5:"event":5:{` +
+		`s:5:"start";O:8:"DateTime":3:{s:4:"date";s:26:"2000-12-31 00:00:00.000000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}` +
+		`s:7:"updated";O:17:"DateTimeImmutable":3:{s:4:"date";s:26:"2000-12-31 00:00:00.000000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}` +
+		`s:7:"expires";i:1700000000;` +
+		`s:8:"birthday";s:10:"2000-12-31";` +
+		`s:7:"deleted";N;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var decoded event
+	err = phpserialize.Unmarshal(result, &decoded)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !decoded.Start.Equal(day) || !decoded.Updated.Equal(day) || !decoded.Birthday.Equal(day) {
+		t.Errorf("Expected all dates to be %v, got %+v", day, decoded)
+	}
+
+	if decoded.Expires.Unix() != 1700000000 {
+		t.Errorf("Expected expires to be 1700000000, got %d", decoded.Expires.Unix())
+	}
+
+	if decoded.Deleted != nil {
+		t.Errorf("Expected deleted to be nil, got %v", decoded.Deleted)
+	}
+}
+
+func TestUnmarshalTime(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected time.Time
+	}{
+		"no fraction": {
+			`O:8:"DateTime":3:{s:4:"date";s:19:"2021-03-04 05:06:07";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`,
+			time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+		"negative offset": {
+			`O:8:"DateTime":3:{s:4:"date";s:26:"2021-03-04 05:06:07.000000";s:13:"timezone_type";i:1;s:8:"timezone";s:6:"-03:00";}`,
+			time.Date(2021, 3, 4, 8, 6, 7, 0, time.UTC),
+		},
+		"daylight saving abbreviation": {
+			`O:8:"DateTime":3:{s:4:"date";s:26:"2021-07-04 05:06:07.000000";s:13:"timezone_type";i:2;s:8:"timezone";s:3:"EDT";}`,
+			time.Date(2021, 7, 4, 9, 6, 7, 0, time.UTC),
+		},
+		"subclass": {
+			`O:13:"Carbon\Carbon":3:{s:4:"date";s:26:"2021-03-04 05:06:07.000000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`,
+			time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result time.Time
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if !result.Equal(test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTimeErrors(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedError error
+	}{
+		"not an object": {
+			`O:5:"event":1:{s:5:"start";s:10:"2000-12-31";}`,
+			errors.New("can not unmarshal PHP string into struct field Start of type time.Time"),
+		},
+		"missing property": {
+			`O:5:"event":1:{s:5:"start";O:8:"DateTime":1:{s:4:"date";s:19:"2021-03-04 05:06:07";}}`,
+			errors.New("can not unmarshal PHP object of class DateTime into struct field Start of type time.Time"),
+		},
+		"unknown abbreviation": {
+			`O:5:"event":1:{s:5:"start";O:8:"DateTime":3:{s:4:"date";s:19:"2021-03-04 05:06:07";s:13:"timezone_type";i:2;s:8:"timezone";s:3:"XYZ";}}`,
+			errors.New("can not unmarshal DateTime timezone: XYZ"),
+		},
+		"unix string": {
+			`O:5:"event":1:{s:7:"expires";s:3:"123";}`,
+			errors.New("can not unmarshal PHP string into struct field Expires of type time.Time"),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result event
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+			expectErrorToEqual(t, err, test.expectedError)
+		})
+	}
+}
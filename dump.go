@@ -0,0 +1,787 @@
+package phpserialize
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// VarDump writes value to w in the same way as var_dump() in PHP. The value is
+// usually one that has been decoded into an interface{}, preferably with the
+// DecodeObjects and KeepReferences options so that class names and references
+// are shown. Any other Go value is shown as it would be after encoding it with
+// Marshal.
+//
+// Object handles (the #1 in "object(Foo)#1") are numbered in the order that
+// the objects first appear, as they would be in a PHP script that had just
+// unserialized the data.
+func VarDump(w io.Writer, value interface{}) error {
+	return dump(w, value, func(d *dumper, value interface{}) []byte {
+		return d.appendVarDump(nil, value, 1)
+	})
+}
+
+// PrintR writes value to w in the same way as print_r() in PHP. See VarDump.
+func PrintR(w io.Writer, value interface{}) error {
+	return dump(w, value, func(d *dumper, value interface{}) []byte {
+		return d.appendPrintR(nil, value, 0)
+	})
+}
+
+// DebugZvalDump writes value to w in the same way as debug_zval_dump() in PHP.
+// See VarDump.
+//
+// The reference counts are those that PHP would show if the value had just
+// been unserialized into a variable that was then passed to debug_zval_dump().
+func DebugZvalDump(w io.Writer, value interface{}) error {
+	return dump(w, value, func(d *dumper, value interface{}) []byte {
+		return d.appendDebugZvalDump(nil, value, 1)
+	})
+}
+
+// Formatter returns a fmt.Formatter for value, so that it can be logged in the
+// same way as PHP would show it. The %v and %s verbs use PrintR, %+v uses
+// VarDump and %#v uses DebugZvalDump. *Object and *Reference already
+// implement fmt.Formatter in this way, except that %#v prints them as Go
+// syntax like any other value.
+func Formatter(value interface{}) fmt.Formatter {
+	return formatter{value}
+}
+
+type formatter struct {
+	value interface{}
+}
+
+func (f formatter) Format(s fmt.State, verb rune) {
+	formatValue(s, verb, f.value)
+}
+
+// Format implements fmt.Formatter. See Formatter.
+func (o *Object) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('#') {
+		type object Object
+		formatGoSyntax(s, (*object)(o), "Object")
+		return
+	}
+
+	formatValue(s, verb, o)
+}
+
+// Format implements fmt.Formatter. See Formatter.
+func (r *Reference) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('#') {
+		type reference Reference
+		formatGoSyntax(s, (*reference)(r), "Reference")
+		return
+	}
+
+	formatValue(s, verb, r)
+}
+
+// formatGoSyntax writes value, which is a pointer to a copy of the type called
+// name without its methods, in the same way as %#v would write the type itself
+// if it did not implement fmt.Formatter.
+func formatGoSyntax(s fmt.State, value interface{}, name string) {
+	text := fmt.Sprintf("%#v", value)
+	_, _ = io.WriteString(s, strings.Replace(text, reflect.TypeOf(value).Elem().String(), "phpserialize."+name, 1))
+}
+
+func formatValue(s fmt.State, verb rune, value interface{}) {
+	var err error
+	switch {
+	case verb != 'v' && verb != 's':
+		_, err = fmt.Fprintf(s, "%%!%c(%T)", verb, value)
+	case verb == 'v' && s.Flag('+'):
+		err = VarDump(s, value)
+	case verb == 'v' && s.Flag('#'):
+		err = DebugZvalDump(s, value)
+	default:
+		err = PrintR(s, value)
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(s, "%%!%c(PHP=%v)", verb, err)
+	}
+}
+
+func dump(w io.Writer, value interface{}, appendValue func(d *dumper, value interface{}) []byte) error {
+	value, err := newValueTree(nil).convert(value)
+	if err != nil {
+		return err
+	}
+
+	d := newDumper(value)
+
+	// PHP passes the value itself, not the reference that holds it.
+	if ref, ok := value.(*Reference); ok {
+		value = ref.Value
+	}
+
+	_, err = w.Write(appendValue(d, value))
+
+	return err
+}
+
+// valueTree converts a value into the types that are decoded into an
+// interface{}: nil, bool, int64, float64, string, []interface{},
+// *orderedmap.OrderedMap[any, any], *Object and *Reference. Values that are
+// shared (the same pointer) are still shared after they are converted. Any
+// other Go value is encoded with the options first.
+type valueTree struct {
+	options   *MarshalOptions
+	converted map[interface{}]interface{}
+}
+
+func newValueTree(options *MarshalOptions) *valueTree {
+	return &valueTree{options: options, converted: map[interface{}]interface{}{}}
+}
+
+func (t *valueTree) convert(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, int64, float64, string:
+		return value, nil
+
+	case Number:
+		return v.value(), nil
+
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if result[i], err = t.convert(item); err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+
+	case *orderedmap.OrderedMap[any, any]:
+		if v == nil {
+			return nil, nil
+		}
+
+		if result, ok := t.converted[v]; ok {
+			return result, nil
+		}
+
+		result := orderedmap.NewOrderedMap[any, any]()
+		t.converted[v] = result
+
+		return result, t.convertMap(result, v)
+
+	case *Object:
+		if v == nil {
+			return nil, nil
+		}
+
+		if result, ok := t.converted[v]; ok {
+			return result, nil
+		}
+
+		result := NewObject(v.ClassName)
+		t.converted[v] = result
+
+		if v.Properties == nil {
+			return result, nil
+		}
+
+		return result, t.convertMap(result.Properties, v.Properties)
+
+	case *Reference:
+		if v == nil {
+			return nil, nil
+		}
+
+		if result, ok := t.converted[v]; ok {
+			return result, nil
+		}
+
+		result := &Reference{}
+		t.converted[v] = result
+
+		var err error
+		result.Value, err = t.convert(v.Value)
+
+		return result, err
+	}
+
+	// Anything else is shown as PHP would see it after it has been encoded.
+	data, err := Marshal(value, t.options)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	options := &UnmarshalOptions{DecodeObjects: true, KeepReferences: true}
+	if err := NewReader(data, options).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return t.convert(result)
+}
+
+func (t *valueTree) convertMap(dst, src *orderedmap.OrderedMap[any, any]) error {
+	for key, value := range src.AllFromFront() {
+		converted, err := t.convert(value)
+		if err != nil {
+			return err
+		}
+
+		dst.Set(dumpKey(key), converted)
+	}
+
+	return nil
+}
+
+// dumpKey returns the int64 or string that PHP would use for an array key.
+func dumpKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case int64, string:
+		return key
+
+	case Number:
+		if i, err := k.Int64(); err == nil {
+			return i
+		}
+	}
+
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	}
+
+	return fmt.Sprint(key)
+}
+
+// dumper writes a value that has been converted by valueTree.
+type dumper struct {
+	// refcounts is the number of times that each *Object, *Reference and
+	// OrderedMap appears.
+	refcounts map[interface{}]int
+
+	// handles are the numbers of objects, in the order that they appear.
+	handles map[*Object]int
+
+	// visiting holds the arrays and objects that are being written, so that
+	// they are not written inside themselves.
+	visiting map[interface{}]bool
+}
+
+func newDumper(value interface{}) *dumper {
+	d := &dumper{
+		refcounts: map[interface{}]int{},
+		handles:   map[*Object]int{},
+		visiting:  map[interface{}]bool{},
+	}
+
+	d.count(value)
+
+	// The variable that holds the value is also counted.
+	switch value.(type) {
+	case *Object, *orderedmap.OrderedMap[any, any]:
+		d.refcounts[value]++
+	}
+
+	return d
+}
+
+func (d *dumper) count(value interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			d.count(item)
+		}
+
+	case *orderedmap.OrderedMap[any, any]:
+		if d.refcounts[v]++; d.refcounts[v] == 1 {
+			for _, item := range v.AllFromFront() {
+				d.count(item)
+			}
+		}
+
+	case *Object:
+		if d.refcounts[v]++; d.refcounts[v] == 1 {
+			d.handles[v] = len(d.handles) + 1
+			d.count(v.Properties)
+		}
+
+	case *Reference:
+		if d.refcounts[v]++; d.refcounts[v] == 1 {
+			d.count(v.Value)
+		}
+	}
+}
+
+// refcount returns the number of times that an array or object appears. A
+// []interface{} is never shared.
+func (d *dumper) refcount(value interface{}) int {
+	if _, ok := value.([]interface{}); ok {
+		return 1
+	}
+
+	return d.refcounts[value]
+}
+
+// recursive returns true if an array or object is already being written.
+func (d *dumper) recursive(value interface{}) bool {
+	return d.visiting[containerKey(value)]
+}
+
+func (d *dumper) setVisiting(value interface{}, visiting bool) {
+	key := containerKey(value)
+	switch {
+	case key == nil:
+	case visiting:
+		d.visiting[key] = true
+	default:
+		delete(d.visiting, key)
+	}
+}
+
+// containerKey returns a map key for an array or object. A []interface{} is
+// identified by its first element, because it can only contain itself through
+// a *Reference to the value that holds it. The key of an empty array is nil.
+func containerKey(value interface{}) interface{} {
+	if v, ok := value.([]interface{}); ok {
+		if len(v) == 0 {
+			return nil
+		}
+
+		return &v[0]
+	}
+
+	return value
+}
+
+// dumpEntry is an element of an array, or a property of an object.
+type dumpEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+func dumpEntries(value interface{}) []dumpEntry {
+	var entries []dumpEntry
+
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			entries = append(entries, dumpEntry{int64(i), item})
+		}
+
+	case *orderedmap.OrderedMap[any, any]:
+		for key, item := range v.AllFromFront() {
+			entries = append(entries, dumpEntry{key, item})
+		}
+
+	case *Object:
+		return dumpEntries(v.Properties)
+	}
+
+	return entries
+}
+
+// unmangleProperty splits the name of a private ("\0Class\0name") or
+// protected ("\0*\0name") property. The class is "*" for a protected property,
+// and ok is false if the name is not mangled.
+func unmangleProperty(key string) (name, class string, ok bool) {
+	if len(key) < 3 || key[0] != 0 {
+		return key, "", false
+	}
+
+	end := strings.IndexByte(key[1:], 0)
+	if end < 1 {
+		return key, "", false
+	}
+
+	return key[end+2:], key[1 : end+1], true
+}
+
+// appendDumpKey appends the key of an array element or object property in
+// the form that var_dump() and debug_zval_dump() use.
+func appendDumpKey(dst []byte, key interface{}, isObject bool, level int) []byte {
+	dst = appendSpaces(dst, level+1)
+
+	s, ok := key.(string)
+	if !ok {
+		dst = append(dst, '[')
+		dst = strconv.AppendInt(dst, key.(int64), 10)
+		return append(dst, "]=>\n"...)
+	}
+
+	name, class, mangled := unmangleProperty(s)
+	switch {
+	case !isObject || !mangled:
+		dst = append(dst, `["`...)
+		dst = append(dst, s...)
+		dst = append(dst, `"]`...)
+
+	case class == "*":
+		dst = append(dst, `["`...)
+		dst = append(dst, name...)
+		dst = append(dst, `":protected]`...)
+
+	default:
+		dst = append(dst, `["`...)
+		dst = append(dst, name...)
+		dst = append(dst, `":"`...)
+		dst = append(dst, class...)
+		dst = append(dst, `":private]`...)
+	}
+
+	return append(dst, "=>\n"...)
+}
+
+func (d *dumper) appendVarDump(dst []byte, value interface{}, level int) []byte {
+	if level > 1 {
+		dst = appendSpaces(dst, level-1)
+	}
+
+	// A reference is only shown when something else refers to the value.
+	prefix := ""
+	if ref, ok := value.(*Reference); ok {
+		if d.refcounts[ref] > 1 {
+			prefix = "&"
+		}
+
+		value = ref.Value
+	}
+
+	dst = append(dst, prefix...)
+
+	switch v := value.(type) {
+	case []interface{}, *orderedmap.OrderedMap[any, any], *Object:
+		if d.recursive(value) {
+			return append(dst[:len(dst)-len(prefix)], "*RECURSION*\n"...)
+		}
+
+		entries := dumpEntries(v)
+		if o, ok := v.(*Object); ok {
+			dst = append(dst, "object("...)
+			dst = append(dst, o.ClassName...)
+			dst = append(dst, ")#"...)
+			dst = strconv.AppendInt(dst, int64(d.handles[o]), 10)
+			dst = append(dst, " ("...)
+			dst = strconv.AppendInt(dst, int64(len(entries)), 10)
+			dst = append(dst, ") {\n"...)
+		} else {
+			dst = append(dst, "array("...)
+			dst = strconv.AppendInt(dst, int64(len(entries)), 10)
+			dst = append(dst, ") {\n"...)
+		}
+
+		dst = d.appendEntries(dst, value, entries, level, d.appendVarDump)
+
+	default:
+		dst = appendDumpScalar(dst, value)
+	}
+
+	return dst
+}
+
+func (d *dumper) appendDebugZvalDump(dst []byte, value interface{}, level int) []byte {
+	if level > 1 {
+		dst = appendSpaces(dst, level-1)
+	}
+
+	switch v := value.(type) {
+	case *Reference:
+		dst = append(dst, "reference refcount("...)
+		dst = strconv.AppendInt(dst, int64(d.refcounts[v]), 10)
+		dst = append(dst, ") {\n"...)
+		dst = d.appendDebugZvalDump(dst, v.Value, level+2)
+
+		return appendClose(dst, level)
+
+	case string:
+		dst = appendDumpScalar(dst, v)
+		dst = dst[:len(dst)-1]
+
+		// Unserialize uses the interned strings that PHP has for every
+		// string of one byte or less.
+		if len(v) <= 1 {
+			return append(dst, " interned\n"...)
+		}
+
+		// The variable that holds the value is also counted.
+		if level == 1 {
+			return append(dst, " refcount(2)\n"...)
+		}
+
+		return append(dst, " refcount(1)\n"...)
+
+	case []interface{}, *orderedmap.OrderedMap[any, any], *Object:
+		if d.recursive(value) {
+			return append(dst, "*RECURSION*\n"...)
+		}
+
+		refcount := d.refcount(value)
+		if _, ok := value.([]interface{}); ok && level == 1 {
+			refcount++
+		}
+
+		entries := dumpEntries(v)
+		if o, ok := v.(*Object); ok {
+			dst = append(dst, "object("...)
+			dst = append(dst, o.ClassName...)
+			dst = append(dst, ")#"...)
+			dst = strconv.AppendInt(dst, int64(d.handles[o]), 10)
+			dst = append(dst, " ("...)
+			dst = strconv.AppendInt(dst, int64(len(entries)), 10)
+			dst = append(dst, ") refcount("...)
+			dst = strconv.AppendInt(dst, int64(refcount), 10)
+			dst = append(dst, "){\n"...)
+		} else if len(entries) == 0 {
+			// Empty arrays are never counted.
+			dst = append(dst, "array(0) interned {\n"...)
+		} else {
+			dst = append(dst, "array("...)
+			dst = strconv.AppendInt(dst, int64(len(entries)), 10)
+			dst = append(dst, ") refcount("...)
+			dst = strconv.AppendInt(dst, int64(refcount), 10)
+			dst = append(dst, "){\n"...)
+		}
+
+		return d.appendEntries(dst, value, entries, level, d.appendDebugZvalDump)
+	}
+
+	return appendDumpScalar(dst, value)
+}
+
+// appendEntries appends the elements of an array or the properties of an
+// object, and the closing brace, for var_dump() and debug_zval_dump().
+func (d *dumper) appendEntries(dst []byte, container interface{}, entries []dumpEntry, level int,
+	appendValue func(dst []byte, value interface{}, level int) []byte) []byte {
+	_, isObject := container.(*Object)
+
+	d.setVisiting(container, true)
+	for _, entry := range entries {
+		dst = appendDumpKey(dst, dumpKey(entry.key), isObject, level)
+		dst = appendValue(dst, entry.value, level+2)
+	}
+	d.setVisiting(container, false)
+
+	return appendClose(dst, level)
+}
+
+func appendClose(dst []byte, level int) []byte {
+	if level > 1 {
+		dst = appendSpaces(dst, level-1)
+	}
+
+	return append(dst, "}\n"...)
+}
+
+// appendDumpScalar appends a value that is not an array or object in the form
+// that var_dump() uses.
+func appendDumpScalar(dst []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		dst = append(dst, "NULL"...)
+
+	case bool:
+		dst = append(dst, "bool("...)
+		dst = strconv.AppendBool(dst, v)
+		dst = append(dst, ')')
+
+	case int64:
+		dst = append(dst, "int("...)
+		dst = strconv.AppendInt(dst, v, 10)
+		dst = append(dst, ')')
+
+	case float64:
+		dst = append(dst, "float("...)
+		dst = appendPHPFloat(dst, v, -1)
+		dst = append(dst, ')')
+
+	case string:
+		dst = append(dst, "string("...)
+		dst = strconv.AppendInt(dst, int64(len(v)), 10)
+		dst = append(dst, ") \""...)
+		dst = append(dst, v...)
+		dst = append(dst, '"')
+	}
+
+	return append(dst, '\n')
+}
+
+func (d *dumper) appendPrintR(dst []byte, value interface{}, indent int) []byte {
+	if ref, ok := value.(*Reference); ok {
+		value = ref.Value
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return dst
+
+	case bool:
+		if v {
+			dst = append(dst, '1')
+		}
+
+		return dst
+
+	case int64:
+		return strconv.AppendInt(dst, v, 10)
+
+	case float64:
+		return appendPHPFloat(dst, v, 14)
+
+	case string:
+		return append(dst, v...)
+
+	case *Object:
+		dst = append(dst, v.ClassName...)
+		dst = append(dst, " Object\n"...)
+
+	default:
+		dst = append(dst, "Array\n"...)
+	}
+
+	if d.recursive(value) {
+		return append(dst, " *RECURSION*"...)
+	}
+
+	_, isObject := value.(*Object)
+
+	dst = appendSpaces(dst, indent)
+	dst = append(dst, "(\n"...)
+
+	d.setVisiting(value, true)
+	for _, entry := range dumpEntries(value) {
+		dst = appendSpaces(dst, indent+4)
+		dst = append(dst, '[')
+
+		switch key := dumpKey(entry.key).(type) {
+		case int64:
+			dst = strconv.AppendInt(dst, key, 10)
+
+		case string:
+			name, class, mangled := unmangleProperty(key)
+			switch {
+			case !isObject || !mangled:
+				dst = append(dst, key...)
+
+			case class == "*":
+				dst = append(dst, name...)
+				dst = append(dst, ":protected"...)
+
+			default:
+				dst = append(dst, name...)
+				dst = append(dst, ':')
+				dst = append(dst, class...)
+				dst = append(dst, ":private"...)
+			}
+		}
+
+		dst = append(dst, "] => "...)
+		dst = d.appendPrintR(dst, entry.value, indent+8)
+		dst = append(dst, '\n')
+	}
+	d.setVisiting(value, false)
+
+	dst = appendSpaces(dst, indent)
+
+	return append(dst, ")\n"...)
+}
+
+func appendSpaces(dst []byte, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, ' ')
+	}
+
+	return dst
+}
+
+// appendPHPFloat appends a float in the same way as PHP converts it to a
+// string with a precision setting (the "precision" and "serialize_precision"
+// ini settings). A precision of -1 uses the fewest digits that will be read
+// back as the same value.
+func appendPHPFloat(dst []byte, value float64, precision int) []byte {
+	switch {
+	case math.IsInf(value, 1):
+		return append(dst, "INF"...)
+	case math.IsInf(value, -1):
+		return append(dst, "-INF"...)
+	case math.IsNaN(value):
+		return append(dst, "NAN"...)
+	}
+
+	// strconv gives the digits and exponent, which are then laid out in the
+	// same way as zend_gcvt().
+	ndigit := precision
+	if precision < 0 {
+		ndigit = 17
+	} else {
+		precision--
+	}
+
+	s := strconv.FormatFloat(value, 'e', precision, 64)
+	if s[0] == '-' {
+		dst = append(dst, '-')
+		s = s[1:]
+	}
+
+	e := strings.IndexByte(s, 'e')
+	exponent, _ := strconv.Atoi(s[e+1:])
+	digits := strings.TrimRight(strings.Replace(s[:e], ".", "", 1), "0")
+	if digits == "" {
+		digits, exponent = "0", 0
+	}
+
+	// decpt is the position of the decimal point in the digits.
+	decpt := exponent + 1
+
+	if decpt < 0 && decpt < -3 || decpt >= 0 && decpt > ndigit {
+		dst = append(dst, digits[0], '.')
+		if len(digits) == 1 {
+			dst = append(dst, '0')
+		} else {
+			dst = append(dst, digits[1:]...)
+		}
+
+		dst = append(dst, 'E')
+		if exponent < 0 {
+			dst = append(dst, '-')
+			exponent = -exponent
+		} else {
+			dst = append(dst, '+')
+		}
+
+		return strconv.AppendInt(dst, int64(exponent), 10)
+	}
+
+	if decpt <= 0 {
+		dst = append(dst, "0."...)
+		dst = appendZeros(dst, -decpt)
+
+		return append(dst, digits...)
+	}
+
+	if len(digits) <= decpt {
+		dst = append(dst, digits...)
+
+		return appendZeros(dst, decpt-len(digits))
+	}
+
+	dst = append(dst, digits[:decpt]...)
+	dst = append(dst, '.')
+
+	return append(dst, digits[decpt:]...)
+}
+
+func appendZeros(dst []byte, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, '0')
+	}
+
+	return dst
+}
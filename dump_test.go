@@ -0,0 +1,300 @@
+package phpserialize_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+// dumpData has a reference, a shared object, mangled property names and
+// nested arrays.
+const dumpData = "a:6:{i:0;s:3:\"abc\";i:1;R:2;s:1:\"o\";O:3:\"Foo\":3:{" +
+	"s:4:\"\x00*\x00p\";d:0.1;s:9:\"\x00Foo\x00priv\";a:0:{}s:1:\"n\";N;}" +
+	"s:1:\"r\";r:3;s:1:\"f\";d:1.0E+25;i:5;a:1:{i:0;b:1;}}"
+
+func decodeDumpData(t *testing.T) interface{} {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+	options.KeepReferences = true
+
+	var value interface{}
+	err := phpserialize.NewReader([]byte(dumpData), options).Decode(&value)
+	expectErrorToNotHaveOccurred(t, err)
+
+	return value
+}
+
+func TestVarDump(t *testing.T) {
+	var buf strings.Builder
+	err := phpserialize.VarDump(&buf, decodeDumpData(t))
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `array(6) {
+  [0]=>
+  &string(3) "abc"
+  [1]=>
+  &string(3) "abc"
+  ["o"]=>
+  object(Foo)#1 (3) {
+    ["p":protected]=>
+    float(0.1)
+    ["priv":"Foo":private]=>
+    array(0) {
+    }
+    ["n"]=>
+    NULL
+  }
+  ["r"]=>
+  object(Foo)#1 (3) {
+    ["p":protected]=>
+    float(0.1)
+    ["priv":"Foo":private]=>
+    array(0) {
+    }
+    ["n"]=>
+    NULL
+  }
+  ["f"]=>
+  float(1.0E+25)
+  [5]=>
+  array(1) {
+    [0]=>
+    bool(true)
+  }
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestPrintR(t *testing.T) {
+	var buf strings.Builder
+	err := phpserialize.PrintR(&buf, decodeDumpData(t))
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `Array
+(
+    [0] => abc
+    [1] => abc
+    [o] => Foo Object
+        (
+            [p:protected] => 0.1
+            [priv:Foo:private] => Array
+                (
+                )
+
+            [n] => 
+        )
+
+    [r] => Foo Object
+        (
+            [p:protected] => 0.1
+            [priv:Foo:private] => Array
+                (
+                )
+
+            [n] => 
+        )
+
+    [f] => 1.0E+25
+    [5] => Array
+        (
+            [0] => 1
+        )
+
+)
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestDebugZvalDump(t *testing.T) {
+	var buf strings.Builder
+	err := phpserialize.DebugZvalDump(&buf, decodeDumpData(t))
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `array(6) refcount(2){
+  [0]=>
+  reference refcount(2) {
+    string(3) "abc" refcount(1)
+  }
+  [1]=>
+  reference refcount(2) {
+    string(3) "abc" refcount(1)
+  }
+  ["o"]=>
+  object(Foo)#1 (3) refcount(2){
+    ["p":protected]=>
+    float(0.1)
+    ["priv":"Foo":private]=>
+    array(0) interned {
+    }
+    ["n"]=>
+    NULL
+  }
+  ["r"]=>
+  object(Foo)#1 (3) refcount(2){
+    ["p":protected]=>
+    float(0.1)
+    ["priv":"Foo":private]=>
+    array(0) interned {
+    }
+    ["n"]=>
+    NULL
+  }
+  ["f"]=>
+  float(1.0E+25)
+  [5]=>
+  array(1) refcount(1){
+    [0]=>
+    bool(true)
+  }
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestDumpScalars(t *testing.T) {
+	tests := []struct {
+		value     interface{}
+		varDump   string
+		printR    string
+		debugZval string
+	}{
+		{nil, "NULL\n", "", "NULL\n"},
+		{false, "bool(false)\n", "", "bool(false)\n"},
+		{true, "bool(true)\n", "1", "bool(true)\n"},
+		{int64(-7), "int(-7)\n", "-7", "int(-7)\n"},
+		{"hello", "string(5) \"hello\"\n", "hello", "string(5) \"hello\" refcount(2)\n"},
+		{"a", "string(1) \"a\"\n", "a", "string(1) \"a\" interned\n"},
+		{0.0, "float(0)\n", "0", "float(0)\n"},
+		{math.Copysign(0, -1), "float(-0)\n", "-0", "float(-0)\n"},
+		{1.5, "float(1.5)\n", "1.5", "float(1.5)\n"},
+		{1e15, "float(1000000000000000)\n", "1.0E+15", "float(1000000000000000)\n"},
+		{1e17, "float(1.0E+17)\n", "1.0E+17", "float(1.0E+17)\n"},
+		{0.0001, "float(0.0001)\n", "0.0001", "float(0.0001)\n"},
+		{0.00001, "float(1.0E-5)\n", "1.0E-5", "float(1.0E-5)\n"},
+		{1.0 / 3, "float(0.3333333333333333)\n", "0.33333333333333", "float(0.3333333333333333)\n"},
+		{123456789.12345678, "float(123456789.12345678)\n", "123456789.12346", "float(123456789.12345678)\n"},
+		{math.Inf(-1), "float(-INF)\n", "-INF", "float(-INF)\n"},
+		{math.NaN(), "float(NAN)\n", "NAN", "float(NAN)\n"},
+		{phpserialize.Number("12"), "int(12)\n", "12", "int(12)\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.value), func(t *testing.T) {
+			for _, dump := range []struct {
+				fn       func(w *strings.Builder, value interface{}) error
+				expected string
+			}{
+				{func(w *strings.Builder, value interface{}) error { return phpserialize.VarDump(w, value) }, test.varDump},
+				{func(w *strings.Builder, value interface{}) error { return phpserialize.PrintR(w, value) }, test.printR},
+				{func(w *strings.Builder, value interface{}) error { return phpserialize.DebugZvalDump(w, value) }, test.debugZval},
+			} {
+				var buf strings.Builder
+				expectErrorToNotHaveOccurred(t, dump.fn(&buf, test.value))
+
+				if buf.String() != dump.expected {
+					t.Errorf("Expected %q, got %q", dump.expected, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDumpGoValues(t *testing.T) {
+	type Point struct {
+		X int
+		Y []string
+	}
+
+	var buf strings.Builder
+	err := phpserialize.VarDump(&buf, []interface{}{Point{X: 1, Y: []string{"a"}}, 2})
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `array(2) {
+  [0]=>
+  object(Point)#1 (2) {
+    ["x"]=>
+    int(1)
+    ["y"]=>
+    array(1) {
+      [0]=>
+      string(1) "a"
+    }
+  }
+  [1]=>
+  int(2)
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestDumpRecursion(t *testing.T) {
+	o := phpserialize.NewObject("Node")
+	o.Properties.Set("self", o)
+
+	var buf strings.Builder
+	expectErrorToNotHaveOccurred(t, phpserialize.VarDump(&buf, o))
+
+	expected := "object(Node)#1 (1) {\n  [\"self\"]=>\n  *RECURSION*\n}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	expectErrorToNotHaveOccurred(t, phpserialize.PrintR(&buf, o))
+
+	expected = "Node Object\n(\n    [self] => Node Object\n *RECURSION*\n)\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	o := phpserialize.NewObject("Foo")
+	o.Properties.Set("a", "xy")
+
+	tests := map[string]struct {
+		actual   string
+		expected string
+	}{
+		"v":  {fmt.Sprintf("%v", o), "Foo Object\n(\n    [a] => xy\n)\n"},
+		"s":  {fmt.Sprintf("%s", o), "Foo Object\n(\n    [a] => xy\n)\n"},
+		"+v": {fmt.Sprintf("%+v", o), "object(Foo)#1 (1) {\n  [\"a\"]=>\n  string(2) \"xy\"\n}\n"},
+		"#v": {
+			fmt.Sprintf("%#v", phpserialize.Formatter(o)),
+			"object(Foo)#1 (1) refcount(2){\n  [\"a\"]=>\n  string(2) \"xy\" refcount(1)\n}\n",
+		},
+		"d": {fmt.Sprintf("%d", o), "%!d(*phpserialize.Object)"},
+		"value": {
+			fmt.Sprintf("%v|%+v", phpserialize.Formatter([]interface{}{true}), phpserialize.Formatter(int64(3))),
+			"Array\n(\n    [0] => 1\n)\n|int(3)\n",
+		},
+		"reference": {
+			fmt.Sprintf("%+v", &phpserialize.Reference{Value: "s"}),
+			"string(1) \"s\"\n",
+		},
+		"Go syntax": {
+			fmt.Sprintf("%#v|%#v", &phpserialize.Reference{Value: "s"}, (*phpserialize.Object)(nil)),
+			`&phpserialize.Reference{Value:"s"}|(*phpserialize.Object)(nil)`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if test.actual != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, test.actual)
+			}
+		})
+	}
+}
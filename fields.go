@@ -0,0 +1,458 @@
+package phpserialize
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+var orderedMapType = reflect.TypeOf(&orderedmap.OrderedMap[any, any]{})
+
+// field describes a struct field that is visible to PHP. The field may belong
+// to an embedded struct, in which case index contains more than one element.
+type field struct {
+	// name is the name of the property in the PHP object.
+	name string
+
+	// tagged is true if the name came from a "php" tag.
+	tagged bool
+
+	// goName is the name of the field in the Go struct.
+	goName string
+
+	// aliases are other property names that can be decoded into the field.
+	// They come from the "alias" tag option, for example:
+	//
+	//	UserID int `php:"user_id,alias=userId|UserID"`
+	aliases []string
+
+	index   []int
+	typ     reflect.Type
+	options tagOptions
+
+	// nameBytes is the name already encoded as a PHP string.
+	nameBytes []byte
+
+	// The omit options from the tag. See MarshalStruct.
+	omitNilPtr bool
+	omitEmpty  bool
+	omitZero   bool
+
+	// encode and decode are chosen once for the type of the field so that
+	// the common types do not need to go through Marshal and setField.
+	encode fieldEncoder
+	decode fieldDecoder
+}
+
+type fieldEncoder func(dst []byte, v reflect.Value, options *MarshalOptions) ([]byte, error)
+
+type fieldDecoder func(v reflect.Value, value interface{}, options *UnmarshalOptions) error
+
+// structFields is the result of typeFields.
+type structFields struct {
+	list []field
+
+	// rest is the field that has the "rest" tag option. It collects the
+	// properties that do not belong to any other field. It is nil if there is
+	// no such field.
+	rest *field
+
+	// byName is the position of each field in list by its name.
+	byName map[string]int
+}
+
+type fieldCacheKey struct {
+	t     reflect.Type
+	namer uintptr
+}
+
+// fieldCache holds the *structFields for each struct type and FieldNamer that
+// has been seen.
+var fieldCache sync.Map
+
+// builtinNamers can be safely cached because each function always returns the
+// same name for a field. Other namers are not cached because two closures can
+// share the same code but capture different variables.
+var builtinNamers = map[uintptr]bool{
+	0: true,
+	reflect.ValueOf(LowerFirstNamer).Pointer(): true,
+	reflect.ValueOf(CamelCaseNamer).Pointer():  true,
+	reflect.ValueOf(SnakeCaseNamer).Pointer():  true,
+	reflect.ValueOf(ExactNamer).Pointer():      true,
+}
+
+// cachedTypeFields is like typeFields but the result is cached, so the fields
+// of each type are only worked out once. It is safe for concurrent use.
+func cachedTypeFields(t reflect.Type, namer FieldNamer) *structFields {
+	var namerPointer uintptr
+	if namer != nil {
+		namerPointer = reflect.ValueOf(namer).Pointer()
+	}
+
+	if !builtinNamers[namerPointer] {
+		fields := typeFields(t, namer)
+		return &fields
+	}
+
+	key := fieldCacheKey{t, namerPointer}
+	if f, ok := fieldCache.Load(key); ok {
+		return f.(*structFields)
+	}
+
+	fields := typeFields(t, namer)
+	f, _ := fieldCache.LoadOrStore(key, &fields)
+
+	return f.(*structFields)
+}
+
+// typeFields returns the fields of a struct type that are encoded and decoded
+// as properties of a PHP object. Fields of embedded structs are promoted into
+// the parent, following the same rules as encoding/json:
+//
+//  1. An embedded struct with a name in its "php" tag is treated as a regular
+//     field with that name.
+//  2. Of several fields with the same name, the one that is nested the least
+//     deeply wins.
+//  3. If several fields are nested at the same depth, the one with a "php" tag
+//     wins. If that does not resolve it, all of them are ignored.
+//
+// Fields that do not have a name in their tag are named by namer. A nil namer
+// is the same as LowerFirstNamer.
+//
+// A field of type *orderedmap.OrderedMap[any, any] with the "rest" tag option is
+// not a property itself. If there is more than one the least nested is used.
+func typeFields(t reflect.Type, namer FieldNamer) structFields {
+	if namer == nil {
+		namer = LowerFirstNamer
+	}
+
+	type candidate struct {
+		t     reflect.Type
+		index []int
+	}
+
+	var fields []field
+	var rest *field
+
+	current := []candidate{}
+	next := []candidate{{t: t}}
+
+	// Count how many times each struct type is embedded at the current and
+	// next depth so that duplicated fields can be detected.
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, c := range current {
+			if visited[c.t] {
+				continue
+			}
+			visited[c.t] = true
+
+			for i := 0; i < c.t.NumField(); i++ {
+				sf := c.t.Field(i)
+
+				fieldType := sf.Type
+				if fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+
+				if sf.Anonymous {
+					// Unexported embedded structs can still have
+					// exported fields that are promoted.
+					if !sf.IsExported() && fieldType.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tagName, options := parseTag(sf.Tag.Get("php"))
+				if tagName == "-" {
+					continue
+				}
+
+				index := make([]int, len(c.index)+1)
+				copy(index, c.index)
+				index[len(c.index)] = i
+
+				if !sf.Anonymous || tagName != "" || fieldType.Kind() != reflect.Struct {
+					if !sf.IsExported() {
+						continue
+					}
+
+					name := tagName
+					if name == "" {
+						name = namer(sf.Name)
+					}
+
+					f := field{
+						name:    name,
+						tagged:  tagName != "",
+						goName:  sf.Name,
+						index:   index,
+						typ:     sf.Type,
+						options: options,
+					}
+
+					if alias, ok := options.Get("alias"); ok {
+						f.aliases = strings.Split(alias, "|")
+					}
+
+					if options.Contains("rest") && sf.Type == orderedMapType {
+						if rest == nil {
+							rest = &f
+						}
+
+						continue
+					}
+
+					fields = append(fields, f)
+
+					// If the parent was embedded more than once at
+					// this depth its fields would be duplicated.
+					// Add one more so the conflict is seen.
+					if count[c.t] > 1 {
+						fields = append(fields, fields[len(fields)-1])
+					}
+
+					continue
+				}
+
+				// Walk into the embedded struct at the next depth.
+				nextCount[fieldType]++
+				if nextCount[fieldType] == 1 {
+					next = append(next, candidate{t: fieldType, index: index})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		if fields[i].tagged != fields[j].tagged {
+			return fields[i].tagged
+		}
+
+		return lessIndex(fields[i].index, fields[j].index)
+	})
+
+	// Remove the fields that are hidden by a more dominant field with the
+	// same name.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].index, fields[j].index)
+	})
+
+	byName := make(map[string]int, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		f.nameBytes = MarshalString(f.name)
+		f.omitNilPtr = f.options.Contains("omitnilptr")
+		f.omitEmpty = f.options.Contains("omitempty")
+		f.omitZero = f.options.Contains("omitzero")
+		f.encode = newFieldEncoder(f.typ, f.options)
+		f.decode = newFieldDecoder(f.typ, f.options)
+
+		byName[f.name] = i
+	}
+
+	return structFields{list: fields, rest: rest, byName: byName}
+}
+
+// has returns true if name is the property name of one of the fields.
+func (fields *structFields) has(name string) bool {
+	_, ok := fields.byName[name]
+
+	return ok
+}
+
+// newFieldEncoder returns the encoder for a field. Fields of named types always
+// use Marshal since they may be handled differently from their underlying type.
+func newFieldEncoder(t reflect.Type, options tagOptions) fieldEncoder {
+	if t == timeType || t == reflect.PointerTo(timeType) {
+		return newTimeEncoder(t, options)
+	}
+
+	if t == durationType && options.Contains("interval") {
+		return newDurationEncoder()
+	}
+
+	if options.Contains("string") {
+		return appendAsString
+	}
+
+	if t.PkgPath() != "" {
+		return marshalValue
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(dst []byte, v reflect.Value, _ *MarshalOptions) ([]byte, error) {
+			return AppendBool(dst, v.Bool()), nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(dst []byte, v reflect.Value, _ *MarshalOptions) ([]byte, error) {
+			return AppendInt(dst, v.Int()), nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(dst []byte, v reflect.Value, _ *MarshalOptions) ([]byte, error) {
+			return AppendUint(dst, v.Uint()), nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		bitSize := t.Bits()
+		return func(dst []byte, v reflect.Value, _ *MarshalOptions) ([]byte, error) {
+			return AppendFloat(dst, v.Float(), bitSize), nil
+		}
+
+	case reflect.String:
+		return func(dst []byte, v reflect.Value, _ *MarshalOptions) ([]byte, error) {
+			return AppendString(dst, v.String()), nil
+		}
+	}
+
+	return marshalValue
+}
+
+func marshalValue(dst []byte, v reflect.Value, options *MarshalOptions) ([]byte, error) {
+	return appendMarshal(dst, v.Interface(), options)
+}
+
+// newFieldDecoder returns the decoder for a field. Values that already have the
+// right type are set directly, anything else goes through setField.
+func newFieldDecoder(t reflect.Type, options tagOptions) fieldDecoder {
+	if t == timeType || t == reflect.PointerTo(timeType) {
+		return newTimeDecoder(t, options)
+	}
+
+	if t == durationType && options.Contains("interval") {
+		return newDurationDecoder()
+	}
+
+	if options.Contains("string") {
+		return setStringField
+	}
+
+	if t.PkgPath() != "" {
+		return setField
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+			if b, ok := value.(bool); ok {
+				v.SetBool(b)
+				return nil
+			}
+
+			return setField(v, value, options)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+			if i, ok := value.(int64); ok && !v.OverflowInt(i) {
+				v.SetInt(i)
+				return nil
+			}
+
+			return setField(v, value, options)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+			if f, ok := value.(float64); ok {
+				v.SetFloat(f)
+				return nil
+			}
+
+			return setField(v, value, options)
+		}
+
+	case reflect.String:
+		return func(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+			if s, ok := value.(string); ok {
+				v.SetString(s)
+				return nil
+			}
+
+			return setField(v, value, options)
+		}
+	}
+
+	return setField
+}
+
+// dominantField picks the field that wins out of several with the same name.
+// The fields must already be sorted by depth and then by tag. If there is no
+// clear winner the second return value is false.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) &&
+		fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+
+	return fields[0], true
+}
+
+func lessIndex(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// fieldByIndex returns the value of a (possibly promoted) field. If alloc is
+// true then nil pointers to embedded structs will be allocated, otherwise the
+// second return value will be false if one is found.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
@@ -0,0 +1,77 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnmarshalAs decodes data into a new value of type T. It is the generic
+// equivalent of:
+//
+//	var v T
+//	err := UnmarshalWithOptions(data, &v, options)
+//
+// The options are optional, and only the first is used. A nil or missing
+// options is the same as DefaultUnmarshalOptions(). If T is a pointer type a
+// new value will be allocated for it, unless the data is a PHP null. If T is
+// an interface{} (any) the value is decoded in the same way as an interface{}
+// struct field.
+//
+// Errors returned from UnmarshalAs include the name of T in their message. The
+// original error can still be retrieved with errors.Unwrap().
+func UnmarshalAs[T any](data []byte, options ...*UnmarshalOptions) (T, error) {
+	var o *UnmarshalOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
+
+	return unmarshalAs[T](data, reflect.TypeFor[T](), o)
+}
+
+// Decoder decodes PHP serialized data into values of type T. It holds on to
+// the options and the reflected type of T so that they do not need to be
+// provided (or looked up) for every value.
+//
+// A Decoder is safe for concurrent use.
+type Decoder[T any] struct {
+	options *UnmarshalOptions
+	typ     reflect.Type
+}
+
+// NewDecoder creates a Decoder for values of type T. A nil options is the same
+// as DefaultUnmarshalOptions().
+func NewDecoder[T any](options *UnmarshalOptions) *Decoder[T] {
+	if options == nil {
+		options = DefaultUnmarshalOptions()
+	}
+
+	return &Decoder[T]{
+		options: options,
+		typ:     reflect.TypeFor[T](),
+	}
+}
+
+// Decode decodes data into a new value of type T. See UnmarshalAs.
+func (d *Decoder[T]) Decode(data []byte) (T, error) {
+	return unmarshalAs[T](data, d.typ, d.options)
+}
+
+func unmarshalAs[T any](data []byte, typ reflect.Type, options *UnmarshalOptions) (T, error) {
+	var v T
+	var err error
+
+	// UnmarshalWithOptions only accepts concrete types, but a Reader can
+	// decode into an interface{}.
+	if typ.Kind() == reflect.Interface && typ.NumMethod() == 0 {
+		err = NewReader(data, options).Decode(&v)
+	} else {
+		err = UnmarshalWithOptions(data, &v, options)
+	}
+
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("can not unmarshal into %s: %w", typ, err)
+	}
+
+	return v, nil
+}
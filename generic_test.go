@@ -0,0 +1,174 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+func TestUnmarshalAsScalar(t *testing.T) {
+	result, err := phpserialize.UnmarshalAs[int]([]byte("i:123;"), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result != 123 {
+		t.Errorf("Expected %v, got %v", 123, result)
+	}
+}
+
+func TestUnmarshalAsStruct(t *testing.T) {
+	data := "O:7:\"struct1\":3:{s:3:\"foo\";i:10;s:3:\"bar\";O:7:\"Struct2\":1:{s:3:\"qux\";d:1.23;}s:3:\"baz\";s:3:\"yay\";}"
+	result, err := phpserialize.UnmarshalAs[struct1]([]byte(data), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Foo != 10 || result.Bar.Qux != 1.23 || result.Baz != "yay" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestUnmarshalAsStructPointer(t *testing.T) {
+	data := "O:7:\"Struct2\":1:{s:3:\"qux\";d:1.23;}"
+	result, err := phpserialize.UnmarshalAs[*Struct2]([]byte(data), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result == nil || result.Qux != 1.23 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	result, err = phpserialize.UnmarshalAs[*Struct2]([]byte("N;"), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result != nil {
+		t.Errorf("Expected nil, got %+v", result)
+	}
+}
+
+func TestUnmarshalAsTypedSlice(t *testing.T) {
+	result, err := phpserialize.UnmarshalAs[[]string](
+		[]byte("a:2:{i:0;s:1:\"a\";i:1;s:1:\"b\";}"), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if len(result) != 2 || result[0] != "a" || result[1] != "b" {
+		t.Errorf("Unexpected result: %#v", result)
+	}
+}
+
+func TestUnmarshalAsOrderedMap(t *testing.T) {
+	result, err := phpserialize.UnmarshalAs[*orderedmap.OrderedMap[any, any]](
+		[]byte("a:2:{s:3:\"bar\";i:20;s:3:\"foo\";i:10;}"), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if keys := orderedKeys(result); !equalKeys(keys, []any{"bar", "foo"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+}
+
+func TestUnmarshalAsOptions(t *testing.T) {
+	data := []byte(`a:1:{i:0;O:3:"Foo":1:{s:1:"a";i:1;}}`)
+
+	result, err := phpserialize.UnmarshalAs[[]interface{}](data)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if _, ok := result[0].(*orderedmap.OrderedMap[any, any]); !ok {
+		t.Errorf("Expected an ordered map, got %#v", result[0])
+	}
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	result, err = phpserialize.UnmarshalAs[[]interface{}](data, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if o, ok := result[0].(*phpserialize.Object); !ok || o.ClassName != "Foo" {
+		t.Errorf("Expected an object, got %#v", result[0])
+	}
+}
+
+func TestUnmarshalAsAny(t *testing.T) {
+	result, err := phpserialize.UnmarshalAs[any]([]byte(`a:2:{i:0;s:1:"a";i:1;d:1.5;}`))
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !reflect.DeepEqual(result, []interface{}{"a", 1.5}) {
+		t.Errorf("Unexpected result %#v", result)
+	}
+
+	decoded, err := phpserialize.NewDecoder[any](nil).Decode([]byte("i:7;"))
+	expectErrorToNotHaveOccurred(t, err)
+
+	if decoded != int64(7) {
+		t.Errorf("Expected 7, got %#v", decoded)
+	}
+
+	_, err = phpserialize.UnmarshalAs[any]([]byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "interface {}") {
+		t.Errorf("Expected an error naming interface {}, got '%v'", err)
+	}
+}
+
+func TestUnmarshalAsErrorIncludesType(t *testing.T) {
+	_, err := phpserialize.UnmarshalAs[int]([]byte("N;"), nil)
+	if err == nil {
+		t.Fatal("expected error to occur")
+	}
+
+	if !strings.Contains(err.Error(), "int") {
+		t.Errorf("Expected the target type in the error, got '%s'", err)
+	}
+
+	if errors.Unwrap(err) == nil || errors.Unwrap(err).Error() != "not an integer" {
+		t.Errorf("Expected the original error to be wrapped, got '%v'",
+			errors.Unwrap(err))
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	decoder := phpserialize.NewDecoder[Struct2](nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := decoder.Decode([]byte("O:7:\"Struct2\":1:{s:3:\"qux\";d:1.5;}"))
+			expectErrorToNotHaveOccurred(t, err)
+
+			if result.Qux != 1.5 {
+				t.Errorf("Expected %v, got %v", 1.5, result.Qux)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err := decoder.Decode([]byte("i:1;"))
+	if err == nil || !strings.Contains(err.Error(), "Struct2") {
+		t.Errorf("Expected an error naming Struct2, got '%v'", err)
+	}
+}
+
+func orderedKeys(m *orderedmap.OrderedMap[any, any]) []any {
+	var keys []any
+	for key := range m.Keys() {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func equalKeys(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,188 @@
+package gentest_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+	"github.com/jamteacoffee/phpserialize/internal/gentest"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+var values = map[string]interface{}{
+	"zero Profile": gentest.Profile{},
+	"full Profile": gentest.Profile{
+		ID:       12,
+		Name:     "Bob's",
+		Nickname: ptr("bobby"),
+		Age:      42,
+		Height:   1.85,
+		Weight:   80.5,
+		Small:    -3,
+		Big:      18446744073709551615,
+		Active:   true,
+		Avatar:   []byte{0, 1, 255},
+		Score:    ptr(-7),
+		Email:    "bob@example.com",
+		Status:   3,
+		Tags:     []string{"a", "b"},
+		Meta:     map[string]string{"x": "y"},
+		Extra:    []interface{}{1, "two"},
+		Created:  time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Home:     gentest.Address{Street: "Main St", City: "Springfield"},
+		Work:     &gentest.Address{Street: "Elm", Postcode: ptr(1234)},
+		Addresses: []gentest.Address{
+			{City: "Paris"},
+			{Street: "Baker St", Postcode: ptr(0)},
+		},
+		Ignored: "ignored",
+	},
+	"empty map Profile": gentest.Profile{Meta: map[string]string{}, Tags: []string{}, M: orderedmap.NewOrderedMap[any, any]()},
+	"ordered map Profile": gentest.Profile{M: func() *orderedmap.OrderedMap[any, any] {
+		m := orderedmap.NewOrderedMap[any, any]()
+		m.Set("k", int64(1))
+
+		return m
+	}()},
+	"pointer to Profile": &gentest.Profile{Name: "ptr"},
+	"Address":            gentest.Address{Street: "s", City: "c", Postcode: ptr(1)},
+	"zero Counter":       gentest.Counter{},
+	"Counter":            gentest.Counter{Hits: -5, Ratio: 0.25, Enable: ptr(true), Label: "l"},
+	"Legacy":             gentest.Legacy{When: time.Unix(1700000000, 0)},
+	"Embedding":          gentest.Embedding{Base: gentest.Base{Kind: "k"}, Value: 3},
+}
+
+var marshalOptions = map[string]*phpserialize.MarshalOptions{
+	"nil":          nil,
+	"default":      phpserialize.DefaultMarshalOptions(),
+	"OnlyStdClass": {OnlyStdClass: true},
+	"FieldNamer":   {FieldNamer: phpserialize.SnakeCaseNamer},
+}
+
+func TestMarshalMatchesReflection(t *testing.T) {
+	for valueName, value := range values {
+		for optionsName, options := range marshalOptions {
+			t.Run(valueName+"/"+optionsName, func(t *testing.T) {
+				if _, ok := value.(phpserialize.Marshaler); !ok {
+					t.Fatalf("%T does not implement phpserialize.Marshaler", value)
+				}
+
+				expected, err := phpserialize.MarshalStruct(reflect.Indirect(reflect.ValueOf(value)).Interface(), withDefaults(options))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				result, err := phpserialize.Marshal(value, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(result) != string(expected) {
+					t.Errorf("Expected '%s', got '%s'", expected, result)
+				}
+			})
+		}
+	}
+}
+
+func withDefaults(options *phpserialize.MarshalOptions) *phpserialize.MarshalOptions {
+	if options == nil {
+		return phpserialize.DefaultMarshalOptions()
+	}
+
+	return options
+}
+
+var unmarshalOptions = map[string]*phpserialize.UnmarshalOptions{
+	"default":               phpserialize.DefaultUnmarshalOptions(),
+	"CaseInsensitive":       {CaseInsensitive: true},
+	"DisallowUnknownFields": {DisallowUnknownFields: true},
+	"ZeroCopy":              {ZeroCopy: true},
+	"FieldNamer":            {FieldNamer: phpserialize.SnakeCaseNamer},
+	"Coercion":              {Coercion: phpserialize.CoercePHP},
+}
+
+// unmarshalInputs are decoded into a Profile, as well as the encoded values.
+var unmarshalInputs = map[string]string{
+	"aliases":        `O:7:"Profile":3:{s:6:"e_mail";s:1:"c";s:4:"mail";s:1:"b";s:6:"e_mail";s:1:"d";}`,
+	"alias and name": `O:7:"Profile":3:{s:4:"mail";s:1:"b";s:5:"email";s:1:"a";s:4:"mail";s:1:"c";}`,
+	"nested alias":   `O:7:"Profile":1:{s:4:"home";O:7:"Address":2:{s:4:"town";s:1:"t";s:4:"city";N;}}`,
+	"unknown":        `O:7:"Profile":3:{s:1:"x";i:1;s:4:"name";s:1:"n";s:1:"y";a:1:{i:0;b:1;}}`,
+	"nulls":          `O:7:"Profile":3:{s:2:"iD";N;s:8:"nickname";N;s:4:"work";N;}`,
+	"other types":    `O:7:"Profile":4:{s:2:"iD";s:2:"12";s:6:"height";i:3;s:9:"is_active";i:1;s:5:"score";d:1.5;}`,
+	"wrong type":     `O:7:"Profile":1:{s:4:"name";a:0:{}}`,
+	"nested wrong":   `O:7:"Profile":1:{s:4:"work";O:7:"Address":1:{s:8:"postcode";s:1:"x";}}`,
+	"stdClass":       `O:8:"stdClass":1:{s:4:"name";s:1:"n";}`,
+	"array":          `a:2:{s:2:"iD";i:5;s:4:"name";s:1:"n";}`,
+	"escaped":        `O:7:"Profile":2:{s:4:"name";s:5:"a\'b";s:6:"avatar";s:2:"\x00\xff";}`,
+	"truncated":      `O:7:"Profile":2:{s:4:"name";s:1:"n";`,
+	"null":           `N;`,
+
+	"reference":        `O:7:"Address":2:{s:6:"street";s:1:"x";s:4:"city";R:2;}`,
+	"nested reference": `O:7:"Profile":2:{s:4:"tags";a:1:{i:0;s:1:"t";}s:4:"name";R:3;}`,
+	"alias reference":  `O:7:"Profile":3:{s:4:"mail";s:1:"m";s:4:"name";R:2;s:4:"town";R:2;}`,
+	"object reference": `O:7:"Profile":2:{s:4:"home";O:7:"Address":1:{s:6:"street";s:1:"s";}s:4:"work";r:2;}`,
+	"self reference":   `O:7:"Profile":1:{s:4:"work";r:1;}`,
+	"bad reference":    `O:7:"Address":1:{s:6:"street";R:5;}`,
+}
+
+func TestUnmarshalMatchesReflection(t *testing.T) {
+	inputs := map[string]string{}
+	for name, input := range unmarshalInputs {
+		inputs[name] = input
+	}
+
+	for valueName, value := range values {
+		data, err := phpserialize.Marshal(value, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inputs[valueName] = string(data)
+	}
+
+	for inputName, input := range inputs {
+		for optionsName, options := range unmarshalOptions {
+			t.Run(inputName+"/"+optionsName, func(t *testing.T) {
+				for _, newValue := range []func() interface{}{
+					func() interface{} { return &gentest.Profile{Name: "before", Score: ptr(1)} },
+					func() interface{} { return &gentest.Address{} },
+					func() interface{} { return &gentest.Counter{} },
+					func() interface{} { return &gentest.Legacy{} },
+					func() interface{} { return &gentest.Embedding{} },
+				} {
+					expected, result := newValue(), newValue()
+
+					expectedErr := phpserialize.NewReader([]byte(input), options).Decode(expected)
+					err := phpserialize.UnmarshalWithOptions([]byte(input), result, options)
+
+					if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+						t.Fatalf("%T: expected error %v, got %v", result, expectedErr, err)
+					}
+
+					// The value is only defined if there was no error.
+					if err == nil && !reflect.DeepEqual(result, expected) {
+						t.Errorf("%T: expected %+v, got %+v", result, expected, result)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestUnmarshalFieldPath(t *testing.T) {
+	var p gentest.Profile
+
+	err := phpserialize.Unmarshal([]byte(`O:7:"Profile":1:{s:4:"work";O:7:"Address":1:{s:8:"postcode";a:0:{}}}`), &p)
+
+	expected := "can not unmarshal PHP array into struct field Work.Postcode of type int"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%v'", expected, err)
+	}
+}
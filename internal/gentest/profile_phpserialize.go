@@ -0,0 +1,657 @@
+// Code generated by phpserialize-gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+// MarshalPHP implements phpserialize.Marshaler.
+func (x Profile) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = phpserialize.DefaultMarshalOptions()
+	}
+
+	if options.FieldNamer != nil {
+		m, err := phpserialize.MarshalStruct(x, options)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, m...), nil
+	}
+
+	n := 13
+	if x.Nickname != nil {
+		n++
+	}
+	if x.Age != 0 {
+		n++
+	}
+	if x.Weight != 0 {
+		n++
+	}
+	if len(x.Avatar) != 0 {
+		n++
+	}
+	if len(x.Tags) != 0 {
+		n++
+	}
+	if x.Meta != nil {
+		n++
+	}
+	if x.Work != nil {
+		n++
+	}
+	if x.M != nil && x.M.Len() != 0 {
+		n++
+	}
+
+	if options.OnlyStdClass {
+		dst = append(dst, "O:8:\"stdClass\":"...)
+	} else {
+		dst = append(dst, "O:7:\"Profile\":"...)
+	}
+
+	dst = strconv.AppendInt(dst, int64(n), 10)
+	dst = append(dst, ':', '{')
+
+	var err error
+
+	dst = append(dst, "s:2:\"iD\";"...)
+	dst = phpserialize.AppendInt(dst, int64(x.ID))
+
+	dst = append(dst, "s:4:\"name\";"...)
+	dst = phpserialize.AppendString(dst, x.Name)
+
+	if x.Nickname != nil {
+		dst = append(dst, "s:8:\"nickname\";"...)
+		dst = phpserialize.AppendString(dst, *x.Nickname)
+	}
+
+	if x.Age != 0 {
+		dst = append(dst, "s:3:\"age\";"...)
+		dst = phpserialize.AppendInt(dst, int64(x.Age))
+	}
+
+	dst = append(dst, "s:6:\"height\";"...)
+	dst = phpserialize.AppendFloat(dst, float64(x.Height), 64)
+
+	if x.Weight != 0 {
+		dst = append(dst, "s:6:\"weight\";"...)
+		dst = phpserialize.AppendFloat(dst, float64(x.Weight), 32)
+	}
+
+	dst = append(dst, "s:5:\"small\";"...)
+	dst = phpserialize.AppendInt(dst, int64(x.Small))
+
+	dst = append(dst, "s:3:\"big\";"...)
+	dst = phpserialize.AppendUint(dst, uint64(x.Big))
+
+	dst = append(dst, "s:9:\"is_active\";"...)
+	dst = phpserialize.AppendBool(dst, x.Active)
+
+	if len(x.Avatar) != 0 {
+		dst = append(dst, "s:6:\"avatar\";"...)
+		dst = phpserialize.AppendBytes(dst, x.Avatar)
+	}
+
+	dst = append(dst, "s:5:\"score\";"...)
+	if x.Score == nil {
+		dst = phpserialize.AppendNil(dst)
+	} else {
+		dst = phpserialize.AppendInt(dst, int64(*x.Score))
+	}
+
+	dst = append(dst, "s:5:\"email\";"...)
+	dst = phpserialize.AppendString(dst, x.Email)
+
+	dst = append(dst, "s:6:\"status\";"...)
+	dst, err = phpserialize.AppendMarshal(dst, x.Status, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(x.Tags) != 0 {
+		dst = append(dst, "s:4:\"tags\";"...)
+		dst, err = phpserialize.AppendMarshal(dst, x.Tags, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if x.Meta != nil {
+		dst = append(dst, "s:4:\"meta\";"...)
+		dst, err = phpserialize.AppendMarshal(dst, x.Meta, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dst = append(dst, "s:5:\"extra\";"...)
+	dst, err = phpserialize.AppendMarshal(dst, x.Extra, options)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, "s:7:\"created\";"...)
+	dst, err = phpserialize.AppendMarshal(dst, x.Created, options)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, "s:4:\"home\";"...)
+	dst, err = x.Home.MarshalPHP(dst, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if x.Work != nil {
+		dst = append(dst, "s:4:\"work\";"...)
+		dst, err = x.Work.MarshalPHP(dst, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dst = append(dst, "s:9:\"addresses\";"...)
+	dst, err = phpserialize.AppendMarshal(dst, x.Addresses, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if x.M != nil && x.M.Len() != 0 {
+		dst = append(dst, "s:1:\"m\";"...)
+		dst, err = phpserialize.AppendMarshal(dst, x.M, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+// UnmarshalPHP implements phpserialize.Unmarshaler.
+func (x *Profile) UnmarshalPHP(r *phpserialize.Reader) error {
+	options := r.Options()
+	if r.Peek() != 'O' || options.CaseInsensitive || options.FieldNamer != nil || options.DisallowUnknownFields {
+		return r.Decode(x)
+	}
+
+	_, n, err := r.ReadObjectHeader()
+	if err != nil {
+		return err
+	}
+
+	unknown := ""
+	hasUnknown := false
+	emailRank, emailOffset := 0, 0
+
+	for i := 0; i < n; i++ {
+		name, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "iD":
+			if r.Peek() == 'i' {
+				v, err := r.ReadInt()
+				if err != nil {
+					return err
+				}
+
+				x.ID = int(v)
+			} else if err := r.Decode(&x.ID); err != nil {
+				return phpserialize.FieldError(err, "ID")
+			}
+		case "name":
+			if r.Peek() == 's' {
+				v, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+
+				x.Name = v
+			} else if err := r.Decode(&x.Name); err != nil {
+				return phpserialize.FieldError(err, "Name")
+			}
+		case "nickname":
+			if r.Peek() == 's' {
+				v, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+
+				if x.Nickname == nil {
+					x.Nickname = new(string)
+				}
+
+				*x.Nickname = v
+			} else if err := r.Decode(&x.Nickname); err != nil {
+				return phpserialize.FieldError(err, "Nickname")
+			}
+		case "age":
+			if r.Peek() == 'i' {
+				v, err := r.ReadInt()
+				if err != nil {
+					return err
+				}
+
+				x.Age = v
+			} else if err := r.Decode(&x.Age); err != nil {
+				return phpserialize.FieldError(err, "Age")
+			}
+		case "height":
+			if r.Peek() == 'd' {
+				v, err := r.ReadFloat()
+				if err != nil {
+					return err
+				}
+
+				x.Height = v
+			} else if err := r.Decode(&x.Height); err != nil {
+				return phpserialize.FieldError(err, "Height")
+			}
+		case "weight":
+			if err := r.Decode(&x.Weight); err != nil {
+				return phpserialize.FieldError(err, "Weight")
+			}
+		case "small":
+			if err := r.Decode(&x.Small); err != nil {
+				return phpserialize.FieldError(err, "Small")
+			}
+		case "big":
+			if err := r.Decode(&x.Big); err != nil {
+				return phpserialize.FieldError(err, "Big")
+			}
+		case "is_active":
+			if r.Peek() == 'b' {
+				v, err := r.ReadBool()
+				if err != nil {
+					return err
+				}
+
+				x.Active = v
+			} else if err := r.Decode(&x.Active); err != nil {
+				return phpserialize.FieldError(err, "Active")
+			}
+		case "avatar":
+			if r.Peek() == 's' {
+				v, err := r.ReadBytes()
+				if err != nil {
+					return err
+				}
+
+				x.Avatar = v
+			} else if err := r.Decode(&x.Avatar); err != nil {
+				return phpserialize.FieldError(err, "Avatar")
+			}
+		case "score":
+			if r.Peek() == 'i' {
+				v, err := r.ReadInt()
+				if err != nil {
+					return err
+				}
+
+				if x.Score == nil {
+					x.Score = new(int)
+				}
+
+				*x.Score = int(v)
+			} else if err := r.Decode(&x.Score); err != nil {
+				return phpserialize.FieldError(err, "Score")
+			}
+		case "email":
+			emailRank = 1
+			if r.Peek() == 's' {
+				v, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+
+				x.Email = v
+			} else if err := r.Decode(&x.Email); err != nil {
+				return phpserialize.FieldError(err, "Email")
+			}
+		case "mail":
+			if emailRank == 0 || emailRank >= 2 {
+				emailRank, emailOffset = 2, r.Offset()
+			}
+
+			if err := r.Skip(); err != nil {
+				return err
+			}
+		case "e_mail":
+			if emailRank == 0 || emailRank >= 3 {
+				emailRank, emailOffset = 3, r.Offset()
+			}
+
+			if err := r.Skip(); err != nil {
+				return err
+			}
+		case "status":
+			if err := r.Decode(&x.Status); err != nil {
+				return phpserialize.FieldError(err, "Status")
+			}
+		case "tags":
+			if err := r.Decode(&x.Tags); err != nil {
+				return phpserialize.FieldError(err, "Tags")
+			}
+		case "meta":
+			if err := r.Decode(&x.Meta); err != nil {
+				return phpserialize.FieldError(err, "Meta")
+			}
+		case "extra":
+			if err := r.Decode(&x.Extra); err != nil {
+				return phpserialize.FieldError(err, "Extra")
+			}
+		case "created":
+			if err := r.Decode(&x.Created); err != nil {
+				return phpserialize.FieldError(err, "Created")
+			}
+		case "home":
+			if err := x.Home.UnmarshalPHP(r); err != nil {
+				return phpserialize.FieldError(err, "Home")
+			}
+		case "work":
+			if r.Peek() == 'O' {
+				if x.Work == nil {
+					x.Work = new(Address)
+				}
+
+				if err := x.Work.UnmarshalPHP(r); err != nil {
+					return phpserialize.FieldError(err, "Work")
+				}
+			} else if err := r.Decode(&x.Work); err != nil {
+				return phpserialize.FieldError(err, "Work")
+			}
+		case "addresses":
+			if err := r.Decode(&x.Addresses); err != nil {
+				return phpserialize.FieldError(err, "Addresses")
+			}
+		case "m":
+			if err := r.Decode(&x.M); err != nil {
+				return phpserialize.FieldError(err, "M")
+			}
+		default:
+			if options.DisallowUnknownFields && !hasUnknown {
+				unknown, hasUnknown = name, true
+			}
+
+			if err := r.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := r.ReadEnd(); err != nil {
+		return err
+	}
+
+	if emailRank > 1 {
+		end := r.Offset()
+		r.Seek(emailOffset)
+
+		if r.Peek() == 's' {
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+
+			x.Email = v
+		} else if err := r.Decode(&x.Email); err != nil {
+			return phpserialize.FieldError(err, "Email")
+		}
+
+		r.Seek(end)
+	}
+
+	if hasUnknown {
+		return fmt.Errorf("unknown property %v for type %T", unknown, *x)
+	}
+
+	return nil
+}
+
+// MarshalPHP implements phpserialize.Marshaler.
+func (x Address) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = phpserialize.DefaultMarshalOptions()
+	}
+
+	if options.FieldNamer != nil {
+		m, err := phpserialize.MarshalStruct(x, options)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, m...), nil
+	}
+
+	n := 3
+
+	if options.OnlyStdClass {
+		dst = append(dst, "O:8:\"stdClass\":"...)
+	} else {
+		dst = append(dst, "O:7:\"Address\":"...)
+	}
+
+	dst = strconv.AppendInt(dst, int64(n), 10)
+	dst = append(dst, ':', '{')
+
+	dst = append(dst, "s:6:\"street\";"...)
+	dst = phpserialize.AppendString(dst, x.Street)
+
+	dst = append(dst, "s:4:\"city\";"...)
+	dst = phpserialize.AppendString(dst, x.City)
+
+	dst = append(dst, "s:8:\"postcode\";"...)
+	if x.Postcode == nil {
+		dst = phpserialize.AppendNil(dst)
+	} else {
+		dst = phpserialize.AppendInt(dst, int64(*x.Postcode))
+	}
+
+	return append(dst, '}'), nil
+}
+
+// UnmarshalPHP implements phpserialize.Unmarshaler.
+func (x *Address) UnmarshalPHP(r *phpserialize.Reader) error {
+	options := r.Options()
+	if r.Peek() != 'O' || options.CaseInsensitive || options.FieldNamer != nil || options.DisallowUnknownFields {
+		return r.Decode(x)
+	}
+
+	_, n, err := r.ReadObjectHeader()
+	if err != nil {
+		return err
+	}
+
+	unknown := ""
+	hasUnknown := false
+	cityRank, cityOffset := 0, 0
+
+	for i := 0; i < n; i++ {
+		name, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "street":
+			if r.Peek() == 's' {
+				v, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+
+				x.Street = v
+			} else if err := r.Decode(&x.Street); err != nil {
+				return phpserialize.FieldError(err, "Street")
+			}
+		case "city":
+			cityRank = 1
+			if r.Peek() == 's' {
+				v, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+
+				x.City = v
+			} else if err := r.Decode(&x.City); err != nil {
+				return phpserialize.FieldError(err, "City")
+			}
+		case "town":
+			if cityRank == 0 || cityRank >= 2 {
+				cityRank, cityOffset = 2, r.Offset()
+			}
+
+			if err := r.Skip(); err != nil {
+				return err
+			}
+		case "postcode":
+			if r.Peek() == 'i' {
+				v, err := r.ReadInt()
+				if err != nil {
+					return err
+				}
+
+				if x.Postcode == nil {
+					x.Postcode = new(int)
+				}
+
+				*x.Postcode = int(v)
+			} else if err := r.Decode(&x.Postcode); err != nil {
+				return phpserialize.FieldError(err, "Postcode")
+			}
+		default:
+			if options.DisallowUnknownFields && !hasUnknown {
+				unknown, hasUnknown = name, true
+			}
+
+			if err := r.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := r.ReadEnd(); err != nil {
+		return err
+	}
+
+	if cityRank > 1 {
+		end := r.Offset()
+		r.Seek(cityOffset)
+
+		if r.Peek() == 's' {
+			v, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+
+			x.City = v
+		} else if err := r.Decode(&x.City); err != nil {
+			return phpserialize.FieldError(err, "City")
+		}
+
+		r.Seek(end)
+	}
+
+	if hasUnknown {
+		return fmt.Errorf("unknown property %v for type %T", unknown, *x)
+	}
+
+	return nil
+}
+
+// MarshalPHP implements phpserialize.Marshaler.
+func (x Counter) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = phpserialize.DefaultMarshalOptions()
+	}
+
+	if options.FieldNamer != nil {
+		m, err := phpserialize.MarshalStruct(x, options)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, m...), nil
+	}
+
+	n := 4
+
+	if options.OnlyStdClass {
+		dst = append(dst, "O:8:\"stdClass\":"...)
+	} else {
+		dst = append(dst, "O:7:\"Counter\":"...)
+	}
+
+	dst = strconv.AppendInt(dst, int64(n), 10)
+	dst = append(dst, ':', '{')
+
+	dst = append(dst, "s:4:\"hits\";"...)
+	dst = phpserialize.AppendString(dst, strconv.FormatInt(int64(x.Hits), 10))
+
+	dst = append(dst, "s:5:\"ratio\";"...)
+	dst = phpserialize.AppendString(dst, strconv.FormatFloat(float64(x.Ratio), 'f', -1, 64))
+
+	dst = append(dst, "s:6:\"enable\";"...)
+	if x.Enable == nil {
+		dst = phpserialize.AppendNil(dst)
+	} else {
+		dst = phpserialize.AppendString(dst, strconv.FormatBool(*x.Enable))
+	}
+
+	dst = append(dst, "s:5:\"label\";"...)
+	dst = phpserialize.AppendString(dst, x.Label)
+
+	return append(dst, '}'), nil
+}
+
+// UnmarshalPHP implements phpserialize.Unmarshaler.
+func (x *Counter) UnmarshalPHP(r *phpserialize.Reader) error {
+	return r.Decode(x)
+}
+
+// MarshalPHP implements phpserialize.Marshaler.
+func (x Legacy) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = phpserialize.DefaultMarshalOptions()
+	}
+
+	m, err := phpserialize.MarshalStruct(x, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dst, m...), nil
+}
+
+// UnmarshalPHP implements phpserialize.Unmarshaler.
+func (x *Legacy) UnmarshalPHP(r *phpserialize.Reader) error {
+	return r.Decode(x)
+}
+
+// MarshalPHP implements phpserialize.Marshaler.
+func (x Embedding) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = phpserialize.DefaultMarshalOptions()
+	}
+
+	m, err := phpserialize.MarshalStruct(x, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dst, m...), nil
+}
+
+// UnmarshalPHP implements phpserialize.Unmarshaler.
+func (x *Embedding) UnmarshalPHP(r *phpserialize.Reader) error {
+	return r.Decode(x)
+}
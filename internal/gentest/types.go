@@ -0,0 +1,75 @@
+// Package gentest holds structs with methods generated by phpserialize-gen.
+// The tests check that the generated methods behave in exactly the same way
+// as encoding and decoding the structs with reflection.
+package gentest
+
+import (
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+//go:generate go run ../../cmd/phpserialize-gen -type Profile,Address,Counter,Legacy,Embedding
+
+// Status is a named type that is not generated, so it is encoded by
+// phpserialize.
+type Status int
+
+type Profile struct {
+	ID       int
+	Name     string
+	Nickname *string `php:"nickname,omitnilptr"`
+	Age      int64   `php:",omitempty"`
+	Height   float64
+	Weight   float32 `php:",omitzero"`
+	Small    int8
+	Big      uint64 `php:"big"`
+	Active   bool   `php:"is_active"`
+	Avatar   []byte `php:",omitempty"`
+	Score    *int
+	Email    string `php:"email,alias=mail|e_mail"`
+
+	Status    Status
+	Tags      []string          `php:",omitempty"`
+	Meta      map[string]string `php:",omitzero"`
+	Extra     interface{}
+	Created   time.Time
+	Home      Address
+	Work      *Address `php:",omitempty"`
+	Addresses []Address
+	M         *orderedmap.OrderedMap[any, any] `php:",omitempty"`
+
+	Ignored string `php:"-"`
+	private int
+}
+
+type Address struct {
+	Street   string
+	City     string `php:"city,alias=town"`
+	Postcode *int
+}
+
+// Counter has fields with the string option, so it is only encoded without
+// reflection.
+type Counter struct {
+	Hits   int     `php:"hits,string"`
+	Ratio  float64 `php:",string"`
+	Enable *bool   `php:",string"`
+	Label  string
+}
+
+// Legacy uses a tag option that the generator does not handle, so both of its
+// methods fall back to reflection.
+type Legacy struct {
+	When time.Time `php:"when,unix"`
+}
+
+type Base struct {
+	Kind string
+}
+
+// Embedding embeds a struct, so both of its methods fall back to reflection.
+type Embedding struct {
+	Base
+	Value int
+}
@@ -0,0 +1,276 @@
+package phpserialize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+var (
+	dateIntervalType = reflect.TypeOf(DateInterval{})
+	durationType     = reflect.TypeOf(time.Duration(0))
+)
+
+// DateInterval is a PHP DateInterval. It is encoded with the properties used
+// by PHP 8.2 and later. Objects from older versions of PHP, which have some
+// extra properties, can also be decoded.
+type DateInterval struct {
+	// Years, Months, Days, Hours, Minutes and Seconds are the y, m, d, h, i
+	// and s properties.
+	Years   int
+	Months  int
+	Days    int
+	Hours   int
+	Minutes int
+	Seconds int
+
+	// Fraction is the f property, which is the fraction of a second.
+	Fraction float64
+
+	// Invert is true if the interval is negative.
+	Invert bool
+
+	// TotalDays is the days property. It is only known when the interval
+	// was created by DateTime::diff(), otherwise it is nil (false in PHP).
+	TotalDays *int
+
+	// DateString is set for an interval that was created by
+	// DateInterval::createFromDateString() in PHP 8.2 or later, such as
+	// "last day of next month". None of the other properties are used when it
+	// is not empty.
+	DateString string
+}
+
+// NewDateInterval creates a DateInterval with the hours, minutes, seconds and
+// fraction of a second of d. Durations longer than a day are still expressed
+// in hours, since a day is not always 24 hours long.
+func NewDateInterval(d time.Duration) DateInterval {
+	var interval DateInterval
+	if d < 0 {
+		interval.Invert = true
+		d = -d
+	}
+
+	interval.Hours = int(d / time.Hour)
+	interval.Minutes = int(d % time.Hour / time.Minute)
+	interval.Seconds = int(d % time.Minute / time.Second)
+	interval.Fraction = float64(d%time.Second) / float64(time.Second)
+
+	return interval
+}
+
+// Duration converts the interval into a time.Duration. A day is counted as 24
+// hours. It returns an error for an interval that has years or months, unless
+// TotalDays is known, since their length depends on the date they are added to.
+func (i DateInterval) Duration() (time.Duration, error) {
+	if i.DateString != "" {
+		return 0, fmt.Errorf("can not convert DateInterval %q to a duration", i.DateString)
+	}
+
+	days := i.Days
+	if i.TotalDays != nil {
+		days = *i.TotalDays
+	} else if i.Years != 0 || i.Months != 0 {
+		return 0, errors.New("can not convert DateInterval with years or months to a duration")
+	}
+
+	d := time.Duration(days)*24*time.Hour +
+		time.Duration(i.Hours)*time.Hour +
+		time.Duration(i.Minutes)*time.Minute +
+		time.Duration(i.Seconds)*time.Second +
+		time.Duration(math.Round(i.Fraction*float64(time.Second)))
+
+	if i.Invert {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// MarshalDateInterval returns the bytes that represent a PHP DateInterval
+// object. The OnlyStdClass option is respected.
+func MarshalDateInterval(i DateInterval, options *MarshalOptions) []byte {
+	if options == nil {
+		options = DefaultMarshalOptions()
+	}
+
+	className := "DateInterval"
+	if options.OnlyStdClass {
+		className = "stdClass"
+	}
+
+	var buffer bytes.Buffer
+	length := 2
+
+	if i.DateString != "" {
+		buffer.Write(MarshalString("from_string"))
+		buffer.Write(MarshalBool(true))
+		buffer.Write(MarshalString("date_string"))
+		buffer.Write(MarshalString(i.DateString))
+	} else {
+		length = 10
+
+		for _, p := range []struct {
+			name  string
+			value int
+		}{
+			{"y", i.Years},
+			{"m", i.Months},
+			{"d", i.Days},
+			{"h", i.Hours},
+			{"i", i.Minutes},
+			{"s", i.Seconds},
+		} {
+			buffer.Write(MarshalString(p.name))
+			buffer.Write(MarshalInt(int64(p.value)))
+		}
+
+		buffer.Write(MarshalString("f"))
+		buffer.Write(MarshalFloat(i.Fraction, 64))
+
+		invert := int64(0)
+		if i.Invert {
+			invert = 1
+		}
+
+		buffer.Write(MarshalString("invert"))
+		buffer.Write(MarshalInt(invert))
+
+		buffer.Write(MarshalString("days"))
+		if i.TotalDays != nil {
+			buffer.Write(MarshalInt(int64(*i.TotalDays)))
+		} else {
+			buffer.Write(MarshalBool(false))
+		}
+
+		buffer.Write(MarshalString("from_string"))
+		buffer.Write(MarshalBool(false))
+	}
+
+	return []byte(fmt.Sprintf("O:%d:\"%s\":%d:{%s}", len(className), className,
+		length, buffer.String()))
+}
+
+// setDateInterval decodes a PHP DateInterval object into a DateInterval.
+func setDateInterval(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	i, err := parseDateInterval(value, v.Type(), options)
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.ValueOf(i))
+
+	return nil
+}
+
+func parseDateInterval(value interface{}, t reflect.Type, options *UnmarshalOptions) (DateInterval, error) {
+	var properties *orderedmap.OrderedMap[any, any]
+	switch v := value.(type) {
+	case *Object:
+		properties = v.Properties
+
+	case *orderedmap.OrderedMap[any, any]:
+		properties = v
+
+	default:
+		return DateInterval{}, newUnmarshalTypeError(value, t)
+	}
+
+	var i DateInterval
+
+	if fromString, _ := properties.Get("from_string"); fromString == true {
+		dateString, _ := properties.Get("date_string")
+		s, ok := dateString.(string)
+		if !ok || s == "" {
+			return DateInterval{}, newUnmarshalTypeError(value, t)
+		}
+
+		i.DateString = s
+
+		return i, nil
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"y", &i.Years},
+		{"m", &i.Months},
+		{"d", &i.Days},
+		{"h", &i.Hours},
+		{"i", &i.Minutes},
+		{"s", &i.Seconds},
+	} {
+		property, _ := properties.Get(p.name)
+		n, ok := coerceInt(property, options.Coercion)
+		if !ok {
+			return DateInterval{}, newUnmarshalTypeError(value, t)
+		}
+
+		*p.value = int(n)
+	}
+
+	// The fraction and invert were added in later versions of PHP, so they
+	// may be missing.
+	if f, ok := properties.Get("f"); ok {
+		if i.Fraction, ok = coerceFloat(f, CoercePHP); !ok {
+			return DateInterval{}, newUnmarshalTypeError(value, t)
+		}
+	}
+
+	if invert, ok := properties.Get("invert"); ok {
+		if i.Invert, ok = coerceBool(invert, CoercePHP); !ok {
+			return DateInterval{}, newUnmarshalTypeError(value, t)
+		}
+	}
+
+	// days is false unless the interval came from DateTime::diff().
+	if days, _ := properties.Get("days"); days != false && days != nil {
+		totalDays, ok := coerceInt(days, options.Coercion)
+		if !ok {
+			return DateInterval{}, newUnmarshalTypeError(value, t)
+		}
+
+		n := int(totalDays)
+		i.TotalDays = &n
+	}
+
+	return i, nil
+}
+
+// newDurationEncoder returns the field encoder for a time.Duration with the
+// "interval" tag option.
+func newDurationEncoder() fieldEncoder {
+	return func(dst []byte, v reflect.Value, options *MarshalOptions) ([]byte, error) {
+		return append(dst, MarshalDateInterval(NewDateInterval(time.Duration(v.Int())), options)...), nil
+	}
+}
+
+// newDurationDecoder returns the field decoder for a time.Duration with the
+// "interval" tag option.
+func newDurationDecoder() fieldDecoder {
+	return func(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+		if value == nil {
+			return nil
+		}
+
+		i, err := parseDateInterval(value, v.Type(), options)
+		if err != nil {
+			return err
+		}
+
+		d, err := i.Duration()
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+}
@@ -0,0 +1,216 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+const phpDateInterval = `O:12:"DateInterval":10:{s:1:"y";i:0;s:1:"m";i:0;s:1:"d";i:1;s:1:"h";i:2;s:1:"i";i:30;s:1:"s";i:0;s:1:"f";d:0.5;s:6:"invert";i:1;s:4:"days";b:0;s:11:"from_string";b:0;}`
+
+type schedule struct {
+	Every   time.Duration `php:"every,interval"`
+	Timeout time.Duration `php:"timeout"`
+}
+
+func TestMarshalDateInterval(t *testing.T) {
+	interval := phpserialize.DateInterval{Days: 1, Hours: 2, Minutes: 30, Fraction: 0.5, Invert: true}
+
+	result, err := phpserialize.Marshal(interval, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != phpDateInterval {
+		t.Errorf("Expected '%s', got '%s'", phpDateInterval, result)
+	}
+
+	var decoded phpserialize.DateInterval
+	err = phpserialize.Unmarshal(result, &decoded)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !reflect.DeepEqual(decoded, interval) {
+		t.Errorf("Expected %+v, got %+v", interval, decoded)
+	}
+}
+
+func TestUnmarshalDateInterval(t *testing.T) {
+	totalDays := 400
+
+	tests := map[string]struct {
+		input    string
+		expected phpserialize.DateInterval
+	}{
+		"from diff": {
+			`O:12:"DateInterval":10:{s:1:"y";i:1;s:1:"m";i:1;s:1:"d";i:4;s:1:"h";i:0;s:1:"i";i:0;s:1:"s";i:0;s:1:"f";d:0;s:6:"invert";i:0;s:4:"days";i:400;s:11:"from_string";b:0;}`,
+			phpserialize.DateInterval{Years: 1, Months: 1, Days: 4, TotalDays: &totalDays},
+		},
+		"PHP 8.1": {
+			`O:12:"DateInterval":16:{s:1:"y";i:0;s:1:"m";i:0;s:1:"d";i:3;s:1:"h";i:0;s:1:"i";i:0;s:1:"s";i:0;s:1:"f";d:0;s:7:"weekday";i:0;s:16:"weekday_behavior";i:0;s:17:"first_last_day_of";i:0;s:6:"invert";i:0;s:4:"days";b:0;s:12:"special_type";i:0;s:14:"special_amount";i:0;s:21:"have_weekday_relative";i:0;s:21:"have_special_relative";i:0;}`,
+			phpserialize.DateInterval{Days: 3},
+		},
+		"from string": {
+			`O:12:"DateInterval":2:{s:11:"from_string";b:1;s:11:"date_string";s:8:"next day";}`,
+			phpserialize.DateInterval{DateString: "next day"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result phpserialize.DateInterval
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Expected %+v, got %+v", test.expected, result)
+			}
+
+			if test.expected.DateString != "" {
+				encoded, err := phpserialize.Marshal(result, nil)
+				expectErrorToNotHaveOccurred(t, err)
+
+				if string(encoded) != test.input {
+					t.Errorf("Expected '%s', got '%s'", test.input, encoded)
+				}
+			}
+		})
+	}
+}
+
+func TestDateIntervalDuration(t *testing.T) {
+	totalDays := 32
+
+	tests := map[string]struct {
+		interval      phpserialize.DateInterval
+		duration      time.Duration
+		expectedError error
+	}{
+		"time": {
+			phpserialize.DateInterval{Hours: 1, Minutes: 2, Seconds: 3, Fraction: 0.25},
+			time.Hour + 2*time.Minute + 3*time.Second + 250*time.Millisecond,
+			nil,
+		},
+		"days": {
+			phpserialize.DateInterval{Days: 2, Invert: true},
+			-48 * time.Hour,
+			nil,
+		},
+		"total days": {
+			phpserialize.DateInterval{Months: 1, Days: 1, TotalDays: &totalDays},
+			32 * 24 * time.Hour,
+			nil,
+		},
+		"months": {
+			phpserialize.DateInterval{Months: 1},
+			0,
+			errors.New("can not convert DateInterval with years or months to a duration"),
+		},
+		"date string": {
+			phpserialize.DateInterval{DateString: "next day"},
+			0,
+			errors.New(`can not convert DateInterval "next day" to a duration`),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := test.interval.Duration()
+			if test.expectedError != nil {
+				expectErrorToEqual(t, err, test.expectedError)
+				return
+			}
+
+			expectErrorToNotHaveOccurred(t, err)
+
+			if result != test.duration {
+				t.Errorf("Expected %v, got %v", test.duration, result)
+			}
+
+			if test.interval.TotalDays == nil && test.interval.Days == 0 {
+				if back := phpserialize.NewDateInterval(result); !reflect.DeepEqual(back, test.interval) {
+					t.Errorf("Expected %+v, got %+v", test.interval, back)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalDurationInterval(t *testing.T) {
+	input := schedule{Every: -(26*time.Hour + 30*time.Minute + 500*time.Millisecond), Timeout: time.Second}
+
+	result, err := phpserialize.Marshal(input, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `O:8:"schedule":2:{s:5:"every";O:12:"DateInterval":10:{s:1:"y";i:0;s:1:"m";i:0;s:1:"d";i:0;s:1:"h";i:26;s:1:"i";i:30;s:1:"s";i:0;s:1:"f";d:0.5;s:6:"invert";i:1;s:4:"days";b:0;s:11:"from_string";b:0;}s:7:"timeout";i:1000000000;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var decoded schedule
+	err = phpserialize.Unmarshal(result, &decoded)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if decoded != input {
+		t.Errorf("Expected %+v, got %+v", input, decoded)
+	}
+
+	err = phpserialize.Unmarshal([]byte(`O:8:"schedule":1:{s:5:"every";O:12:"DateInterval":10:{s:1:"y";i:0;s:1:"m";i:2;s:1:"d";i:0;s:1:"h";i:0;s:1:"i";i:0;s:1:"s";i:0;s:1:"f";d:0;s:6:"invert";i:0;s:4:"days";b:0;s:11:"from_string";b:0;}}`), &decoded)
+	expectErrorToEqual(t, err, errors.New("can not convert DateInterval with years or months to a duration"))
+}
+
+func TestDateTimeZone(t *testing.T) {
+	input := `O:12:"DateTimeZone":2:{s:13:"timezone_type";i:3;s:8:"timezone";s:13:"Europe/London";}`
+
+	var zone phpserialize.DateTimeZone
+	err := phpserialize.Unmarshal([]byte(input), &zone)
+	expectErrorToNotHaveOccurred(t, err)
+
+	location, err := zone.Location()
+	expectErrorToNotHaveOccurred(t, err)
+
+	if location.String() != "Europe/London" {
+		t.Errorf("Expected Europe/London, got %s", location)
+	}
+
+	result, err := phpserialize.Marshal(phpserialize.NewDateTimeZone(location), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != input {
+		t.Errorf("Expected '%s', got '%s'", input, result)
+	}
+
+	zone = phpserialize.NewDateTimeZone(time.FixedZone("", -90*60))
+	if zone != (phpserialize.DateTimeZone{TimezoneType: 1, Timezone: "-01:30"}) {
+		t.Errorf("Expected an offset, got %+v", zone)
+	}
+}
+
+func TestDatePeriod(t *testing.T) {
+	input := `O:10:"DatePeriod":7:{` +
+		`s:5:"start";O:8:"DateTime":3:{s:4:"date";s:26:"2024-01-01 00:00:00.000000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}` +
+		`s:7:"current";N;s:3:"end";N;` +
+		`s:8:"interval";O:12:"DateInterval":10:{s:1:"y";i:0;s:1:"m";i:0;s:1:"d";i:7;s:1:"h";i:0;s:1:"i";i:0;s:1:"s";i:0;s:1:"f";d:0;s:6:"invert";i:0;s:4:"days";b:0;s:11:"from_string";b:0;}` +
+		`s:11:"recurrences";i:5;s:18:"include_start_date";b:1;s:16:"include_end_date";b:0;}`
+
+	var period phpserialize.DatePeriod
+	err := phpserialize.Unmarshal([]byte(input), &period)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := phpserialize.DatePeriod{
+		Start:            time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Interval:         &phpserialize.DateInterval{Days: 7},
+		Recurrences:      5,
+		IncludeStartDate: true,
+	}
+	if !reflect.DeepEqual(period, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, period)
+	}
+
+	result, err := phpserialize.Marshal(period, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != input {
+		t.Errorf("Expected '%s', got '%s'", input, result)
+	}
+}
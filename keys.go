@@ -0,0 +1,134 @@
+package phpserialize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// normalizeKey converts a decoded array key in the same way as PHP does when
+// a value is used as an array key:
+//
+//   - A string that contains a decimal integer (without a plus sign or
+//     leading zeros) becomes an integer, so "123" is 123 but "0123" is not.
+//   - A float is truncated to an integer.
+//   - A bool becomes 0 or 1.
+//   - A null becomes "".
+//
+// The result is always an int64 or a string. Any other type of value can not
+// be an array key and returns an error.
+func normalizeKey(key interface{}) (interface{}, error) {
+	if n, ok := key.(Number); ok {
+		key = n.value()
+	}
+
+	switch k := key.(type) {
+	case int64:
+		return k, nil
+
+	case string:
+		if i, ok := integerKey(k); ok {
+			return i, nil
+		}
+
+		return k, nil
+
+	case float64:
+		if math.IsNaN(k) || math.IsInf(k, 0) || k < -(1<<63) || k >= 1<<63 {
+			return int64(0), nil
+		}
+
+		return int64(k), nil
+
+	case bool:
+		if k {
+			return int64(1), nil
+		}
+
+		return int64(0), nil
+
+	case nil:
+		return "", nil
+	}
+
+	return nil, fmt.Errorf("can not use PHP %s as an array key", phpTypeName(key))
+}
+
+// integerKey returns the integer for a string that PHP would use as an integer
+// array key.
+func integerKey(s string) (int64, bool) {
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+
+	if digits == "" || len(digits) > 19 || (digits[0] == '0' && len(s) > 1) {
+		return 0, false
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, false
+		}
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+
+	return i, err == nil
+}
+
+// normalizeEntries normalizes the keys of an array with normalizeKey. Keys
+// that become the same are only kept once, in the position of the first one
+// and with the last value, in the same way as PHP.
+func normalizeEntries(keys, values []interface{}, options *MarshalOptions) ([]interface{}, []interface{}, error) {
+	d := newDecoder(DefaultUnmarshalOptions(), true)
+
+	normalizedKeys := make([]interface{}, 0, len(keys))
+	normalizedValues := make([]interface{}, 0, len(values))
+	positions := make(map[interface{}]int, len(keys))
+
+	for i, key := range keys {
+		normalized, err := normalizeMapKey(d, key, options)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if position, ok := positions[normalized]; ok {
+			normalizedValues[position] = values[i]
+			continue
+		}
+
+		positions[normalized] = len(normalizedKeys)
+		normalizedKeys = append(normalizedKeys, normalized)
+		normalizedValues = append(normalizedValues, values[i])
+	}
+
+	return normalizedKeys, normalizedValues, nil
+}
+
+// normalizeMapKey normalizes a Go map key with normalizeKey. Scalars and
+// TextMarshalers are used directly, so that a string key is never changed.
+func normalizeMapKey(d *decoder, key interface{}, options *MarshalOptions) (interface{}, error) {
+	scalar, ok, err := mapKeyScalar(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return normalizeKey(scalar)
+	}
+
+	// Any other key, such as a *big.Int or a struct, is encoded and
+	// decoded again to find the PHP value that it becomes.
+	m, err := Marshal(key, options)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := d.consumeNext(m, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeKey(decoded)
+}
@@ -0,0 +1,106 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+func TestMarshalNormalizeKeys(t *testing.T) {
+	options := phpserialize.DefaultMarshalOptions()
+	options.NormalizeKeys = true
+
+	tests := map[string]struct {
+		input  interface{}
+		output string
+	}{
+		"numeric strings": {
+			map[string]int{"1": 1, "-5": 2, "10": 3, "9": 4},
+			`a:4:{i:-5;i:2;i:1;i:1;i:9;i:4;i:10;i:3;}`,
+		},
+		"strings that stay strings": {
+			map[string]int{"01": 1, "+1": 2, "1.5": 3, "-0": 4, " 1": 5, "9223372036854775808": 6},
+			`a:6:{s:2:" 1";i:5;s:2:"+1";i:2;s:2:"-0";i:4;s:2:"01";i:1;s:3:"1.5";i:3;s:19:"9223372036854775808";i:6;}`,
+		},
+		"floats": {
+			map[float64]string{1.9: "a", -2.5: "b"},
+			`a:2:{i:-2;s:1:"b";i:1;s:1:"a";}`,
+		},
+		"bools": {
+			map[bool]string{true: "a", false: "b"},
+			`a:2:{i:0;s:1:"b";i:1;s:1:"a";}`,
+		},
+		"duplicates": {
+			map[interface{}]string{"1": "a", 1: "a"},
+			`a:1:{i:1;s:1:"a";}`,
+		},
+		"null": {
+			map[interface{}]int{nil: 1},
+			`a:1:{s:0:"";i:1;}`,
+		},
+		"backslashes": {
+			map[string]int{`a\x41`: 1, "aA": 2, `C:\x41\n`: 3},
+			`a:3:{s:8:"C:\x41\n";i:3;s:2:"aA";i:2;s:5:"a\x41";i:1;}`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := phpserialize.Marshal(test.input, options)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.output {
+				t.Errorf("Expected '%s', got '%s'", test.output, result)
+			}
+		})
+	}
+
+	// Without the option the keys are unchanged.
+	result, err := phpserialize.Marshal(map[string]int{"1": 1}, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != `a:1:{s:1:"1";i:1;}` {
+		t.Errorf("Expected the key to be a string, got '%s'", result)
+	}
+
+	_, err = phpserialize.Marshal(map[interface{}]int{Circle{}: 1}, options)
+	expectErrorToEqual(t, err, errors.New("can not use PHP object of class Circle as an array key"))
+}
+
+func TestUnmarshalNormalizeKeys(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.NormalizeKeys = true
+
+	var result *orderedmap.OrderedMap[any, any]
+	err := phpserialize.UnmarshalWithOptions([]byte(
+		`a:5:{s:1:"1";s:1:"a";i:1;s:1:"b";s:2:"01";s:1:"c";d:2.5;s:1:"d";N;s:1:"e";}`), &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !equalKeys(orderedKeys(result), []interface{}{int64(1), "01", int64(2), ""}) {
+		t.Errorf("Unexpected keys %v", orderedKeys(result))
+	}
+
+	// The later value replaces the earlier one, but keeps its position.
+	if v, _ := result.Get(int64(1)); v != "b" {
+		t.Errorf("Expected 1 to be b, got %v", v)
+	}
+
+	var m map[int]string
+	err = phpserialize.UnmarshalWithOptions([]byte(`a:2:{s:1:"7";s:1:"a";s:2:"-3";s:1:"b";}`), &m, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if m[7] != "a" || m[-3] != "b" {
+		t.Errorf("Expected map[7:a -3:b], got %v", m)
+	}
+
+	// Object properties are never normalized.
+	var o map[string]int
+	err = phpserialize.UnmarshalWithOptions([]byte(`O:8:"stdClass":1:{s:1:"1";i:1;}`), &o, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if o["1"] != 1 {
+		t.Errorf("Expected map[1:1], got %v", o)
+	}
+}
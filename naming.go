@@ -0,0 +1,82 @@
+package phpserialize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FieldNamer converts the name of a Go struct field into the name of the PHP
+// property it is encoded as, and decoded from. It is not used for fields that
+// have a name in their "php" tag.
+//
+// Any function with the right signature can be used, or one of the provided
+// namers: LowerFirstNamer (the default), CamelCaseNamer, SnakeCaseNamer and
+// ExactNamer.
+type FieldNamer func(fieldName string) string
+
+// LowerFirstNamer converts the first letter to lowercase and leaves the rest of
+// the name as it is, for example "UserID" becomes "userID". This is the default
+// FieldNamer.
+func LowerFirstNamer(fieldName string) string {
+	return lowerCaseFirstLetter(fieldName)
+}
+
+// CamelCaseNamer converts the name to camelCase, treating acronyms as words.
+// For example "UserID" becomes "userId" and "HTTPServer" becomes "httpServer".
+func CamelCaseNamer(fieldName string) string {
+	words := splitWords(fieldName)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 {
+			word = upperCaseFirstLetter(word)
+		}
+		words[i] = word
+	}
+
+	return strings.Join(words, "")
+}
+
+// SnakeCaseNamer converts the name to snake_case. For example "UserID" becomes
+// "user_id" and "HTTPServer" becomes "http_server".
+func SnakeCaseNamer(fieldName string) string {
+	words := splitWords(fieldName)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+
+	return strings.Join(words, "_")
+}
+
+// ExactNamer uses the name of the Go field without changing it.
+func ExactNamer(fieldName string) string {
+	return fieldName
+}
+
+// splitWords splits a Go identifier into words at each change of case. A run
+// of uppercase letters is treated as a single word (an acronym), except for its
+// last letter if that starts a new word. Digits belong to the word before
+// them. Underscores are also treated as word boundaries.
+func splitWords(s string) []string {
+	runes := []rune(s)
+
+	var words []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes)
+		if !boundary {
+			prev, r := runes[i-1], runes[i]
+			boundary = r == '_' || prev == '_' ||
+				(unicode.IsUpper(r) && !unicode.IsUpper(prev)) ||
+				(unicode.IsUpper(r) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+		}
+
+		if boundary {
+			if word := strings.Trim(string(runes[start:i]), "_"); word != "" {
+				words = append(words, word)
+			}
+			start = i
+		}
+	}
+
+	return words
+}
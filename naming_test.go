@@ -0,0 +1,110 @@
+package phpserialize_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type namedFields struct {
+	UserID     int
+	HTTPServer string
+	Foo2Bar    bool
+	Tagged     int `php:"TAG"`
+}
+
+var namerTests = map[string]struct {
+	namer  phpserialize.FieldNamer
+	output string
+}{
+	"LowerFirstNamer": {
+		phpserialize.LowerFirstNamer,
+		`O:11:"namedFields":4:{s:6:"userID";i:1;s:10:"hTTPServer";s:1:"a";s:7:"foo2Bar";b:1;s:3:"TAG";i:2;}`,
+	},
+	"CamelCaseNamer": {
+		phpserialize.CamelCaseNamer,
+		`O:11:"namedFields":4:{s:6:"userId";i:1;s:10:"httpServer";s:1:"a";s:7:"foo2Bar";b:1;s:3:"TAG";i:2;}`,
+	},
+	"SnakeCaseNamer": {
+		phpserialize.SnakeCaseNamer,
+		`O:11:"namedFields":4:{s:7:"user_id";i:1;s:11:"http_server";s:1:"a";s:8:"foo2_bar";b:1;s:3:"TAG";i:2;}`,
+	},
+	"ExactNamer": {
+		phpserialize.ExactNamer,
+		`O:11:"namedFields":4:{s:6:"UserID";i:1;s:10:"HTTPServer";s:1:"a";s:7:"Foo2Bar";b:1;s:3:"TAG";i:2;}`,
+	},
+	"custom": {
+		strings.ToUpper,
+		`O:11:"namedFields":4:{s:6:"USERID";i:1;s:10:"HTTPSERVER";s:1:"a";s:7:"FOO2BAR";b:1;s:3:"TAG";i:2;}`,
+	},
+}
+
+func TestFieldNamer(t *testing.T) {
+	input := namedFields{1, "a", true, 2}
+
+	for testName, test := range namerTests {
+		t.Run(testName, func(t *testing.T) {
+			marshalOptions := phpserialize.DefaultMarshalOptions()
+			marshalOptions.FieldNamer = test.namer
+
+			result, err := phpserialize.Marshal(input, marshalOptions)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.output {
+				t.Errorf("Expected '%s', got '%s'", test.output, result)
+			}
+
+			unmarshalOptions := phpserialize.DefaultUnmarshalOptions()
+			unmarshalOptions.FieldNamer = test.namer
+
+			var decoded namedFields
+			err = phpserialize.UnmarshalWithOptions(result, &decoded, unmarshalOptions)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if !reflect.DeepEqual(decoded, input) {
+				t.Errorf("Expected %+v, got %+v", input, decoded)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTagWithOptions(t *testing.T) {
+	type tagWithOptions struct {
+		Name *string `php:"name,omitnilptr"`
+	}
+
+	input := "bob"
+	data, err := phpserialize.Marshal(tagWithOptions{&input}, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	var result tagWithOptions
+	err = phpserialize.Unmarshal(data, &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Name == nil || *result.Name != input {
+		t.Errorf("Expected %v, got %v", input, result.Name)
+	}
+}
+
+func TestFieldNamerClosuresAreNotMixedUp(t *testing.T) {
+	prefixNamer := func(prefix string) phpserialize.FieldNamer {
+		return func(fieldName string) string {
+			return prefix + fieldName
+		}
+	}
+
+	for _, prefix := range []string{"a_", "b_"} {
+		options := phpserialize.DefaultMarshalOptions()
+		options.FieldNamer = prefixNamer(prefix)
+
+		result, err := phpserialize.Marshal(Struct2{1}, options)
+		expectErrorToNotHaveOccurred(t, err)
+
+		expected := `O:7:"Struct2":1:{s:5:"` + prefix + `Qux";d:1;}`
+		if string(result) != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, result)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package phpserialize
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Number is a PHP integer or float that keeps the text it was serialized with,
+// in the same way as json.Number. This means that large integers and exact
+// decimal amounts are not rounded before they are needed.
+//
+// A Number is encoded as a PHP integer if it is an integer that fits into an
+// int64, otherwise it is encoded as a float with the same text.
+type Number string
+
+var (
+	numberType   = reflect.TypeOf(Number(""))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// String returns the text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns the number as an int64. It fails if the number is a float or
+// does not fit.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Float64 returns the number as a float64, which may be rounded.
+func (n Number) Float64() (float64, error) {
+	f, err := strconv.ParseFloat(phpFloatLiteral(string(n)), 64)
+	if err != nil && !isRangeError(err) {
+		return 0, err
+	}
+
+	return f, nil
+}
+
+// BigInt returns the number as a *big.Int. It fails if the number is a float.
+func (n Number) BigInt() (*big.Int, error) {
+	i, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil, fmt.Errorf("can not convert %q to *big.Int", string(n))
+	}
+
+	return i, nil
+}
+
+// BigFloat returns the number as a *big.Float. The precision is chosen so that
+// all of the digits in the number are kept, with a minimum of 64 bits. It
+// fails for NAN.
+func (n Number) BigFloat() (*big.Float, error) {
+	f, _, err := big.ParseFloat(phpFloatLiteral(string(n)), 10, bigFloatPrec(string(n)), big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("can not convert %q to *big.Float", string(n))
+	}
+
+	return f, nil
+}
+
+// BigRat returns the number as an exact *big.Rat. It fails for INF and NAN.
+func (n Number) BigRat() (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return nil, fmt.Errorf("can not convert %q to *big.Rat", string(n))
+	}
+
+	return r, nil
+}
+
+// isInt returns true if the number is an integer literal, such as "-123".
+func (n Number) isInt() bool {
+	s := strings.TrimPrefix(string(n), "-")
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValid returns true if the number can be written to or read from serialized
+// PHP. This is an optional "-", digits with an optional fraction and exponent,
+// or exactly INF, -INF or NAN.
+func (n Number) isValid() bool {
+	switch s := string(n); s {
+	case "INF", "-INF", "NAN":
+		return true
+
+	case "":
+		return false
+
+	default:
+		number, _ := phpNumericPrefix(s)
+
+		return s[0] != '+' && number == s
+	}
+}
+
+// value converts the number into the int64 or float64 that it would otherwise
+// have been decoded as. Integers that do not fit into an int64 become a float,
+// as they would in PHP.
+func (n Number) value() interface{} {
+	if n.isInt() {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+	}
+
+	f, _ := n.Float64()
+
+	return f
+}
+
+// MarshalNumber returns the bytes that represent a PHP integer or float with
+// the text of n. An empty Number is encoded as 0, and an error is returned if n
+// is not a number that PHP can read.
+func MarshalNumber(n Number) ([]byte, error) {
+	if n == "" {
+		return MarshalInt(0), nil
+	}
+
+	if n.isInt() {
+		if _, err := n.Int64(); err == nil {
+			return []byte("i:" + string(n) + ";"), nil
+		}
+	}
+
+	if !n.isValid() {
+		return nil, fmt.Errorf("can not encode invalid Number: %q", string(n))
+	}
+
+	return []byte("d:" + string(n) + ";"), nil
+}
+
+// MarshalBigInt returns the bytes that represent a PHP integer. Integers that
+// do not fit into an int64 are encoded as a float with all of their digits,
+// which PHP will round when it is decoded.
+func MarshalBigInt(i *big.Int) []byte {
+	if i.IsInt64() {
+		return MarshalInt(i.Int64())
+	}
+
+	return []byte("d:" + i.String() + ";")
+}
+
+// MarshalBigFloat returns the bytes that represent a PHP float with all of the
+// digits of f.
+func MarshalBigFloat(f *big.Float) []byte {
+	if f.IsInf() {
+		if f.Sign() < 0 {
+			return []byte("d:-INF;")
+		}
+
+		return []byte("d:INF;")
+	}
+
+	return []byte("d:" + f.Text('f', -1) + ";")
+}
+
+// MarshalBigRat returns the bytes that represent a PHP integer if r is an
+// integer, otherwise a PHP float that is as close as possible to r.
+func MarshalBigRat(r *big.Rat) []byte {
+	if r.IsInt() {
+		return MarshalBigInt(r.Num())
+	}
+
+	f, _ := r.Float64()
+
+	return MarshalFloat(f, 64)
+}
+
+// consumeNumber reads an integer or float without converting it.
+func consumeNumber(data []byte, offset int) (Number, int, error) {
+	if !checkType(data, 'i', offset) && !checkType(data, 'd', offset) {
+		return "", -1, fmt.Errorf("not a number")
+	}
+
+	s, newOffset := consumeStringUntilByte(data, ';', offset+2)
+	if newOffset < 0 {
+		return "", -1, fmt.Errorf("not a number")
+	}
+
+	n := Number(s)
+	if data[offset] == 'i' && !n.isInt() {
+		return "", -1, fmt.Errorf("not an integer: %s", s)
+	}
+
+	if !n.isValid() {
+		return "", -1, fmt.Errorf("not a number: %s", s)
+	}
+
+	// The +1 is to skip over the final ';'
+	return n, newOffset + 1, nil
+}
+
+// numberText returns the text of a decoded number, which will only be a Number
+// if the decoder kept the original text. In PHP mode numeric strings are also
+// accepted.
+func numberText(value interface{}, mode CoercionMode) (string, bool) {
+	switch v := value.(type) {
+	case Number:
+		return string(v), true
+
+	case int64:
+		return strconv.FormatInt(v, 10), true
+
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return string(floatNumber(v)), true
+		}
+
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+
+	case string:
+		if mode == CoercePHP {
+			number, _ := phpNumericPrefix(v)
+			if number != "" && number == strings.TrimSpace(v) {
+				return strings.TrimPrefix(number, "+"), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// setNumber decodes a value into a Number.
+func setNumber(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	s, ok := numberText(value, options.Coercion)
+	if !ok {
+		return newUnmarshalTypeError(value, v.Type())
+	}
+
+	v.SetString(s)
+
+	return nil
+}
+
+// setBigNumber decodes a value into a big.Int, big.Float or big.Rat. Like other
+// integers, a float can only be decoded into a big.Int in PHP mode.
+func setBigNumber(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	t := v.Type()
+
+	s, ok := numberText(value, options.Coercion)
+	if !ok {
+		return newUnmarshalTypeError(value, t)
+	}
+
+	n := Number(s)
+
+	var result interface{}
+	var err error
+
+	switch t {
+	case bigIntType:
+		if !n.isInt() {
+			if options.Coercion != CoercePHP {
+				return newUnmarshalTypeError(value, t)
+			}
+
+			var f *big.Float
+			if f, err = n.BigFloat(); err == nil {
+				if f.IsInf() {
+					return newUnmarshalTypeError(value, t)
+				}
+
+				result, _ = f.Int(nil)
+			}
+		} else {
+			result, err = n.BigInt()
+		}
+
+	case bigFloatType:
+		result, err = n.BigFloat()
+
+	case bigRatType:
+		result, err = n.BigRat()
+	}
+
+	if err != nil {
+		return newUnmarshalTypeError(value, t)
+	}
+
+	v.Set(reflect.ValueOf(result).Elem())
+
+	return nil
+}
+
+// bigFloatPrec returns a precision that can hold all of the digits in s.
+func bigFloatPrec(s string) uint {
+	prec := uint(len(s)) * 4
+	if prec < 64 {
+		prec = 64
+	}
+
+	return prec
+}
+
+// phpFloatLiteral converts the INF and NAN that PHP uses to a form that
+// strconv understands.
+func phpFloatLiteral(s string) string {
+	switch s {
+	case "INF":
+		return "+Inf"
+	case "-INF":
+		return "-Inf"
+	case "NAN":
+		return "NaN"
+	}
+
+	return s
+}
+
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// numberTypes caches the result of hasNumberType.
+var numberTypes sync.Map
+
+// hasNumberType returns true if a value of type t can contain a Number, big.Int,
+// big.Float or big.Rat. The decoder only keeps the text of numbers when it is
+// needed, since it is slower.
+func hasNumberType(t reflect.Type) bool {
+	if has, ok := numberTypes.Load(t); ok {
+		return has.(bool)
+	}
+
+	has := findNumberType(t, map[reflect.Type]bool{})
+	numberTypes.Store(t, has)
+
+	return has
+}
+
+func findNumberType(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	switch t {
+	case numberType, bigIntType, bigFloatType, bigRatType:
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findNumberType(t.Elem(), visited)
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if findNumberType(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,236 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type account struct {
+	ID      int                 `php:"id"`
+	Balance phpserialize.Number `php:"balance"`
+	Big     *big.Int            `php:"big"`
+	Rate    *big.Float          `php:"rate"`
+	Share   *big.Rat            `php:"share"`
+	Extra   any                 `php:"extra"`
+}
+
+func TestNumber(t *testing.T) {
+	n := phpserialize.Number("12345678901234567890")
+
+	if _, err := n.Int64(); err == nil {
+		t.Error("Expected Int64 to fail")
+	}
+
+	i, err := n.BigInt()
+	expectErrorToNotHaveOccurred(t, err)
+
+	if i.String() != "12345678901234567890" {
+		t.Errorf("Expected 12345678901234567890, got %s", i)
+	}
+
+	f, err := phpserialize.Number("0.1").BigFloat()
+	expectErrorToNotHaveOccurred(t, err)
+
+	if f.Text('f', -1) != "0.1" {
+		t.Errorf("Expected 0.1, got %s", f.Text('f', -1))
+	}
+
+	r, err := phpserialize.Number("0.1").BigRat()
+	expectErrorToNotHaveOccurred(t, err)
+
+	if r.String() != "1/10" {
+		t.Errorf("Expected 1/10, got %s", r)
+	}
+
+	f64, err := phpserialize.Number("INF").Float64()
+	expectErrorToNotHaveOccurred(t, err)
+
+	if f64 <= 0 || f64*2 != f64 {
+		t.Errorf("Expected INF, got %v", f64)
+	}
+}
+
+func TestMarshalNumber(t *testing.T) {
+	tests := map[string]struct {
+		input  interface{}
+		output string
+	}{
+		"Number int":        {phpserialize.Number("-42"), "i:-42;"},
+		"Number float":      {phpserialize.Number("19.990"), "d:19.990;"},
+		"Number large":      {phpserialize.Number("12345678901234567890"), "d:12345678901234567890;"},
+		"Number empty":      {phpserialize.Number(""), "i:0;"},
+		"big.Int":           {big.NewInt(7), "i:7;"},
+		"big.Int large":     {new(big.Int).Lsh(big.NewInt(1), 70), "d:1180591620717411303424;"},
+		"big.Float":         {big.NewFloat(1.5), "d:1.5;"},
+		"big.Float INF":     {new(big.Float).SetInf(true), "d:-INF;"},
+		"big.Rat int":       {big.NewRat(10, 2), "i:5;"},
+		"big.Rat":           {big.NewRat(1, 4), "d:0.25;"},
+		"nil big.Int":       {(*big.Int)(nil), "N;"},
+		"big.Int not a ptr": {*big.NewInt(3), "i:3;"},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := phpserialize.Marshal(test.input, nil)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.output {
+				t.Errorf("Expected '%s', got '%s'", test.output, result)
+			}
+		})
+	}
+
+	// Only numbers that PHP can read are encoded, even if Go can parse them.
+	for _, n := range []string{"abc", "infinity", "Inf", "+Inf", "NaN", "0x1p3", "1_0", "+1", "1e", " 1"} {
+		_, err := phpserialize.Marshal(phpserialize.Number(n), nil)
+		expectErrorToEqual(t, err, fmt.Errorf("can not encode invalid Number: %q", n))
+	}
+
+	for _, n := range []string{"-1.5E-7", "5.", ".5", "INF", "-INF", "NAN"} {
+		result, err := phpserialize.MarshalNumber(phpserialize.Number(n))
+		expectErrorToNotHaveOccurred(t, err)
+
+		if expected := "d:" + n + ";"; string(result) != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, result)
+		}
+	}
+}
+
+func TestUnmarshalNumberFields(t *testing.T) {
+	input := `O:7:"account":6:{s:2:"id";i:3;s:7:"balance";d:19.990;` +
+		`s:3:"big";i:123456789012345678901234567890;` +
+		`s:4:"rate";d:0.10000000000000000555;s:5:"share";d:0.1;` +
+		`s:5:"extra";a:1:{i:0;i:5;}}`
+
+	var result account
+	err := phpserialize.Unmarshal([]byte(input), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.ID != 3 {
+		t.Errorf("Expected id to be 3, got %d", result.ID)
+	}
+
+	if result.Balance != "19.990" {
+		t.Errorf("Expected balance to be 19.990, got %s", result.Balance)
+	}
+
+	if result.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("Expected big to be 123456789012345678901234567890, got %s", result.Big)
+	}
+
+	if result.Rate.Text('f', -1) != "0.10000000000000000555" {
+		t.Errorf("Expected rate to be 0.10000000000000000555, got %s", result.Rate.Text('f', -1))
+	}
+
+	if result.Share.String() != "1/10" {
+		t.Errorf("Expected share to be 1/10, got %s", result.Share)
+	}
+
+	// Numbers are only kept as a Number when they are asked for.
+	if !reflect.DeepEqual(result.Extra, []interface{}{int64(5)}) {
+		t.Errorf("Expected extra to be [5], got %#v", result.Extra)
+	}
+
+	encoded, err := phpserialize.Marshal(result, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	// The big.Int is too large for a PHP integer, so it becomes a float.
+	expected := strings.Replace(input, "i:1234", "d:1234", 1)
+	if string(encoded) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, encoded)
+	}
+}
+
+func TestUnmarshalNumberErrors(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedError error
+	}{
+		"float into big.Int": {
+			`O:7:"account":1:{s:3:"big";d:1.5;}`,
+			errors.New("can not unmarshal PHP float into struct field Big of type big.Int"),
+		},
+		"string into Number": {
+			`O:7:"account":1:{s:7:"balance";s:1:"1";}`,
+			errors.New("can not unmarshal PHP string into struct field Balance of type phpserialize.Number"),
+		},
+		"infinity into Number": {
+			`O:7:"account":1:{s:7:"balance";d:infinity;}`,
+			errors.New("not a number: infinity"),
+		},
+		"hex float into Number": {
+			`O:7:"account":1:{s:7:"balance";d:0x1p3;}`,
+			errors.New("not a number: 0x1p3"),
+		},
+		"underscore into Number": {
+			`O:7:"account":1:{s:7:"balance";d:1_0;}`,
+			errors.New("not a number: 1_0"),
+		},
+		"Go infinity into Number": {
+			`O:7:"account":1:{s:7:"balance";d:+Inf;}`,
+			errors.New("not a number: +Inf"),
+		},
+		"large int into int": {
+			`O:7:"account":1:{s:2:"id";i:123456789012345678901234567890;}`,
+			errors.New("can not unmarshal PHP float into struct field ID of type int"),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result account
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+			expectErrorToEqual(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestUnmarshalNumberPHPMode(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.Coercion = phpserialize.CoercePHP
+
+	var result account
+	err := phpserialize.UnmarshalWithOptions([]byte(
+		`O:7:"account":2:{s:3:"big";d:1.0E+25;s:7:"balance";s:5:" 1.50";}`), &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Big.String() != "10000000000000000000000000" {
+		t.Errorf("Expected big to be 10000000000000000000000000, got %s", result.Big)
+	}
+
+	if result.Balance != "1.50" {
+		t.Errorf("Expected balance to be 1.50, got %s", result.Balance)
+	}
+}
+
+func TestUnmarshalUseNumber(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.UseNumber = true
+
+	var result *orderedmap.OrderedMap[any, any]
+	err := phpserialize.UnmarshalWithOptions([]byte(`a:2:{i:0;d:0.30;s:1:"a";i:99999999999999999999;}`), &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if v, _ := result.Get(int64(0)); v != phpserialize.Number("0.30") {
+		t.Errorf("Expected 0.30, got %#v", v)
+	}
+
+	if v, _ := result.Get("a"); v != phpserialize.Number("99999999999999999999") {
+		t.Errorf("Expected 99999999999999999999, got %#v", v)
+	}
+
+	var top big.Int
+	err = phpserialize.Unmarshal([]byte(`i:99999999999999999999;`), &top)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if top.String() != "99999999999999999999" {
+		t.Errorf("Expected 99999999999999999999, got %s", &top)
+	}
+}
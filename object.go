@@ -0,0 +1,234 @@
+package phpserialize
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// Object is a PHP object that has kept its class name. Objects are only decoded
+// as an *Object when the DecodeObjects option is enabled. They can also be
+// encoded with Marshal to produce an object of any class.
+type Object struct {
+	ClassName  string
+	Properties *orderedmap.OrderedMap[any, any]
+}
+
+// NewObject creates an Object with no properties.
+func NewObject(className string) *Object {
+	return &Object{
+		ClassName:  className,
+		Properties: orderedmap.NewOrderedMap[any, any](),
+	}
+}
+
+// MarshalObject returns the bytes that represent a PHP object with the class
+// name and properties of o. The OnlyStdClass option is respected.
+func MarshalObject(o *Object, options *MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = DefaultMarshalOptions()
+	}
+
+	return appendObject(nil, o, options)
+}
+
+func appendObject(dst []byte, o *Object, options *MarshalOptions) ([]byte, error) {
+	className := o.ClassName
+	if options.OnlyStdClass {
+		className = "stdClass"
+	}
+
+	if o.Properties == nil {
+		return append(appendObjectHeader(dst, className, 0), '}'), nil
+	}
+
+	// The properties are checked rather than o, which may be a copy.
+	options, err := options.startEncoding(o.Properties)
+	if err != nil {
+		return nil, err
+	}
+	defer delete(options.visiting, o.Properties)
+
+	dst = appendObjectHeader(dst, className, o.Properties.Len())
+	for key, value := range o.Properties.AllFromFront() {
+		dst, err = appendMarshal(dst, key, options)
+		if err != nil {
+			return nil, err
+		}
+
+		dst, err = appendMarshal(dst, value, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(dst, '}'), nil
+}
+
+// classRegistry maps PHP class names to the reflect.Type they are decoded into.
+var classRegistry sync.Map
+
+// RegisterClass records the Go type that objects of a PHP class are decoded
+// into when the target is an interface, such as a struct field of type Shape.
+// The value is only used for its type, which can be a struct or a pointer to a
+// struct:
+//
+//	phpserialize.RegisterClass("Circle", &Circle{})
+//
+// The type (or a pointer to it) must implement the interface it is decoded
+// into. Registering the same class name again replaces the previous type.
+func RegisterClass(className string, value interface{}) {
+	classRegistry.Store(className, reflect.TypeOf(value))
+}
+
+// ClassResolver can be implemented by a struct to choose the Go type of PHP
+// objects that are decoded into its interface fields (including the elements of
+// slices and maps in those fields). This is useful when the same class name
+// needs to become different Go types, depending on where it is found.
+//
+// ResolvePHPClass receives the name of the Go field and the PHP class name, and
+// returns a value of the type to use in the same way as RegisterClass. If it
+// returns nil the registered classes are used instead.
+type ClassResolver interface {
+	ResolvePHPClass(fieldName, className string) interface{}
+}
+
+var classResolverType = reflect.TypeOf((*ClassResolver)(nil)).Elem()
+
+// classResolverFor returns the resolver for a struct, or nil if it does not
+// implement ClassResolver.
+func classResolverFor(obj reflect.Value) ClassResolver {
+	if obj.CanAddr() && obj.Addr().Type().Implements(classResolverType) {
+		return obj.Addr().Interface().(ClassResolver)
+	}
+
+	if obj.Type().Implements(classResolverType) {
+		return obj.Interface().(ClassResolver)
+	}
+
+	return nil
+}
+
+// resolveClass returns the Go type for a PHP class name, or nil if it is not
+// known.
+func resolveClass(className string, options *UnmarshalOptions) reflect.Type {
+	if options.resolveClass != nil {
+		if v := options.resolveClass(className); v != nil {
+			return reflect.TypeOf(v)
+		}
+	}
+
+	if t, ok := classRegistry.Load(className); ok {
+		return t.(reflect.Type)
+	}
+
+	return nil
+}
+
+// setInterface decodes a value into an interface. An empty interface receives
+// the value as is. Any other interface needs a PHP object with a class that can
+// be resolved to a Go type that implements the interface.
+func setInterface(v reflect.Value, value interface{}, options *UnmarshalOptions) error {
+	t := v.Type()
+
+	if t.NumMethod() == 0 {
+		v.Set(reflect.ValueOf(plainValue(value, options)))
+		return nil
+	}
+
+	o, ok := value.(*Object)
+	if !ok {
+		return newUnmarshalTypeError(value, t)
+	}
+
+	concrete := resolveClass(o.ClassName, options)
+	if concrete == nil {
+		return newUnmarshalTypeError(value, t)
+	}
+
+	var target, result reflect.Value
+	switch {
+	case concrete.Implements(t):
+		target = reflect.New(concrete).Elem()
+		result = target
+
+	case reflect.PointerTo(concrete).Implements(t):
+		result = reflect.New(concrete)
+		target = result.Elem()
+
+	default:
+		return newUnmarshalTypeError(value, t)
+	}
+
+	if err := setField(target, o, options); err != nil {
+		return err
+	}
+
+	v.Set(result)
+
+	return nil
+}
+
+// plainValue converts any *Object in a decoded value back into an OrderedMap of
+// its properties, unless DecodeObjects is enabled. Likewise, a Number is
+// converted back into an int64 or float64 unless UseNumber is enabled. Arrays
+// are updated in place.
+func plainValue(value interface{}, options *UnmarshalOptions) interface{} {
+	if options.DecodeObjects && options.UseNumber {
+		return value
+	}
+
+	return plainValueOnce(value, options, nil)
+}
+
+// plainValueOnce is plainValue for a value that may contain itself. Each
+// object, map and reference is only converted the first time that it is
+// seen.
+func plainValueOnce(value interface{}, options *UnmarshalOptions, seen map[interface{}]bool) interface{} {
+	switch v := value.(type) {
+	case *Object, *Reference, *orderedmap.OrderedMap[any, any]:
+		if seen[v] {
+			if o, ok := v.(*Object); ok && !options.DecodeObjects {
+				return o.Properties
+			}
+
+			return value
+		}
+
+		if seen == nil {
+			seen = map[interface{}]bool{}
+		}
+
+		seen[v] = true
+	}
+
+	switch v := value.(type) {
+	case *Object:
+		if !options.DecodeObjects {
+			return plainValueOnce(v.Properties, options, seen)
+		}
+
+		plainValueOnce(v.Properties, options, seen)
+
+	case Number:
+		if !options.UseNumber {
+			return v.value()
+		}
+
+	case *Reference:
+		v.Value = plainValueOnce(v.Value, options, seen)
+
+	case []interface{}:
+		for i, item := range v {
+			v[i] = plainValueOnce(item, options, seen)
+		}
+
+	case *orderedmap.OrderedMap[any, any]:
+		for el := v.Front(); el != nil; el = el.Next() {
+			el.Value = plainValueOnce(el.Value, options, seen)
+		}
+	}
+
+	return value
+}
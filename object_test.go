@@ -0,0 +1,199 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type Shape interface {
+	Area() float64
+}
+
+type Circle struct {
+	Radius float64 `php:"radius"`
+}
+
+func (c Circle) Area() float64 {
+	return 3 * c.Radius * c.Radius
+}
+
+type Square struct {
+	Side float64 `php:"side"`
+}
+
+func (s *Square) Area() float64 {
+	return s.Side * s.Side
+}
+
+type Rectangle struct {
+	Width  float64 `php:"width"`
+	Height float64 `php:"height"`
+}
+
+func (r Rectangle) Area() float64 {
+	return r.Width * r.Height
+}
+
+type drawing struct {
+	Main   Shape   `php:"main"`
+	Shapes []Shape `php:"shapes"`
+	Extra  any     `php:"extra"`
+}
+
+// resolvedDrawing decodes "Square" as a Rectangle in its Main field only.
+type resolvedDrawing struct {
+	Main  Shape `php:"main"`
+	Other Shape `php:"other"`
+}
+
+func (d *resolvedDrawing) ResolvePHPClass(fieldName, className string) interface{} {
+	if fieldName == "Main" && className == "Square" {
+		return Rectangle{}
+	}
+
+	return nil
+}
+
+func init() {
+	phpserialize.RegisterClass("Circle", Circle{})
+	phpserialize.RegisterClass("Square", &Square{})
+}
+
+func TestUnmarshalInterfaceField(t *testing.T) {
+	var result drawing
+	err := phpserialize.Unmarshal([]byte(`O:7:"drawing":3:{`+
+		`s:4:"main";O:6:"Circle":1:{s:6:"radius";d:2;}`+
+		`s:6:"shapes";a:2:{i:0;O:6:"Square":1:{s:4:"side";d:3;}i:1;O:6:"Circle":1:{s:6:"radius";d:1;}}`+
+		`s:5:"extra";O:8:"stdClass":1:{s:1:"a";i:1;}}`), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Main != (Circle{Radius: 2}) {
+		t.Errorf("Expected main to be a Circle, got %#v", result.Main)
+	}
+
+	expected := []Shape{&Square{Side: 3}, Circle{Radius: 1}}
+	if !reflect.DeepEqual(result.Shapes, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, result.Shapes)
+	}
+
+	// Without DecodeObjects an empty interface still receives an OrderedMap.
+	extra, ok := result.Extra.(*orderedmap.OrderedMap[any, any])
+	if !ok {
+		t.Fatalf("Expected extra to be an OrderedMap, got %T", result.Extra)
+	}
+
+	if v, _ := extra.Get("a"); v != int64(1) {
+		t.Errorf("Expected a to be 1, got %v", v)
+	}
+}
+
+func TestUnmarshalInterfaceFieldErrors(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedError error
+	}{
+		"unknown class": {
+			`O:7:"drawing":1:{s:4:"main";O:8:"Triangle":0:{}}`,
+			errors.New("can not unmarshal PHP object of class Triangle into struct field Main of type phpserialize_test.Shape"),
+		},
+		"not an object": {
+			`O:7:"drawing":1:{s:4:"main";a:0:{}}`,
+			errors.New("can not unmarshal PHP array into struct field Main of type phpserialize_test.Shape"),
+		},
+		"bad property": {
+			`O:7:"drawing":1:{s:4:"main";O:6:"Circle":1:{s:6:"radius";s:1:"x";}}`,
+			errors.New("can not unmarshal PHP string into struct field Main.Radius of type float64"),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result drawing
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+			expectErrorToEqual(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestUnmarshalClassResolver(t *testing.T) {
+	var result resolvedDrawing
+	err := phpserialize.Unmarshal([]byte(`O:15:"resolvedDrawing":2:{`+
+		`s:4:"main";O:6:"Square":2:{s:5:"width";d:2;s:6:"height";d:3;}`+
+		`s:5:"other";O:6:"Square":1:{s:4:"side";d:4;}}`), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Main != (Rectangle{Width: 2, Height: 3}) {
+		t.Errorf("Expected main to be a Rectangle, got %#v", result.Main)
+	}
+
+	if !reflect.DeepEqual(result.Other, &Square{Side: 4}) {
+		t.Errorf("Expected other to be a *Square, got %#v", result.Other)
+	}
+}
+
+func TestUnmarshalDecodeObjects(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	var result *orderedmap.OrderedMap[any, any]
+	err := phpserialize.UnmarshalWithOptions([]byte(
+		`a:1:{s:1:"c";O:6:"Circle":1:{s:6:"radius";d:2;}}`), &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	v, _ := result.Get("c")
+	o, ok := v.(*phpserialize.Object)
+	if !ok {
+		t.Fatalf("Expected an *Object, got %T", v)
+	}
+
+	if o.ClassName != "Circle" {
+		t.Errorf("Expected class name Circle, got %s", o.ClassName)
+	}
+
+	if radius, _ := o.Properties.Get("radius"); radius != 2.0 {
+		t.Errorf("Expected radius to be 2, got %v", radius)
+	}
+}
+
+func TestMarshalObject(t *testing.T) {
+	o := phpserialize.NewObject("Circle")
+	o.Properties.Set("radius", 2.5)
+
+	result, err := phpserialize.Marshal(o, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `O:6:"Circle":1:{s:6:"radius";d:2.5;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var shape drawing
+	err = phpserialize.Unmarshal([]byte(`O:7:"drawing":1:{s:4:"main";`+expected+`}`), &shape)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if shape.Main != (Circle{Radius: 2.5}) {
+		t.Errorf("Expected main to be a Circle, got %#v", shape.Main)
+	}
+
+	options := phpserialize.DefaultMarshalOptions()
+	options.OnlyStdClass = true
+
+	result, err = phpserialize.Marshal(o, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected = `O:8:"stdClass":1:{s:6:"radius";d:2.5;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	result, err = phpserialize.Marshal((*phpserialize.Object)(nil), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != "N;" {
+		t.Errorf("Expected 'N;', got '%s'", result)
+	}
+}
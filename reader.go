@@ -0,0 +1,336 @@
+package phpserialize
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is implemented by types that can encode themselves, such as the
+// types generated by cmd/phpserialize-gen. MarshalPHP appends the encoded value
+// to dst and returns the extended slice. Marshal uses it instead of reflection.
+type Marshaler interface {
+	MarshalPHP(dst []byte, options *MarshalOptions) ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that can decode themselves, such as the
+// types generated by cmd/phpserialize-gen. UnmarshalPHP reads exactly one
+// value from r. Unmarshal uses it instead of reflection when it is given a
+// pointer to the type.
+type Unmarshaler interface {
+	UnmarshalPHP(r *Reader) error
+}
+
+// Reader reads serialized PHP values one piece at a time. It is used by types
+// that implement Unmarshaler to parse the data without reflection.
+//
+// r: and R: refer to values by the order that PHP wrote them in, which does
+// not depend on the order that they are read. Each one is decoded as the value
+// that it refers to, as it would be by reflection.
+type Reader struct {
+	data    []byte
+	offset  int
+	options *UnmarshalOptions
+
+	// d holds the references for every value that is read. They are found
+	// again for each value that starts after the end of the last one.
+	d        *decoder
+	refsFrom int
+	refsTo   int
+}
+
+// NewReader creates a Reader that starts at the beginning of data. A nil
+// options is the same as DefaultUnmarshalOptions().
+func NewReader(data []byte, options *UnmarshalOptions) *Reader {
+	if options == nil {
+		options = DefaultUnmarshalOptions()
+	}
+
+	return &Reader{
+		data:    data,
+		options: options,
+		d:       newDecoder(options, false),
+	}
+}
+
+// Options returns the options that the data is being decoded with.
+func (r *Reader) Options() *UnmarshalOptions {
+	return r.options
+}
+
+// Offset returns the position of the next value in the data.
+func (r *Reader) Offset() int {
+	return r.offset
+}
+
+// Seek moves to offset, which should be a position returned by Offset. It is
+// used to go back to a value that was skipped over.
+func (r *Reader) Seek(offset int) {
+	r.offset = offset
+}
+
+// Peek returns the character that starts the next value without reading it,
+// such as 'i' for an integer or 'O' for an object. It is 0 if there is no more
+// data.
+func (r *Reader) Peek() byte {
+	if r.offset >= len(r.data) {
+		return 0
+	}
+
+	return r.data[r.offset]
+}
+
+// prepare finds the values that r: and R: can refer to in the value at the
+// current offset, unless it is part of the value that they were found in.
+func (r *Reader) prepare() {
+	if r.d.refsChecked && r.offset >= r.refsFrom && r.offset < r.refsTo {
+		return
+	}
+
+	r.d.initReferences(r.data, r.offset, true)
+	r.refsFrom, r.refsTo = r.offset, len(r.data)
+	if r.d.refs != nil {
+		r.refsTo = r.d.refs.end
+	}
+}
+
+// decoder returns a decoder for a value of type t that shares the references
+// of the Reader.
+func (r *Reader) decoder(t reflect.Type) *decoder {
+	r.prepare()
+
+	d := newTypedDecoder(r.options, t)
+	d.refs, d.refsChecked = r.d.refs, true
+
+	return d
+}
+
+// readReference reads r: or R: as the value that it refers to. It returns
+// false if the next value is not a reference.
+func (r *Reader) readReference() (interface{}, bool, error) {
+	r.prepare()
+	if c := r.Peek(); c != 'r' && c != 'R' {
+		return nil, false, nil
+	}
+
+	var v interface{}
+	value, offset, err := r.decoder(reflect.TypeOf(&v).Elem()).consumeNext(r.data, r.offset)
+	if ref, ok := value.(*Reference); ok {
+		value = ref.Value
+	}
+
+	return value, true, r.advance(offset, err)
+}
+
+// advance moves to offset if err is nil.
+func (r *Reader) advance(offset int, err error) error {
+	if err != nil {
+		return err
+	}
+
+	r.offset = offset
+
+	return nil
+}
+
+// ReadNull reads a null.
+func (r *Reader) ReadNull() error {
+	if v, ok, err := r.readReference(); ok {
+		if err == nil && v != nil {
+			err = errors.New("not null")
+		}
+
+		return err
+	}
+
+	_, offset, err := consumeNil(r.data, r.offset)
+
+	return r.advance(offset, err)
+}
+
+// ReadBool reads a bool.
+func (r *Reader) ReadBool() (bool, error) {
+	if v, ok, err := r.readReference(); ok {
+		b, isBool := v.(bool)
+		if err == nil && !isBool {
+			err = errors.New("not a boolean")
+		}
+
+		return b, err
+	}
+
+	if r.offset+4 > len(r.data) {
+		return false, errors.New("not a boolean")
+	}
+
+	v, offset, err := consumeBool(r.data, r.offset)
+
+	return v, r.advance(offset, err)
+}
+
+// ReadInt reads an integer.
+func (r *Reader) ReadInt() (int64, error) {
+	if v, ok, err := r.readReference(); ok {
+		if n, isNumber := v.(Number); isNumber {
+			v = n.value()
+		}
+
+		i, isInt := v.(int64)
+		if err == nil && !isInt {
+			err = errors.New("not an integer")
+		}
+
+		return i, err
+	}
+
+	v, offset, err := consumeInt(r.data, r.offset)
+
+	return v, r.advance(offset, err)
+}
+
+// ReadFloat reads a float.
+func (r *Reader) ReadFloat() (float64, error) {
+	if v, ok, err := r.readReference(); ok {
+		if n, isNumber := v.(Number); isNumber {
+			v = n.value()
+		}
+
+		f, isFloat := v.(float64)
+		if err == nil && !isFloat {
+			err = errors.New("not a float")
+		}
+
+		return f, err
+	}
+
+	v, offset, err := consumeFloat(r.data, r.offset)
+
+	return v, r.advance(offset, err)
+}
+
+// ReadString reads a string. The ZeroCopy option is respected.
+func (r *Reader) ReadString() (string, error) {
+	if v, ok, err := r.readReference(); ok {
+		s, isString := v.(string)
+		if err == nil && !isString {
+			err = errors.New("not a string")
+		}
+
+		return s, err
+	}
+
+	v, offset, err := r.d.consumeString(r.data, r.offset)
+
+	return v, r.advance(offset, err)
+}
+
+// ReadBytes reads a string as a []byte. The ZeroCopy option is respected, in
+// which case the result must not be changed.
+func (r *Reader) ReadBytes() ([]byte, error) {
+	s, err := r.ReadString()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.options.ZeroCopy {
+		return stringToBytes(s), nil
+	}
+
+	return []byte(s), nil
+}
+
+// ReadArrayHeader reads the start of an array, up to and including the "{",
+// and returns the number of elements. Each element is a key followed by a
+// value, and the array finishes with ReadEnd.
+func (r *Reader) ReadArrayHeader() (int, error) {
+	r.prepare()
+	if !checkType(r.data, 'a', r.offset) {
+		return 0, errors.New("not an array")
+	}
+
+	length, offset, err := consumeIntPart(r.data, r.offset+2)
+	if err != nil {
+		return 0, err
+	}
+
+	if !checkType(r.data, '{', offset) {
+		return 0, fmt.Errorf("can not read array at offset %d", r.offset)
+	}
+
+	r.offset = offset + 1
+
+	return length, nil
+}
+
+// ReadObjectHeader reads the start of an object, up to and including the "{",
+// and returns its class name and number of properties. Each property is a
+// string name followed by a value, and the object finishes with ReadEnd.
+func (r *Reader) ReadObjectHeader() (string, int, error) {
+	r.prepare()
+	if !checkType(r.data, 'O', r.offset) {
+		return "", 0, errors.New("not an object")
+	}
+
+	rawClassName, offset, err := consumeStringRealBytes(r.data, r.offset+2)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The class name is followed by a ':' rather than a ';', which is
+	// skipped over by consumeStringRealBytes.
+	length, offset, err := consumeIntPart(r.data, offset)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if !checkType(r.data, '{', offset) {
+		return "", 0, fmt.Errorf("can not read object at offset %d", r.offset)
+	}
+
+	r.offset = offset + 1
+
+	return r.d.decodeString(rawClassName), length, nil
+}
+
+// ReadEnd reads the "}" at the end of an array or object.
+func (r *Reader) ReadEnd() error {
+	if !checkType(r.data, '}', r.offset) {
+		return fmt.Errorf("can not find the end of an array or object at offset %d", r.offset)
+	}
+
+	r.offset++
+
+	return nil
+}
+
+// Skip reads the next value, including everything that it contains, and
+// discards it. Like an unknown property decoded by reflection, it can not
+// refer to a value that contains it.
+func (r *Reader) Skip() error {
+	_, offset, err := r.decoder(reflect.TypeOf(struct{}{})).consumeNext(r.data, r.offset)
+
+	return r.advance(offset, err)
+}
+
+// Decode reads the next value into v, which must be a pointer, in the same way
+// as a struct field is decoded by reflection. Types that implement Unmarshaler
+// use it for anything they do not handle themselves.
+func (r *Reader) Decode(v interface{}) error {
+	value := reflect.ValueOf(v).Elem()
+
+	decoded, offset, err := r.decoder(value.Type()).consumeNext(r.data, r.offset)
+	if err != nil {
+		return err
+	}
+
+	r.offset = offset
+
+	return setField(value, decoded, r.options)
+}
+
+// FieldError adds the name of a struct field to the path of an
+// *UnmarshalTypeError in the same way as decoding a struct with reflection.
+// Any other error is returned unchanged.
+func FieldError(err error, fieldName string) error {
+	return withFieldName(err, fieldName)
+}
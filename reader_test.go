@@ -0,0 +1,192 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+func TestReader(t *testing.T) {
+	r := phpserialize.NewReader([]byte(`a:6:{i:0;b:1;i:1;i:-5;i:2;d:1.5;i:3;s:5:"it\'s";i:4;N;i:5;O:3:"Foo":1:{s:1:"a";a:1:{i:0;i:1;}}}`), nil)
+
+	n, err := r.ReadArrayHeader()
+	expectErrorToNotHaveOccurred(t, err)
+	if n != 6 {
+		t.Errorf("Expected 6 elements, got %d", n)
+	}
+
+	if k, err := r.ReadInt(); err != nil || k != 0 {
+		t.Errorf("Expected key 0, got %v %v", k, err)
+	}
+	if b, err := r.ReadBool(); err != nil || !b {
+		t.Errorf("Expected true, got %v %v", b, err)
+	}
+
+	_, _ = r.ReadInt()
+	if i, err := r.ReadInt(); err != nil || i != -5 {
+		t.Errorf("Expected -5, got %v %v", i, err)
+	}
+
+	_, _ = r.ReadInt()
+	if f, err := r.ReadFloat(); err != nil || f != 1.5 {
+		t.Errorf("Expected 1.5, got %v %v", f, err)
+	}
+
+	_, _ = r.ReadInt()
+	if s, err := r.ReadString(); err != nil || s != "it's" {
+		t.Errorf("Expected it's, got %v %v", s, err)
+	}
+
+	_, _ = r.ReadInt()
+	if r.Peek() != 'N' {
+		t.Errorf("Expected N, got %c", r.Peek())
+	}
+	expectErrorToNotHaveOccurred(t, r.ReadNull())
+
+	_, _ = r.ReadInt()
+	offset := r.Offset()
+	className, n, err := r.ReadObjectHeader()
+	expectErrorToNotHaveOccurred(t, err)
+	if className != "Foo" || n != 1 {
+		t.Errorf("Expected Foo with 1 property, got %s with %d", className, n)
+	}
+
+	// Go back and skip the whole object instead.
+	r.Seek(offset)
+	expectErrorToNotHaveOccurred(t, r.Skip())
+	expectErrorToNotHaveOccurred(t, r.ReadEnd())
+
+	if r.Peek() != 0 {
+		t.Errorf("Expected the end of the data, got %c", r.Peek())
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	r := phpserialize.NewReader([]byte(`i:1;`), nil)
+
+	_, err := r.ReadBool()
+	expectErrorToEqual(t, err, errors.New("not a boolean"))
+
+	_, err = r.ReadArrayHeader()
+	expectErrorToEqual(t, err, errors.New("not an array"))
+
+	_, _, err = r.ReadObjectHeader()
+	expectErrorToEqual(t, err, errors.New("not an object"))
+
+	// A failed read does not move the reader.
+	if r.Offset() != 0 {
+		t.Errorf("Expected offset 0, got %d", r.Offset())
+	}
+}
+
+func TestReaderDecode(t *testing.T) {
+	type Inner struct {
+		Value int
+	}
+
+	r := phpserialize.NewReader([]byte(`O:5:"Inner":1:{s:5:"value";i:3;}i:7;`), nil)
+
+	var inner Inner
+	expectErrorToNotHaveOccurred(t, r.Decode(&inner))
+	if inner.Value != 3 {
+		t.Errorf("Expected 3, got %d", inner.Value)
+	}
+
+	var s string
+	err := phpserialize.FieldError(r.Decode(&s), "S")
+	expectErrorToEqual(t, err, errors.New("can not unmarshal PHP int into struct field S of type string"))
+}
+
+func TestReaderReferences(t *testing.T) {
+	// The values are numbered in the order that they were written, even
+	// when they are read in a different order or more than once.
+	r := phpserialize.NewReader([]byte(`a:4:{i:0;s:1:"x";i:1;a:1:{i:0;i:5;}i:2;R:2;i:3;R:4;}`), nil)
+
+	_, err := r.ReadArrayHeader()
+	expectErrorToNotHaveOccurred(t, err)
+
+	_, _ = r.ReadInt()
+	offset := r.Offset()
+	expectErrorToNotHaveOccurred(t, r.Skip())
+	r.Seek(offset)
+	expectErrorToNotHaveOccurred(t, r.Skip())
+
+	_, _ = r.ReadInt()
+	expectErrorToNotHaveOccurred(t, r.Skip())
+
+	_, _ = r.ReadInt()
+	if s, err := r.ReadString(); err != nil || s != "x" {
+		t.Errorf("Expected x, got %v %v", s, err)
+	}
+
+	_, _ = r.ReadInt()
+	if i, err := r.ReadInt(); err != nil || i != 5 {
+		t.Errorf("Expected 5, got %v %v", i, err)
+	}
+
+	expectErrorToNotHaveOccurred(t, r.ReadEnd())
+
+	r = phpserialize.NewReader([]byte(`a:2:{i:0;i:1;i:1;R:2;}`), nil)
+	_, _ = r.ReadArrayHeader()
+	_, _ = r.ReadInt()
+	_, _ = r.ReadInt()
+	_, _ = r.ReadInt()
+
+	_, err = r.ReadString()
+	expectErrorToEqual(t, err, errors.New("not a string"))
+}
+
+// point encodes itself as a PHP string such as "1,2".
+type point struct {
+	X, Y int64
+}
+
+func (p point) MarshalPHP(dst []byte, options *phpserialize.MarshalOptions) ([]byte, error) {
+	return phpserialize.AppendString(dst, strconv.FormatInt(p.X, 10)+","+strconv.FormatInt(p.Y, 10)), nil
+}
+
+func (p *point) UnmarshalPHP(r *phpserialize.Reader) error {
+	n, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		if err := r.Skip(); err != nil {
+			return err
+		}
+
+		v, err := r.ReadInt()
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			p.X = v
+		} else {
+			p.Y = v
+		}
+	}
+
+	return r.ReadEnd()
+}
+
+func TestMarshalerAndUnmarshaler(t *testing.T) {
+	result, err := phpserialize.Marshal([]interface{}{point{1, 2}, (*point)(nil)}, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `a:2:{i:0;s:3:"1,2";i:1;N;}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var p point
+	err = phpserialize.Unmarshal([]byte(`a:2:{i:0;i:3;i:1;i:4;}`), &p)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if p != (point{3, 4}) {
+		t.Errorf("Expected {3 4}, got %v", p)
+	}
+}
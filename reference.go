@@ -0,0 +1,242 @@
+package phpserialize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Reference is a PHP reference, which is serialized as R: followed by the
+// number of the value that it refers to. With the KeepReferences option, a
+// value that is referred to is decoded into an interface{} as a *Reference,
+// and so is every R: that refers to it, so that they are all the same
+// *Reference. Marshal encodes the value that a Reference holds.
+type Reference struct {
+	Value interface{}
+}
+
+// references holds the values that have been decoded, so that r: and R: can
+// refer back to them. PHP numbers each value from 1 in the order that it
+// starts, except for array keys, property names and R: itself.
+type references struct {
+	values []referenceTarget
+
+	// targets are the numbers of the values that R: refers to. With the
+	// KeepReferences option they are decoded as a *Reference.
+	targets map[int]*Reference
+
+	// scanning is true while the targets are being found.
+	scanning bool
+
+	// ids are the numbers of the values at each offset. They are only
+	// known when the data has been scanned for a Reader, which can read
+	// values out of order or more than once. A value that has not been
+	// decoded yet is decoded when it is referred to.
+	ids map[int]int
+
+	// last is the number of the value that was started last.
+	last int
+
+	// end is the offset after the value that was scanned.
+	end int
+}
+
+type referenceTarget struct {
+	value interface{}
+
+	// object is true if the value is a PHP object, which is the only type
+	// that r: can refer to.
+	object bool
+
+	// complete is false until the value has been decoded. A value can
+	// only refer to one that contains it if that is an object that has
+	// already been given its value by startObject, or if it is kept as a
+	// *Reference.
+	complete bool
+
+	// started is true once the value has started to be decoded.
+	started bool
+
+	// offset is where the value starts in the data.
+	offset int
+}
+
+// initReferences prepares the decoder for r: and R: in the value at offset.
+// Nothing is tracked unless the data could contain them. With outOfOrder, as
+// used by a Reader, every value is numbered by its offset before it is read.
+func (d *decoder) initReferences(data []byte, offset int, outOfOrder bool) {
+	d.refsChecked = true
+	d.refs = nil
+
+	rest := data[offset:]
+	hasValueReferences := bytes.Contains(rest, []byte("R:"))
+	if !hasValueReferences && !bytes.Contains(rest, []byte("r:")) {
+		return
+	}
+
+	d.refs = &references{}
+	keepReferences := hasValueReferences && d.options.KeepReferences
+	if !keepReferences && !outOfOrder {
+		return
+	}
+
+	// The values that R: refers to have to be known before they are
+	// decoded, so the data is read twice. Any error will be found again.
+	scanner := *d
+	scanner.refs = &references{targets: map[int]*Reference{}, scanning: true}
+	_, end, _ := scanner.consumeNext(data, offset)
+
+	if keepReferences {
+		d.refs.targets = scanner.refs.targets
+		for id := range d.refs.targets {
+			d.refs.targets[id] = &Reference{}
+		}
+	}
+
+	if !outOfOrder {
+		return
+	}
+
+	d.refs.end = end
+	if end < 0 {
+		d.refs.end = len(data)
+	}
+
+	d.refs.ids = make(map[int]int, len(scanner.refs.values))
+	d.refs.values = make([]referenceTarget, len(scanner.refs.values))
+	for i, target := range scanner.refs.values {
+		d.refs.ids[target.offset] = i + 1
+		d.refs.values[i] = referenceTarget{object: target.object, offset: target.offset}
+	}
+}
+
+// startValue numbers the value at offset, which may contain other values.
+func (d *decoder) startValue(data []byte, offset int) int {
+	if !d.refsChecked {
+		d.initReferences(data, offset, false)
+	}
+
+	if d.refs == nil {
+		return 0
+	}
+
+	target := referenceTarget{object: checkType(data, 'O', offset), started: true, offset: offset}
+
+	if d.refs.ids != nil {
+		// The value may be read again, which starts it over.
+		id := d.refs.ids[offset]
+		if id != 0 {
+			d.refs.values[id-1] = target
+		}
+
+		d.refs.last = id
+
+		return id
+	}
+
+	d.refs.values = append(d.refs.values, target)
+	d.refs.last = len(d.refs.values)
+
+	return d.refs.last
+}
+
+// startObject gives the object that is being decoded, which is the value
+// started last, its value before its properties are decoded. This allows
+// them to refer back to it.
+func (d *decoder) startObject(value interface{}) {
+	if d.refs == nil || !d.cycles || d.refs.last == 0 {
+		return
+	}
+
+	target := &d.refs.values[d.refs.last-1]
+	if ref, ok := target.value.(*Reference); ok {
+		ref.Value = value
+	} else {
+		target.value = value
+	}
+}
+
+// finishValue records the decoded value for the number returned by
+// startValue. The value that should be used instead of it, which is a
+// *Reference if it is referred to by R:, is returned.
+func (d *decoder) finishValue(id int, value interface{}) interface{} {
+	if d.refs == nil || id == 0 {
+		return value
+	}
+
+	target := &d.refs.values[id-1]
+	target.complete = true
+
+	if ref := d.refs.targets[id]; ref != nil {
+		ref.Value = value
+		value = ref
+	}
+
+	target.value = value
+
+	return value
+}
+
+// consumeReference reads r: or R:, which refer to a value that has already
+// been decoded. The number of the value starts at offset+2.
+func (d *decoder) consumeReference(data []byte, offset int) (interface{}, int, error) {
+	if !checkType(data, data[offset], offset) {
+		return nil, -1, errors.New("not a reference")
+	}
+
+	raw, end := consumeBytesUntilByte(data, ';', offset+2)
+	if end < 0 {
+		return nil, -1, errors.New("not a reference")
+	}
+
+	id, err := strconv.Atoi(bytesToString(raw))
+	if err != nil {
+		return nil, -1, err
+	}
+
+	if id < 1 || id > len(d.refs.values) {
+		return nil, -1, fmt.Errorf("can not decode reference to value %d", id)
+	}
+
+	if data[offset] == 'R' && d.refs.scanning {
+		d.refs.targets[id] = nil
+	}
+
+	target := d.refs.values[id-1]
+	if !target.started && d.refs.ids != nil {
+		// A Reader has not decoded the value, so it is decoded now from
+		// where it starts.
+		if _, _, err := d.consumeNext(data, target.offset); err != nil {
+			return nil, -1, err
+		}
+
+		target = d.refs.values[id-1]
+	}
+
+	if !target.complete && target.value == nil {
+		return nil, -1, fmt.Errorf("can not decode reference to value %d that contains it", id)
+	}
+
+	if data[offset] == 'R' {
+		return target.value, end + 1, nil
+	}
+
+	// r: is the same object, rather than a reference to the value that
+	// holds it. It is numbered like any other value.
+	if !target.object {
+		return nil, -1, fmt.Errorf("can not decode object reference to value %d that is not an object", id)
+	}
+
+	value := target.value
+	if ref, ok := value.(*Reference); ok {
+		value = ref.Value
+	}
+
+	id = d.startValue(data, offset)
+	if id != 0 {
+		d.refs.values[id-1].object = true
+	}
+
+	return d.finishValue(id, value), end + 1, nil
+}
@@ -0,0 +1,236 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+// The first element of each array is referred to by the second.
+const (
+	valueReferenceData  = `a:3:{i:0;s:3:"abc";i:1;R:2;i:2;s:1:"x";}`
+	objectReferenceData = `a:2:{i:0;O:8:"stdClass":1:{s:1:"a";i:1;}i:1;r:2;}`
+)
+
+func TestUnmarshalReferences(t *testing.T) {
+	var values []interface{}
+	err := phpserialize.Unmarshal([]byte(valueReferenceData), &values)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if len(values) != 3 || values[0] != "abc" || values[1] != "abc" || values[2] != "x" {
+		t.Errorf("Unexpected result %#v", values)
+	}
+
+	var strings []string
+	err = phpserialize.Unmarshal([]byte(valueReferenceData), &strings)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if len(strings) != 3 || strings[1] != "abc" {
+		t.Errorf("Unexpected result %#v", strings)
+	}
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	err = phpserialize.UnmarshalWithOptions([]byte(objectReferenceData), &values, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if _, ok := values[0].(*phpserialize.Object); !ok || values[0] != values[1] {
+		t.Errorf("Expected the same object twice, got %#v", values)
+	}
+
+	// Keys are not numbered, so the second property refers to the first.
+	var m *orderedmap.OrderedMap[any, any]
+	err = phpserialize.Unmarshal([]byte(`O:1:"A":2:{s:1:"a";i:5;s:1:"b";R:2;}`), &m)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if b, _ := m.Get("b"); b != int64(5) {
+		t.Errorf("Expected 5, got %#v", b)
+	}
+}
+
+func TestUnmarshalKeepReferences(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.KeepReferences = true
+
+	var values []interface{}
+	err := phpserialize.UnmarshalWithOptions([]byte(valueReferenceData), &values, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	ref, ok := values[0].(*phpserialize.Reference)
+	if !ok || values[1] != ref || ref.Value != "abc" {
+		t.Fatalf("Expected the same reference twice, got %#v", values)
+	}
+
+	if values[2] != "x" {
+		t.Errorf("Expected x, got %#v", values[2])
+	}
+
+	// A reference is encoded as the value that it holds.
+	data, err := phpserialize.Marshal(values, nil)
+	expectErrorToNotHaveOccurred(t, err)
+	if expected := `a:3:{i:0;s:3:"abc";i:1;s:3:"abc";i:2;s:1:"x";}`; string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	// Fields that are not an interface{} receive the value.
+	type pair struct {
+		A string `php:"a"`
+		B string `php:"b"`
+	}
+
+	var p pair
+	err = phpserialize.UnmarshalWithOptions([]byte(`O:4:"pair":2:{s:1:"a";s:3:"abc";s:1:"b";R:2;}`), &p, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if p.A != "abc" || p.B != "abc" {
+		t.Errorf("Unexpected result %+v", p)
+	}
+}
+
+func TestUnmarshalSelfReference(t *testing.T) {
+	data := []byte(`O:8:"stdClass":2:{s:4:"self";r:1;s:4:"list";a:1:{i:0;R:1;}}`)
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	var value interface{}
+	err := phpserialize.NewReader(data, options).Decode(&value)
+	expectErrorToNotHaveOccurred(t, err)
+
+	o, ok := value.(*phpserialize.Object)
+	if !ok {
+		t.Fatalf("Expected an object, got %#v", value)
+	}
+
+	self, _ := o.Properties.Get("self")
+	list, _ := o.Properties.Get("list")
+	if self != o || !reflect.DeepEqual(list, []interface{}{o}) {
+		t.Errorf("Expected the object to contain itself, got %v and %v", self, list)
+	}
+
+	var buf strings.Builder
+	expectErrorToNotHaveOccurred(t, phpserialize.VarDump(&buf, value))
+
+	expected := `object(stdClass)#1 (2) {
+  ["self"]=>
+  *RECURSION*
+  ["list"]=>
+  array(1) {
+    [0]=>
+    *RECURSION*
+  }
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	// Without DecodeObjects it is the ordered map that contains itself.
+	var m *orderedmap.OrderedMap[any, any]
+	err = phpserialize.Unmarshal(data, &m)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if self, _ := m.Get("self"); self != m {
+		t.Errorf("Expected the map to contain itself, got %v", self)
+	}
+
+	// An array can only contain itself through a *Reference.
+	options.KeepReferences = true
+
+	value = nil
+	err = phpserialize.NewReader([]byte(`a:1:{i:0;R:1;}`), options).Decode(&value)
+	expectErrorToNotHaveOccurred(t, err)
+
+	ref, ok := value.(*phpserialize.Reference)
+	if !ok {
+		t.Fatalf("Expected a reference, got %#v", value)
+	}
+
+	if items, _ := ref.Value.([]interface{}); len(items) != 1 || items[0] != ref {
+		t.Errorf("Expected the reference to contain itself, got %#v", ref.Value)
+	}
+
+	// Any other type would have to contain itself as well.
+	type node struct {
+		Self *node `php:"self"`
+	}
+
+	var n node
+	err = phpserialize.Unmarshal([]byte(`O:4:"node":1:{s:4:"self";r:1;}`), &n)
+	expectErrorToEqual(t, err, errors.New("can not decode reference to value 1 that contains it"))
+}
+
+func TestMarshalSelfReference(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+	options.KeepReferences = true
+
+	for _, input := range []string{
+		`a:1:{s:1:"o";O:8:"stdClass":1:{s:4:"self";r:2;}}`,
+		`O:8:"stdClass":1:{s:4:"self";r:1;}`,
+		`a:1:{i:0;R:1;}`,
+	} {
+		t.Run(input, func(t *testing.T) {
+			var value interface{}
+			err := phpserialize.NewReader([]byte(input), options).Decode(&value)
+			expectErrorToNotHaveOccurred(t, err)
+
+			_, err = phpserialize.Marshal(value, nil)
+			expectErrorToEqual(t, err, errors.New("can not encode a value that contains itself"))
+		})
+	}
+
+	// The same value can be encoded more than once if it does not contain
+	// itself.
+	data := `a:2:{i:0;O:8:"stdClass":0:{}i:1;r:2;}`
+
+	var value interface{}
+	err := phpserialize.NewReader([]byte(data), options).Decode(&value)
+	expectErrorToNotHaveOccurred(t, err)
+
+	result, err := phpserialize.Marshal(value, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `a:2:{i:0;O:8:"stdClass":0:{}i:1;O:8:"stdClass":0:{}}`
+	if string(result) != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestUnmarshalReferenceErrors(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedError error
+	}{
+		"unknown value": {
+			`a:1:{i:0;R:5;}`,
+			errors.New("can not decode reference to value 5"),
+		},
+		"containing value": {
+			`a:1:{i:0;R:1;}`,
+			errors.New("can not decode reference to value 1 that contains it"),
+		},
+		"not an object": {
+			`a:2:{i:0;i:1;i:1;r:2;}`,
+			errors.New("can not decode object reference to value 2 that is not an object"),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			for _, keep := range []bool{false, true} {
+				options := phpserialize.DefaultUnmarshalOptions()
+				options.KeepReferences = keep
+
+				var values []interface{}
+				err := phpserialize.UnmarshalWithOptions([]byte(test.input), &values, options)
+				expectErrorToEqual(t, err, test.expectedError)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package phpserialize
 
 import (
-	"bytes"
+	"encoding"
+	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
 )
 
 // MarshalOptions must be provided when invoking Marshal(). Use
@@ -15,6 +19,37 @@ type MarshalOptions struct {
 	// If this is true, then all struct names will be stripped from objects
 	// and "stdClass" will be used instead. The default value is false.
 	OnlyStdClass bool
+
+	// FieldNamer is used to name the properties of struct fields that do not
+	// have a name in their "php" tag. The default value is nil, which is the
+	// same as LowerFirstNamer.
+	FieldNamer FieldNamer
+
+	// If NormalizeKeys is true then the keys of maps are converted in the
+	// same way as PHP converts array keys. For example, the string "1"
+	// becomes the integer 1, a float is truncated and a bool becomes 0 or 1.
+	// This makes the output match what PHP itself would produce. The default
+	// value is false.
+	NormalizeKeys bool
+
+	// KeyOrder is the order that the keys of maps are encoded in. The
+	// default value is KeyOrderDefault. Keys that are not a bool, number,
+	// string or encoding.TextMarshaler can only be encoded with KeyOrderNone
+	// or KeyCompare.
+	KeyOrder KeyOrder
+
+	// KeyCompare, if it is not nil, is used to sort the keys of maps instead
+	// of KeyOrder. It is passed the map keys (or the normalized keys, when
+	// NormalizeKeys is true) and returns a negative number, zero or a
+	// positive number in the same way as cmp.Compare. The default value is
+	// nil.
+	KeyCompare func(a, b interface{}) int
+
+	// visiting holds the ordered maps and references that are being
+	// encoded, so that a value that contains itself is an error instead of
+	// recursing forever. It is only set on a copy of the options, which is
+	// made by startEncoding.
+	visiting map[interface{}]bool
 }
 
 // DefaultMarshalOptions will create a new instance of MarshalOptions with
@@ -22,6 +57,10 @@ type MarshalOptions struct {
 func DefaultMarshalOptions() *MarshalOptions {
 	options := new(MarshalOptions)
 	options.OnlyStdClass = false
+	options.FieldNamer = nil
+	options.NormalizeKeys = false
+	options.KeyOrder = KeyOrderDefault
+	options.KeyCompare = nil
 
 	return options
 }
@@ -36,11 +75,7 @@ func DefaultMarshalOptions() *MarshalOptions {
 //
 //     Marshal(true)
 func MarshalBool(value bool) []byte {
-	if value {
-		return []byte("b:1;")
-	}
-
-	return []byte("b:0;")
+	return AppendBool(nil, value)
 }
 
 // MarshalInt returns the bytes to represent a PHP serialized integer value.
@@ -53,13 +88,13 @@ func MarshalBool(value bool) []byte {
 //
 //     Marshal(123)
 func MarshalInt(value int64) []byte {
-	return []byte("i:" + strconv.FormatInt(value, 10) + ";")
+	return AppendInt(nil, value)
 }
 
 // MarshalUint is provided for compatibility with unsigned types in Go. It works
 // the same way as MarshalInt.
 func MarshalUint(value uint64) []byte {
-	return []byte("i:" + strconv.FormatUint(value, 10) + ";")
+	return AppendUint(nil, value)
 }
 
 // MarshalFloat returns the bytes to represent a PHP serialized floating-point
@@ -82,7 +117,7 @@ func MarshalUint(value uint64) []byte {
 //
 //     Marshal(1.23)
 func MarshalFloat(value float64, bitSize int) []byte {
-	return []byte("d:" + strconv.FormatFloat(value, 'f', -1, bitSize) + ";")
+	return AppendFloat(nil, value, bitSize)
 }
 
 // MarshalString returns the bytes to represent a PHP serialized string value.
@@ -98,11 +133,7 @@ func MarshalFloat(value float64, bitSize int) []byte {
 // One important distinction is that PHP stores binary data in strings. See
 // MarshalBytes for more information.
 func MarshalString(value string) []byte {
-	// As far as I can tell only the single-quote is escaped. Not even the
-	// backslash itself is escaped. Weird. See escapeTests for more information.
-	value = strings.Replace(value, "'", "\\'", -1)
-
-	return []byte(fmt.Sprintf("s:%d:\"%s\";", len(value), value))
+	return AppendString(nil, value)
 }
 
 // MarshalBytes returns the bytes to represent a PHP serialized string value
@@ -114,12 +145,7 @@ func MarshalString(value string) []byte {
 // this condition and allow either a string or []byte when unserializing a PHP
 // string.
 func MarshalBytes(value []byte) []byte {
-	var buffer bytes.Buffer
-	for _, c := range value {
-		buffer.WriteString(fmt.Sprintf("\\x%02x", c))
-	}
-
-	return []byte(fmt.Sprintf("s:%d:\"%s\";", len(value), buffer.String()))
+	return AppendBytes(nil, value)
 }
 
 // MarshalNil returns the bytes to represent a PHP serialized null value.
@@ -131,7 +157,7 @@ func MarshalBytes(value []byte) []byte {
 // Unlike the other specific Marshal functions it does not take an argument
 // because the output is a constant value.
 func MarshalNil() []byte {
-	return []byte("N;")
+	return AppendNil(nil)
 }
 
 // MarshalStruct returns the bytes that represent a PHP encoded class from a
@@ -140,65 +166,244 @@ func MarshalNil() []byte {
 // Fields that are not exported (starting with a lowercase letter) will not be
 // present in the output. All fields that appear in the output will have their
 // first letter converted to lowercase. Any other uppercase letters in the field
-// name are maintained. At the moment there is no way to change this behaviour,
-// unlike other marshallers that use a tag on the field.
+// name are maintained. This can be changed for all fields with the FieldNamer
+// option, or for a single field with a "php" tag on the field. The tag "-" will
+// always omit the field.
+//
+// The fields of embedded structs are promoted into the PHP object as if they
+// were declared in the outer struct. See typeFields for how conflicting names
+// are resolved.
+//
+// The tag may also contain a comma-separated list of options after the name:
+//
+//	omitnilptr - omit the field if it is a nil pointer.
+//	omitempty  - omit the field if it is false, 0, a nil pointer, a nil
+//	             interface value, or an empty string, slice, map or
+//	             *orderedmap.OrderedMap.
+//	omitzero   - omit the field if it is the zero value for its type. If the
+//	             field has an "IsZero() bool" method that will be used instead.
+//	string     - encode a bool, integer or float field as a PHP string that
+//	             contains the value, such as s:2:"42"; instead of i:42;.
+//	rest       - the field must be a *orderedmap.OrderedMap[any, any]. Its
+//	             entries are written as extra properties of the object. This
+//	             is how properties that are unknown to the struct survive
+//	             being decoded and encoded again.
+//	alias=a|b  - other property names that the field can be decoded from.
+//	             They are not used when encoding.
+//
+// A time.Time field is encoded as a PHP DateTime object (see MarshalTime), or
+// with one of these options:
+//
+//	immutable  - a DateTimeImmutable object instead.
+//	unix       - an integer Unix timestamp.
+//	format=x   - a string formatted with the Go layout x, such as
+//	             format=2006-01-02. The layout can not contain a comma.
+//
+// A time.Duration field with the "interval" option is encoded as a PHP
+// DateInterval object (see NewDateInterval).
 func MarshalStruct(input interface{}, options *MarshalOptions) ([]byte, error) {
-	value := reflect.ValueOf(input)
-	typeOfValue := value.Type()
+	return appendStruct(nil, reflect.ValueOf(input), options)
+}
 
-	// Some of the fields in the struct may not be visible (unexported). We
-	// need to make sure we count all the visible ones for the final result.
+func appendStruct(dst []byte, value reflect.Value, options *MarshalOptions) ([]byte, error) {
+	// Some of the fields in the struct may be omitted. We need to make sure
+	// we count all the visible ones for the final result, so the fields are
+	// encoded into a scratch buffer before the header is written.
 	visibleFieldCount := 0
 
-	var buffer bytes.Buffer
-	for i := 0; i < value.NumField(); i++ {
-		f := value.Field(i)
+	fields := cachedTypeFields(value.Type(), options.FieldNamer)
+
+	scratch := getScratch()
+	defer putScratch(scratch)
 
-		if !f.CanInterface() {
-			// This is an unexported field, we cannot read it.
+	buffer := *scratch
+	for i := range fields.list {
+		field := &fields.list[i]
+
+		// The field will not be found if it belongs to an embedded
+		// struct pointer that is nil.
+		f, ok := fieldByIndex(value, field.index, false)
+		if !ok || omitField(f, field) {
 			continue
 		}
 
-		visibleFieldCount++
+		var err error
+		buffer = append(buffer, field.nameBytes...)
+		buffer, err = field.encode(buffer, f, options)
+		if err != nil {
+			return nil, err
+		}
 
-		fieldName, fieldOptions := parseTag(typeOfValue.Field(i).Tag.Get("php"))
+		visibleFieldCount++
+	}
 
-		if fieldOptions.Contains("omitnilptr") {
-			if f.Kind() == reflect.Ptr && f.IsNil() {
-				visibleFieldCount--
-				continue
+	// Properties that were collected by a "rest" field are written after
+	// all the other fields.
+	if fields.rest != nil {
+		f, ok := fieldByIndex(value, fields.rest.index, false)
+		if ok && !f.IsNil() {
+			rest := f.Interface().(*orderedmap.OrderedMap[any, any])
+			for key, v := range rest.AllFromFront() {
+				// A field always takes priority over an
+				// unknown property with the same name.
+				if name, ok := key.(string); ok && fields.has(name) {
+					continue
+				}
+
+				var err error
+				buffer, err = appendMarshal(buffer, key, options)
+				if err != nil {
+					return nil, err
+				}
+
+				buffer, err = appendMarshal(buffer, v, options)
+				if err != nil {
+					return nil, err
+				}
+
+				visibleFieldCount++
 			}
 		}
+	}
 
-		if fieldName == "-" {
-			visibleFieldCount--
-			continue
-		} else if fieldName == "" {
-			fieldName = lowerCaseFirstLetter(typeOfValue.Field(i).Name)
+	// The buffer may have grown, in which case the larger one is kept.
+	*scratch = buffer
+
+	className := value.Type().Name()
+	if options.OnlyStdClass {
+		className = "stdClass"
+	}
+
+	dst = appendObjectHeader(dst, className, visibleFieldCount)
+	dst = append(dst, buffer...)
+
+	return append(dst, '}'), nil
+}
+
+// appendAsString encodes a bool, integer or float as a string. Any other type
+// is encoded normally.
+func appendAsString(dst []byte, f reflect.Value, options *MarshalOptions) ([]byte, error) {
+	v := f
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return AppendNil(dst), nil
 		}
-		buffer.Write(MarshalString(fieldName))
+		v = v.Elem()
+	}
 
-		m, err := Marshal(f.Interface(), options)
-		if err != nil {
-			return nil, err
+	switch v.Kind() {
+	case reflect.Bool:
+		return AppendString(dst, strconv.FormatBool(v.Bool())), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendString(dst, strconv.FormatInt(v.Int(), 10)), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return AppendString(dst, strconv.FormatUint(v.Uint(), 10)), nil
+
+	case reflect.Float32:
+		return AppendString(dst, strconv.FormatFloat(v.Float(), 'f', -1, 32)), nil
+
+	case reflect.Float64:
+		return AppendString(dst, strconv.FormatFloat(v.Float(), 'f', -1, 64)), nil
+	}
+
+	return appendMarshal(dst, f.Interface(), options)
+}
+
+// omitField returns true if the field should not be included in the output
+// because of one of the omit options in its tag.
+func omitField(f reflect.Value, field *field) bool {
+	if field.omitNilPtr && f.Kind() == reflect.Ptr && f.IsNil() {
+		return true
+	}
+
+	if field.omitEmpty && isEmptyValue(f) {
+		return true
+	}
+
+	return field.omitZero && isZeroValue(f)
+}
+
+// isEmptyValue follows the same rules as encoding/json for omitempty, except
+// that an OrderedMap with no entries is also empty, like a map.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+
+	case reflect.Bool:
+		return !v.Bool()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+
+	case reflect.Interface:
+		return v.IsNil()
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return true
 		}
 
-		buffer.Write(m)
+		// An OrderedMap is empty in the same way a map is. Other types
+		// with a Len method are not.
+		if isOrderedMap(v.Type()) {
+			return v.MethodByName("Len").Call(nil)[0].Int() == 0
+		}
 	}
 
-	className := reflect.ValueOf(input).Type().Name()
-	if options.OnlyStdClass {
-		className = "stdClass"
+	return false
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+// isZeroValue uses the IsZero() method when the type provides one (such as
+// time.Time) and otherwise compares against the zero value of the type.
+func isZeroValue(v reflect.Value) bool {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true
+	}
+
+	if z, ok := v.Interface().(isZeroer); ok {
+		return z.IsZero()
+	}
+
+	// The method may only be defined on the pointer receiver.
+	if v.CanAddr() {
+		if z, ok := v.Addr().Interface().(isZeroer); ok {
+			return z.IsZero()
+		}
+	} else if reflect.PointerTo(v.Type()).Implements(reflect.TypeOf((*isZeroer)(nil)).Elem()) {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+
+		return ptr.Interface().(isZeroer).IsZero()
 	}
 
-	return []byte(fmt.Sprintf("O:%d:\"%s\":%d:{%s}", len(className),
-		className, visibleFieldCount, buffer.String())), nil
+	return v.IsZero()
 }
 
 // Marshal is the canonical way to perform the equivalent of serialize() in PHP.
 // It can handle encoding scalar types, slices and maps.
+//
+// Types that implement encoding.TextMarshaler are encoded as a PHP string of
+// their text, unless they are one of the types that this package handles
+// itself, such as time.Time and *big.Int.
 func Marshal(input interface{}, options *MarshalOptions) ([]byte, error) {
+	return appendMarshal(nil, input, options)
+}
 
+func appendMarshal(dst []byte, input interface{}, options *MarshalOptions) ([]byte, error) {
 	if options == nil {
 		options = DefaultMarshalOptions()
 	}
@@ -206,108 +411,294 @@ func Marshal(input interface{}, options *MarshalOptions) ([]byte, error) {
 	// []byte is a special case because all strings (binary and otherwise)
 	// are handled as strings in PHP.
 	if bytesToEncode, ok := input.([]byte); ok {
-		return MarshalBytes(bytesToEncode), nil
+		return AppendBytes(dst, bytesToEncode), nil
 	}
 
 	// Nil is another special case because it is typeless and must be
 	// handled before trying to determine the type.
 	if input == nil {
-		return MarshalNil(), nil
+		return AppendNil(dst), nil
+	}
+
+	switch v := input.(type) {
+	case Marshaler:
+		if value := reflect.ValueOf(v); value.Kind() == reflect.Ptr && value.IsNil() {
+			return AppendNil(dst), nil
+		}
+
+		return v.MarshalPHP(dst, options)
+
+	case *Reference:
+		if v == nil {
+			return AppendNil(dst), nil
+		}
+
+		options, err := options.startEncoding(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(options.visiting, v)
+
+		return appendMarshal(dst, v.Value, options)
+
+	case *Object:
+		if v == nil {
+			return AppendNil(dst), nil
+		}
+
+		return appendObject(dst, v, options)
+
+	case Object:
+		return appendObject(dst, &v, options)
+
+	case time.Time:
+		return append(dst, MarshalTime(v, options)...), nil
+
+	case DateInterval:
+		return append(dst, MarshalDateInterval(v, options)...), nil
+
+	case Number:
+		m, err := MarshalNumber(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, m...), nil
+
+	case *big.Int:
+		if v == nil {
+			return AppendNil(dst), nil
+		}
+
+		return append(dst, MarshalBigInt(v)...), nil
+
+	case *big.Float:
+		if v == nil {
+			return AppendNil(dst), nil
+		}
+
+		return append(dst, MarshalBigFloat(v)...), nil
+
+	case *big.Rat:
+		if v == nil {
+			return AppendNil(dst), nil
+		}
+
+		return append(dst, MarshalBigRat(v)...), nil
+
+	case big.Int:
+		return append(dst, MarshalBigInt(&v)...), nil
+
+	case big.Float:
+		return append(dst, MarshalBigFloat(&v)...), nil
+
+	case big.Rat:
+		return append(dst, MarshalBigRat(&v)...), nil
+
+	case encoding.TextMarshaler:
+		m, err := marshalText(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, m...), nil
 	}
 
-	// Otherwise we need to decide if it is a scalar value, map or slice.
 	value := reflect.ValueOf(input)
+
+	// The MarshalText method may only be defined on the pointer receiver.
+	if value.Type().PkgPath() != "" && reflect.PointerTo(value.Type()).Implements(textMarshalerType) {
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+
+		m, err := marshalText(ptr.Interface().(encoding.TextMarshaler))
+		if err != nil {
+			return nil, err
+		}
+
+		return append(dst, m...), nil
+	}
+
+	// Otherwise we need to decide if it is a scalar value, map or slice.
 	switch value.Kind() {
 	case reflect.Bool:
-		return MarshalBool(value.Bool()), nil
+		return AppendBool(dst, value.Bool()), nil
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
 		reflect.Int64:
-		return MarshalInt(value.Int()), nil
+		return AppendInt(dst, value.Int()), nil
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return MarshalUint(value.Uint()), nil
+		return AppendUint(dst, value.Uint()), nil
 
 	case reflect.Float32:
-		return MarshalFloat(value.Float(), 32), nil
+		return AppendFloat(dst, value.Float(), 32), nil
 
 	case reflect.Float64:
-		return MarshalFloat(value.Float(), 64), nil
+		return AppendFloat(dst, value.Float(), 64), nil
 
 	case reflect.String:
-		return MarshalString(value.String()), nil
+		return AppendString(dst, value.String()), nil
 
 	case reflect.Slice:
-		return marshalSlice(value.Interface(), options)
+		return appendSlice(dst, value, options)
 
 	case reflect.Map:
-		return marshalMap(value.Interface(), options)
+		return appendMap(dst, value, options)
 
 	case reflect.Struct:
-		return MarshalStruct(input, options)
+		return appendStruct(dst, value, options)
 
 	case reflect.Ptr:
 		if value.IsNil() {
-			return MarshalNil(), nil
+			return AppendNil(dst), nil
+		}
+
+		if isOrderedMap(value.Type()) {
+			return appendOrderedMap(dst, value, options)
 		}
-		return Marshal(value.Elem().Interface(), options)
+
+		return appendMarshal(dst, value.Elem().Interface(), options)
 
 	default:
 		return nil, fmt.Errorf("can not encode: %T", input)
 	}
 }
 
-func marshalSlice(input interface{}, options *MarshalOptions) ([]byte, error) {
-	s := reflect.ValueOf(input)
-
-	var buffer bytes.Buffer
+func appendSlice(dst []byte, s reflect.Value, options *MarshalOptions) ([]byte, error) {
+	dst = appendArrayHeader(dst, s.Len())
 	for i := 0; i < s.Len(); i++ {
-		m, err := Marshal(i, options)
+		dst = AppendInt(dst, int64(i))
+
+		var err error
+		dst, err = appendMarshal(dst, s.Index(i).Interface(), options)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	return append(dst, '}'), nil
+}
 
-		buffer.Write(m)
+func appendMap(dst []byte, s reflect.Value, options *MarshalOptions) ([]byte, error) {
+	mapKeys := s.MapKeys()
+	keys := make([]interface{}, len(mapKeys))
+	values := make([]interface{}, len(mapKeys))
+	for i, mapKey := range mapKeys {
+		keys[i] = mapKey.Interface()
+		values[i] = s.MapIndex(mapKey).Interface()
+	}
 
-		m, err = Marshal(s.Index(i).Interface(), options)
+	// Go randomises maps. To be able to test this we need to make sure the
+	// map keys always come out in the same order. So we sort them first.
+	sortErr := sortEntries(keys, values, options)
+
+	if options.NormalizeKeys {
+		// A key that can not be sorted can not be an array key either,
+		// which is the more useful error.
+		var err error
+		keys, values, err = normalizeEntries(keys, values, options)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	if sortErr != nil {
+		return nil, sortErr
+	}
 
-		buffer.Write(m)
+	if options.NormalizeKeys {
+		// The keys need to be sorted again now that some of them are
+		// integers.
+		if err := sortEntries(keys, values, options); err != nil {
+			return nil, err
+		}
 	}
 
-	return []byte(fmt.Sprintf("a:%d:{%s}", s.Len(), buffer.String())), nil
+	return appendEntries(dst, keys, values, options)
 }
 
-func marshalMap(input interface{}, options *MarshalOptions) ([]byte, error) {
-	s := reflect.ValueOf(input)
+// appendOrderedMap encodes an *orderedmap.OrderedMap with any type of keys and
+// values as a PHP array, keeping the order of its entries.
+func appendOrderedMap(dst []byte, v reflect.Value, options *MarshalOptions) ([]byte, error) {
+	options, err := options.startEncoding(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	defer delete(options.visiting, v.Interface())
 
-	// Go randomises maps. To be able to test this we need to make sure the
-	// map keys always come out in the same order. So we sort them first.
-	mapKeys := s.MapKeys()
-	sort.Slice(mapKeys, func(i, j int) bool {
-		return lessValue(mapKeys[i], mapKeys[j])
-	})
+	var keys, values []interface{}
+
+	if m, ok := v.Interface().(*orderedmap.OrderedMap[any, any]); ok {
+		keys = make([]interface{}, 0, m.Len())
+		values = make([]interface{}, 0, m.Len())
+		for key, value := range m.AllFromFront() {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+	} else {
+		// The type parameters are not known, so the elements have to be
+		// walked through with reflection.
+		for el := v.MethodByName("Front").Call(nil)[0]; !el.IsNil(); el = el.MethodByName("Next").Call(nil)[0] {
+			keys = append(keys, el.Elem().FieldByName("Key").Interface())
+			values = append(values, el.Elem().FieldByName("Value").Interface())
+		}
+	}
 
-	var buffer bytes.Buffer
-	for _, mapKey := range mapKeys {
-		m, err := Marshal(mapKey.Interface(), options)
+	if options.NormalizeKeys {
+		keys, values, err = normalizeEntries(keys, values, options)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	return appendEntries(dst, keys, values, options)
+}
+
+// startEncoding marks value, which is an ordered map or a reference, as being
+// encoded. It returns the options that the values it contains are encoded
+// with, which must be passed to finish it with delete(options.visiting, value).
+func (options *MarshalOptions) startEncoding(value interface{}) (*MarshalOptions, error) {
+	if options.visiting[value] {
+		return nil, errors.New("can not encode a value that contains itself")
+	}
+
+	if options.visiting == nil {
+		copied := *options
+		copied.visiting = map[interface{}]bool{}
+		options = &copied
+	}
+
+	options.visiting[value] = true
+
+	return options, nil
+}
 
-		buffer.Write(m)
+// isOrderedMap returns true if t is a pointer to an orderedmap.OrderedMap of
+// any type.
+func isOrderedMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr &&
+		t.Elem().PkgPath() == orderedMapType.Elem().PkgPath() &&
+		strings.HasPrefix(t.Elem().Name(), "OrderedMap[")
+}
 
-		m, err = Marshal(s.MapIndex(mapKey).Interface(), options)
+// appendEntries encodes the keys and values as a PHP array, in order.
+func appendEntries(dst []byte, keys, values []interface{}, options *MarshalOptions) ([]byte, error) {
+	dst = appendArrayHeader(dst, len(keys))
+	for i, key := range keys {
+		var err error
+		dst, err = appendMarshal(dst, key, options)
 		if err != nil {
 			return nil, err
 		}
 
-		buffer.Write(m)
+		dst, err = appendMarshal(dst, values[i], options)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return []byte(fmt.Sprintf("a:%d:{%s}", s.Len(), buffer.String())), nil
+	return append(dst, '}'), nil
 }
 
 func lowerCaseFirstLetter(s string) string {
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/elliotchance/orderedmap/v3"
 	"github.com/jamteacoffee/phpserialize"
 )
 
@@ -45,6 +46,119 @@ type Nillable struct {
 	BarPtr *Struct2
 }
 
+type structOmitEmpty struct {
+	Bool   bool              `php:",omitempty"`
+	Int    int               `php:",omitempty"`
+	Float  float64           `php:",omitempty"`
+	String string            `php:",omitempty"`
+	Slice  []int             `php:",omitempty"`
+	Map    map[string]int    `php:",omitempty"`
+	Ptr    *Struct2          `php:",omitempty"`
+	Any    interface{}       `php:",omitempty"`
+	Struct Struct2           `php:",omitempty"`
+	Named  string            `php:"name,omitempty"`
+	Always map[string]string `php:"always"`
+
+	Ordered *orderedmap.OrderedMap[any, any] `php:",omitempty"`
+	Lengthy *lengthy                         `php:",omitempty"`
+}
+
+// lengthy has a Len method, but is not empty in the way that a map is.
+type lengthy struct {
+	N int
+}
+
+func (l *lengthy) Len() int {
+	return 0
+}
+
+type zeroer struct {
+	Value int
+}
+
+func (z zeroer) IsZero() bool {
+	return z.Value < 0
+}
+
+type structOmitZero struct {
+	Struct  Struct2 `php:",omitzero"`
+	Int     int     `php:",omitzero"`
+	Slice   []int   `php:",omitzero"`
+	Zeroer  zeroer  `php:",omitzero"`
+	Pointer *zeroer `php:",omitzero"`
+}
+
+type Base struct {
+	ID   int
+	Name string
+}
+
+type base struct {
+	ID int
+}
+
+type embedded struct {
+	Base
+	Name  string
+	Extra bool
+}
+
+type embeddedPtr struct {
+	*Base
+	Value int
+}
+
+type embeddedTagged struct {
+	Base `php:"base"`
+}
+
+type embeddedUnexported struct {
+	base
+	Value int
+}
+
+type ConflictA struct {
+	X int
+	Y int
+}
+
+type ConflictB struct {
+	X int
+	Z int `php:"y"`
+}
+
+type embeddedConflict struct {
+	ConflictA
+	ConflictB
+}
+
+type structRest struct {
+	Foo  int
+	Rest *orderedmap.OrderedMap[any, any] `php:",rest"`
+}
+
+func newRest() *orderedmap.OrderedMap[any, any] {
+	rest := orderedmap.NewOrderedMap[any, any]()
+	rest.Set("zed", "z")
+	rest.Set("foo", 99)
+	rest.Set(int64(5), 1.5)
+
+	return rest
+}
+
+func newStringIntMap() *orderedmap.OrderedMap[string, int] {
+	m := orderedmap.NewOrderedMap[string, int]()
+	m.Set("z", 1)
+	m.Set("a", 2)
+	m.Set("m", 3)
+
+	return m
+}
+
+type structOrderedMap struct {
+	Items *orderedmap.OrderedMap[string, int]
+}
+
 type marshalTest struct {
 	input   interface{}
 	output  []byte
@@ -168,6 +282,102 @@ var marshalTests = map[string]marshalTest{
 		nil,
 	},
 
+	// encode object (struct with omitempty)
+	"structOmitEmpty{}": {
+		structOmitEmpty{},
+		[]byte("O:15:\"structOmitEmpty\":2:{s:6:\"struct\";O:7:\"Struct2\":1:{s:3:\"qux\";d:0;}s:6:\"always\";a:0:{}}"),
+		nil,
+	},
+	"structOmitEmpty{Int: 1, Slice: []int{}, Named: 'x'}": {
+		structOmitEmpty{Int: 1, Slice: []int{}, Named: "x"},
+		[]byte("O:15:\"structOmitEmpty\":4:{s:3:\"int\";i:1;s:6:\"struct\";O:7:\"Struct2\":1:{s:3:\"qux\";d:0;}s:4:\"name\";s:1:\"x\";s:6:\"always\";a:0:{}}"),
+		nil,
+	},
+
+	"structOmitEmpty{Ordered: empty, Lengthy: &lengthy{}}": {
+		structOmitEmpty{Ordered: orderedmap.NewOrderedMap[any, any](), Lengthy: &lengthy{}},
+		[]byte("O:15:\"structOmitEmpty\":3:{s:6:\"struct\";O:7:\"Struct2\":1:{s:3:\"qux\";d:0;}s:6:\"always\";a:0:{}s:7:\"lengthy\";O:7:\"lengthy\":1:{s:1:\"n\";i:0;}}"),
+		nil,
+	},
+
+	// encode object (struct with omitzero)
+	"structOmitZero{}": {
+		structOmitZero{},
+		[]byte("O:14:\"structOmitZero\":1:{s:6:\"zeroer\";O:6:\"zeroer\":1:{s:5:\"value\";i:0;}}"),
+		nil,
+	},
+	"structOmitZero{Slice: []int{}, Zeroer: zeroer{-1}, Pointer: &zeroer{-1}}": {
+		structOmitZero{Slice: []int{}, Zeroer: zeroer{-1}, Pointer: &zeroer{-1}},
+		[]byte("O:14:\"structOmitZero\":1:{s:5:\"slice\";a:0:{}}"),
+		nil,
+	},
+
+	// encode object (struct with embedded structs)
+	"embedded{Base{ID, Name}, Name, Extra}": {
+		embedded{Base{1, "base"}, "outer", true},
+		[]byte("O:8:\"embedded\":3:{s:2:\"iD\";i:1;s:4:\"name\";s:5:\"outer\";s:5:\"extra\";b:1;}"),
+		nil,
+	},
+	"embeddedPtr{*Base{ID, Name}, Value}": {
+		embeddedPtr{&Base{1, "base"}, 2},
+		[]byte("O:11:\"embeddedPtr\":3:{s:2:\"iD\";i:1;s:4:\"name\";s:4:\"base\";s:5:\"value\";i:2;}"),
+		nil,
+	},
+	"embeddedPtr{<nil>, Value}": {
+		embeddedPtr{nil, 2},
+		[]byte("O:11:\"embeddedPtr\":1:{s:5:\"value\";i:2;}"),
+		nil,
+	},
+	"embeddedTagged{Base}": {
+		embeddedTagged{Base{1, "base"}},
+		[]byte("O:14:\"embeddedTagged\":1:{s:4:\"base\";O:4:\"Base\":2:{s:2:\"iD\";i:1;s:4:\"name\";s:4:\"base\";}}"),
+		nil,
+	},
+	"embeddedUnexported{base{ID}, Value}": {
+		embeddedUnexported{base{1}, 2},
+		[]byte("O:18:\"embeddedUnexported\":2:{s:2:\"iD\";i:1;s:5:\"value\";i:2;}"),
+		nil,
+	},
+	"embeddedConflict{ConflictA{X, Y}, ConflictB{X, Z}}": {
+		embeddedConflict{ConflictA{1, 2}, ConflictB{3, 4}},
+		[]byte("O:16:\"embeddedConflict\":1:{s:1:\"y\";i:4;}"),
+		nil,
+	},
+
+	// encode object (struct with rest field)
+	"structRest{Foo, Rest <nil>}": {
+		structRest{1, nil},
+		[]byte("O:10:\"structRest\":1:{s:3:\"foo\";i:1;}"),
+		nil,
+	},
+	"structRest{Foo, Rest {zed, foo, 5}}": {
+		structRest{1, newRest()},
+		[]byte("O:10:\"structRest\":3:{s:3:\"foo\";i:1;s:3:\"zed\";s:1:\"z\";i:5;d:1.5;}"),
+		nil,
+	},
+
+	// encode OrderedMap
+	"*OrderedMap[any, any]{zed, foo, 5}": {
+		newRest(),
+		[]byte("a:3:{s:3:\"zed\";s:1:\"z\";s:3:\"foo\";i:99;i:5;d:1.5;}"),
+		nil,
+	},
+	"*OrderedMap[string, int]{z, a, m}": {
+		newStringIntMap(),
+		[]byte("a:3:{s:1:\"z\";i:1;s:1:\"a\";i:2;s:1:\"m\";i:3;}"),
+		nil,
+	},
+	"*OrderedMap[string, int] <nil>": {
+		(*orderedmap.OrderedMap[string, int])(nil),
+		[]byte("N;"),
+		nil,
+	},
+	"structOrderedMap{Items}": {
+		structOrderedMap{newStringIntMap()},
+		[]byte("O:16:\"structOrderedMap\":1:{s:5:\"items\";a:3:{s:1:\"z\";i:1;s:1:\"a\";i:2;s:1:\"m\";i:3;}}"),
+		nil,
+	},
+
 	// stdClassOnly
 	"struct1{Foo int, Bar Struct2{Qux float64}, hidden bool}: OnlyStdClass = true": {
 		struct1{10, Struct2{1.23}, true, "yay"},
@@ -240,3 +450,47 @@ func TestMarshalEscape(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalOrderedMapRoundTrip(t *testing.T) {
+	input := []byte("a:3:{s:1:\"b\";i:1;i:7;s:1:\"x\";s:1:\"a\";a:2:{i:0;b:1;i:1;N;}}")
+
+	m, err := phpserialize.UnmarshalAssociativeArray(input)
+	expectErrorToNotHaveOccurred(t, err)
+
+	result, err := phpserialize.Marshal(m, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if string(result) != string(input) {
+		t.Errorf("Expected '%s', got '%s'", input, result)
+	}
+
+	m.Delete("b")
+	m.Set("c", 2.5)
+
+	result, err = phpserialize.Marshal(m, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := "a:3:{i:7;s:1:\"x\";s:1:\"a\";a:2:{i:0;b:1;i:1;N;}s:1:\"c\";d:2.5;}"
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestMarshalOrderedMapNormalizeKeys(t *testing.T) {
+	options := phpserialize.DefaultMarshalOptions()
+	options.NormalizeKeys = true
+
+	m := orderedmap.NewOrderedMap[string, string]()
+	m.Set("b", "x")
+	m.Set("10", "y")
+	m.Set("2", "z")
+
+	result, err := phpserialize.Marshal(m, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	// The order is kept, even though the keys are normalized.
+	expected := "a:3:{s:1:\"b\";s:1:\"x\";i:10;s:1:\"y\";i:2;s:1:\"z\";}"
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
@@ -29,3 +29,21 @@ func (o tagOptions) Contains(option string) bool {
 	}
 	return false
 }
+
+// Get returns the value of an option that is in the form "key=value". The
+// second return value is false if the option is not present.
+func (o tagOptions) Get(key string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, key+"=") {
+			return s[len(key)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
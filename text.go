@@ -0,0 +1,46 @@
+package phpserialize
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// marshalText encodes a value that implements encoding.TextMarshaler as a PHP
+// string. A nil pointer is encoded as null.
+func marshalText(m encoding.TextMarshaler) ([]byte, error) {
+	if v := reflect.ValueOf(m); v.Kind() == reflect.Ptr && v.IsNil() {
+		return MarshalNil(), nil
+	}
+
+	text, err := m.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	return MarshalString(string(text)), nil
+}
+
+// isTextUnmarshaler returns true if a pointer to t implements
+// encoding.TextUnmarshaler. Only types declared in a package can have methods,
+// which avoids the more expensive check for most types.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return t.PkgPath() != "" && t.Kind() != reflect.Ptr &&
+		reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setText decodes a PHP string with UnmarshalText. The second return value is
+// false if v does not implement encoding.TextUnmarshaler, or value is not a
+// string, in which case nothing is done.
+func setText(v reflect.Value, value interface{}) (bool, error) {
+	s, ok := value.(string)
+	if !ok || !v.CanAddr() || !isTextUnmarshaler(v.Type()) || isSpecialType(v.Type()) {
+		return false, nil
+	}
+
+	return true, v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
@@ -0,0 +1,145 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type color int
+
+const (
+	red color = iota
+	green
+)
+
+func (c color) MarshalText() ([]byte, error) {
+	switch c {
+	case red:
+		return []byte("red"), nil
+	case green:
+		return []byte("green"), nil
+	}
+
+	return nil, fmt.Errorf("unknown color %d", int(c))
+}
+
+func (c *color) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "red":
+		*c = red
+	case "green":
+		*c = green
+	default:
+		return fmt.Errorf("unknown color %q", text)
+	}
+
+	return nil
+}
+
+// upper only implements TextMarshaler on its pointer receiver.
+type upper struct {
+	s string
+}
+
+func (u *upper) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(u.s)), nil
+}
+
+type server struct {
+	Addr    netip.Addr         `php:"addr"`
+	Backup  *netip.Addr        `php:"backup"`
+	Color   color              `php:"color"`
+	Weights map[netip.Addr]int `php:"weights"`
+	Labels  map[color]string   `php:"labels"`
+}
+
+func TestMarshalTextMarshaler(t *testing.T) {
+	tests := map[string]struct {
+		input  interface{}
+		output string
+	}{
+		"netip.Addr":       {netip.MustParseAddr("10.0.0.1"), `s:8:"10.0.0.1";`},
+		"enum":             {green, `s:5:"green";`},
+		"nil pointer":      {(*netip.Addr)(nil), `N;`},
+		"pointer receiver": {upper{"abc"}, `s:3:"ABC";`},
+		"map keys": {
+			map[netip.Addr]bool{
+				netip.MustParseAddr("10.0.0.2"): true,
+				netip.MustParseAddr("10.0.0.1"): false,
+			},
+			`a:2:{s:8:"10.0.0.1";b:0;s:8:"10.0.0.2";b:1;}`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := phpserialize.Marshal(test.input, nil)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.output {
+				t.Errorf("Expected '%s', got '%s'", test.output, result)
+			}
+		})
+	}
+
+	_, err := phpserialize.Marshal(color(5), nil)
+	expectErrorToEqual(t, err, errors.New("unknown color 5"))
+}
+
+func TestTextMarshalerRoundTrip(t *testing.T) {
+	backup := netip.MustParseAddr("::1")
+	input := server{
+		Addr:    netip.MustParseAddr("192.168.1.1"),
+		Backup:  &backup,
+		Color:   green,
+		Weights: map[netip.Addr]int{netip.MustParseAddr("10.0.0.1"): 5},
+		Labels:  map[color]string{red: "stop", green: "go"},
+	}
+
+	result, err := phpserialize.Marshal(input, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := `O:6:"server":5:{s:4:"addr";s:11:"192.168.1.1";s:6:"backup";s:3:"::1";` +
+		`s:5:"color";s:5:"green";s:7:"weights";a:1:{s:8:"10.0.0.1";i:5;}` +
+		`s:6:"labels";a:2:{s:5:"green";s:2:"go";s:3:"red";s:4:"stop";}}`
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+
+	var decoded server
+	err = phpserialize.Unmarshal(result, &decoded)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if !reflect.DeepEqual(decoded, input) {
+		t.Errorf("Expected %+v, got %+v", input, decoded)
+	}
+}
+
+func TestUnmarshalTextUnmarshaler(t *testing.T) {
+	var addr netip.Addr
+	err := phpserialize.Unmarshal([]byte(`s:8:"10.0.0.1";`), &addr)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if addr != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("Expected 10.0.0.1, got %s", addr)
+	}
+
+	// An integer is still decoded into an enum by its kind.
+	var c color
+	err = phpserialize.Unmarshal([]byte(`i:1;`), &c)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if c != green {
+		t.Errorf("Expected green, got %d", c)
+	}
+
+	var s server
+	err = phpserialize.Unmarshal([]byte(`O:6:"server":1:{s:5:"color";s:4:"blue";}`), &s)
+	expectErrorToEqual(t, err, errors.New(`unknown color "blue"`))
+}
@@ -25,7 +25,13 @@ func findByte(data []byte, lookingFor byte, offset int) int {
 // DecodePHPString converts a string of ASCII bytes (like "Bj\xc3\xb6rk") back
 // into a UTF8 string ("Björk", in that case).
 func DecodePHPString(data []byte) string {
+	// Most strings do not contain anything that needs to be unescaped.
+	if bytes.IndexByte(data, '\\') < 0 {
+		return string(data)
+	}
+
 	var buffer bytes.Buffer
+	buffer.Grow(len(data))
 	for i := 0; i < len(data); i++ {
 		if data[i] == '\\' {
 			if i+1 <= len(data)-1 {
@@ -101,32 +107,175 @@ func checkType(data []byte, typeCharacter byte, offset int) bool {
 }
 
 func UnmarshalIndexedArray(data []byte) ([]interface{}, error) {
-	v, _, err := consumeIndexedArray(data, 0)
+	return newDecoder(DefaultUnmarshalOptions(), false).unmarshalIndexedArray(data)
+}
+
+func UnmarshalAssociativeArray(data []byte) (*orderedmap.OrderedMap[any, any], error) {
+	return newDecoder(DefaultUnmarshalOptions(), false).unmarshalAssociativeArray(data)
+}
+
+func (d *decoder) unmarshalIndexedArray(data []byte) ([]interface{}, error) {
+	d.startValue(data, 0)
+
+	v, _, err := d.consumeIndexedArray(data, 0)
 
 	return v, err
 }
 
-func UnmarshalAssociativeArray(data []byte) (*orderedmap.OrderedMap[any, any], error) {
+func (d *decoder) unmarshalAssociativeArray(data []byte) (*orderedmap.OrderedMap[any, any], error) {
+	d.startValue(data, 0)
+
 	// We may be unmarshalling an object into a map.
 	if checkType(data, 'O', 0) {
-		result, _, err := consumeObjectAsMap(data, 0)
+		result, _, err := d.consumeObjectAsMap(data, 0)
 
 		return result, err
 	}
 
-	result, _, err := consumeAssociativeArray(data, 0)
+	result, _, err := d.consumeAssociativeArray(data, 0)
 
 	return result, err
 }
 
 func UnmarshalObject(data []byte, v reflect.Value) error {
-	_, err := consumeObject(data, 0, v)
+	_, err := consumeObject(data, 0, v, DefaultUnmarshalOptions())
 	return err
 }
 
+// UnmarshalOptions can be provided when invoking UnmarshalWithOptions() or
+// any of the generic decoding functions. Use DefaultUnmarshalOptions() for
+// sensible defaults.
+type UnmarshalOptions struct {
+	// Coercion controls what happens when a PHP value does not have the same
+	// type as the Go value it is decoded into. See CoercionMode. The default
+	// value is CoerceStrict.
+	Coercion CoercionMode
+
+	// If DisallowUnknownFields is true then decoding a PHP object into a
+	// struct will fail if the object has a property that does not match
+	// any of the struct fields. Properties are never unknown if the struct
+	// has a field with the "rest" tag option. The default value is false.
+	DisallowUnknownFields bool
+
+	// FieldNamer is used to find the property for struct fields that do not
+	// have a name in their "php" tag. It should be the same FieldNamer that
+	// was used to encode the data. The default value is nil, which is the same
+	// as LowerFirstNamer.
+	FieldNamer FieldNamer
+
+	// If CaseInsensitive is true then properties are matched to struct
+	// fields without considering case, for example a field named "userId"
+	// will also be decoded from "UserID" or "USERID". A property with an
+	// exact match is always preferred. The default value is false.
+	CaseInsensitive bool
+
+	// If DecodeObjects is true then PHP objects that are decoded into an
+	// interface{} (including the values of an OrderedMap or []interface{})
+	// will be an *Object, so that their class name is kept. Otherwise they
+	// are an *orderedmap.OrderedMap[any, any] of their properties. The
+	// default value is false.
+	DecodeObjects bool
+
+	// If UseNumber is true then integers and floats that are decoded into an
+	// interface{} will be a Number instead of an int64 or float64. Numbers
+	// are always kept as a Number when they are decoded into a Number,
+	// *big.Int, *big.Float or *big.Rat. The default value is false.
+	UseNumber bool
+
+	// If NormalizeKeys is true then the keys of arrays are converted in the
+	// same way as PHP converts array keys. For example, the string key "1"
+	// becomes the integer 1, so that it can not be found under both. The
+	// properties of objects are not changed. The default value is false.
+	NormalizeKeys bool
+
+	// If ZeroCopy is true then strings, and []byte values, share their
+	// memory with the data being decoded instead of being copied from it.
+	// This avoids most allocations, but the data must not be changed while
+	// any of the decoded values are still in use.
+	//
+	// Decoded []byte values must be treated as read-only. They may share
+	// their memory with a decoded string, such as a string field that is
+	// set from the same value through R:, and changing them would change
+	// that string. The default value is false.
+	ZeroCopy bool
+
+	// If KeepReferences is true then a PHP value that is referred to by R:
+	// is decoded into an interface{} as a *Reference, which every R: that
+	// refers to it shares. Otherwise R: is decoded as the value that it
+	// refers to. Objects that are referred to by r: are decoded as the same
+	// *Object (or OrderedMap) either way. The default value is false.
+	//
+	// A reference to an object that contains it, or to any value that
+	// contains it if KeepReferences is true, makes a value that contains
+	// itself. This is only allowed when decoding into an interface{},
+	// *OrderedMap or []interface{}. Marshal returns an error for it,
+	// because PHP would need a reference to encode it.
+	KeepReferences bool
+
+	// resolveClass is set while decoding the fields of a struct that
+	// implements ClassResolver.
+	resolveClass func(className string) interface{}
+}
+
+// DefaultUnmarshalOptions will create a new instance of UnmarshalOptions with
+// sensible defaults. See UnmarshalOptions for a full description of options.
+func DefaultUnmarshalOptions() *UnmarshalOptions {
+	options := new(UnmarshalOptions)
+	options.Coercion = CoerceStrict
+	options.DisallowUnknownFields = false
+	options.FieldNamer = nil
+	options.CaseInsensitive = false
+	options.DecodeObjects = false
+	options.UseNumber = false
+	options.NormalizeKeys = false
+	options.ZeroCopy = false
+	options.KeepReferences = false
+
+	return options
+}
+
+// Unmarshal is the canonical way to perform the equivalent of unserialize() in
+// PHP. The value v must be a pointer. It uses the default UnmarshalOptions.
 func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalWithOptions(data, v, nil)
+}
+
+// UnmarshalWithOptions works the same way as Unmarshal, but allows the
+// decoding to be customised. A nil options is the same as
+// DefaultUnmarshalOptions().
+func UnmarshalWithOptions(data []byte, v interface{}, options *UnmarshalOptions) error {
+	if options == nil {
+		options = DefaultUnmarshalOptions()
+	}
+
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalPHP(NewReader(data, options))
+	}
+
 	value := reflect.ValueOf(v).Elem()
 
+	// Scalar values are read with their own type unless PHP type juggling
+	// has been asked for.
+	if options.Coercion == CoercePHP && isScalarKind(value.Kind()) {
+		v, _, err := newTypedDecoder(options, value.Type()).consumeNext(data, 0)
+		if err != nil {
+			return err
+		}
+
+		return setField(value, v, options)
+	}
+
+	// These types are decoded from a PHP value that does not match their
+	// kind.
+	if isSpecialType(value.Type()) || isTextUnmarshaler(value.Type()) {
+		v, _, err := newTypedDecoder(options, value.Type()).consumeNext(data, 0)
+		if err != nil {
+			return err
+		}
+
+		return setField(value, v, options)
+	}
+
 	switch value.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		v, err := UnmarshalInt(data)
@@ -161,7 +310,7 @@ func Unmarshal(data []byte, v interface{}) error {
 		value.SetBool(v)
 
 	case reflect.String:
-		v, err := UnmarshalString(data)
+		v, _, err := newDecoder(options, false).consumeString(data, 0)
 		if err != nil {
 			return err
 		}
@@ -172,6 +321,16 @@ func Unmarshal(data []byte, v interface{}) error {
 		// uint8 is an alias for byte. This means we are trying to pull
 		// a binary string out.
 		if value.Type().Elem().Kind() == reflect.Uint8 {
+			if options.ZeroCopy {
+				v, _, err := newDecoder(options, false).consumeString(data, 0)
+				if err != nil {
+					return err
+				}
+
+				value.SetBytes(stringToBytes(v))
+				return nil
+			}
+
 			v, err := UnmarshalBytes(data)
 			if err != nil {
 				return err
@@ -181,49 +340,66 @@ func Unmarshal(data []byte, v interface{}) error {
 			return nil
 		}
 
-		// Otherwise this must be a slice (array)
-		v, err := UnmarshalIndexedArray(data)
+		// Otherwise this must be a slice (array). A []interface{} can be
+		// used as is, any other slice type needs each of its elements
+		// converted.
+		isInterfaceSlice := value.Type() == reflect.TypeOf([]interface{}{})
+
+		d := newDecoder(options, false)
+		if !isInterfaceSlice {
+			d = newTypedDecoder(options, value.Type())
+		}
+
+		v, err := d.unmarshalIndexedArray(data)
 		if err != nil {
 			return err
 		}
 
+		if !isInterfaceSlice {
+			return setField(value, v, options)
+		}
+
 		value.Set(reflect.ValueOf(v))
 		return nil
 
 	case reflect.Map:
-		v, err := UnmarshalAssociativeArray(data)
+		v, err := newTypedDecoder(options, value.Type()).unmarshalAssociativeArray(data)
 		if err != nil {
 			return err
 		}
 
-		value.Set(reflect.ValueOf(v))
-		return nil
+		return setField(value, v, options)
 
 	case reflect.Struct:
-		err := UnmarshalObject(data, value)
+		_, err := consumeObject(data, 0, value, options)
 		if err != nil {
 			return err
 		}
 
 		return nil
 	case reflect.Ptr:
-		// If it's a nil pointer, allocate a new value
-		if value.IsNil() {
-			return errors.New("can not unmarshal to nil")
-		} else {
-			if value.Type() == reflect.TypeOf(&orderedmap.OrderedMap[any, any]{}) {
-				v, err := UnmarshalAssociativeArray(data)
-				if err != nil {
-					return err
-				}
-				value.Set(reflect.ValueOf(v))
-			} else {
-				return errors.New("can not unmarshal type: " + value.Elem().Type().Name())
+		if value.Type() == orderedMapType {
+			v, err := newDecoder(options, false).unmarshalAssociativeArray(data)
+			if err != nil {
+				return err
 			}
+			value.Set(reflect.ValueOf(v))
+
+			return nil
+		}
 
+		// A PHP null leaves any other pointer as nil.
+		if checkType(data, 'N', 0) {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
 		}
 
-		return nil
+		// If it's a nil pointer, allocate a new value
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+
+		return UnmarshalWithOptions(data, value.Interface(), options)
 
 	default:
 		return errors.New("can not unmarshal type: " + value.Kind().String())
@@ -232,6 +408,32 @@ func Unmarshal(data []byte, v interface{}) error {
 	return nil
 }
 
+// isSpecialType returns true for the types that are not decoded by their kind.
+// Pointers to these types are also included.
+func isSpecialType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, dateIntervalType, numberType, bigIntType, bigFloatType, bigRatType:
+		return true
+	}
+
+	return false
+}
+
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+		return true
+	}
+
+	return false
+}
+
 func upperCaseFirstLetter(s string) string {
 	return strings.ToUpper(s[0:1]) + s[1:]
 }
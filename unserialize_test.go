@@ -561,6 +561,56 @@ func TestUnmarshalAssociativeArrayMultipleNested(t *testing.T) {
 	}
 }
 
+// nestedMixedArray returns arrays nested depth times, where each one starts
+// like a list but turns out to be associative after the nested array.
+func nestedMixedArray(depth int) string {
+	s := "i:1;"
+	for i := 0; i < depth; i++ {
+		s = `a:2:{i:0;` + s + `s:1:"x";i:1;}`
+	}
+
+	return s
+}
+
+func TestUnmarshalArraySinglePass(t *testing.T) {
+	// This would take far too long if each array was read more than once.
+	var result *orderedmap.OrderedMap[any, any]
+	err := phpserialize.Unmarshal([]byte(nestedMixedArray(64)), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	depth := 0
+	for value := any(result); ; depth++ {
+		m, ok := value.(*orderedmap.OrderedMap[any, any])
+		if !ok {
+			break
+		}
+
+		if !equalKeys(orderedKeys(m), []any{int64(0), "x"}) {
+			t.Fatalf("Unexpected keys %v", orderedKeys(m))
+		}
+
+		value, _ = m.Get(int64(0))
+	}
+
+	if depth != 64 {
+		t.Errorf("Expected a depth of 64, got %d", depth)
+	}
+
+	// A list that becomes associative keeps the values before it.
+	var mixed *orderedmap.OrderedMap[any, any]
+	err = phpserialize.Unmarshal([]byte(`a:1:{s:1:"a";a:3:{i:0;s:1:"a";i:1;s:1:"b";i:5;s:1:"c";}}`), &mixed)
+	expectErrorToNotHaveOccurred(t, err)
+
+	inner, _ := mixed.Get("a")
+	if !equalKeys(orderedKeys(inner.(*orderedmap.OrderedMap[any, any])), []any{int64(0), int64(1), int64(5)}) {
+		t.Errorf("Unexpected keys %v", orderedKeys(inner.(*orderedmap.OrderedMap[any, any])))
+	}
+
+	var list []interface{}
+	err = phpserialize.Unmarshal([]byte(`a:1:{i:0;a:-1:{}}`), &list)
+	expectErrorToEqual(t, err, errors.New("can not decode array with length -1"))
+}
+
 func TestUnmarshalAssociativeArrayNotAnArray(t *testing.T) {
 	input := []byte("N;")
 	result := orderedmap.NewOrderedMap[any, any]()
@@ -860,6 +910,136 @@ func TestUnmarshalObjectThatContainsArray(t *testing.T) {
 	}
 }
 
+func TestUnmarshalEmbeddedStruct(t *testing.T) {
+	data := "O:8:\"embedded\":3:{s:2:\"iD\";i:1;s:4:\"name\";s:5:\"outer\";s:5:\"extra\";b:1;}"
+	var result embedded
+	err := phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := embedded{Base{1, ""}, "outer", true}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestUnmarshalEmbeddedStructPointer(t *testing.T) {
+	data := "O:11:\"embeddedPtr\":3:{s:2:\"iD\";i:1;s:4:\"name\";s:4:\"base\";s:5:\"value\";i:2;}"
+	var result embeddedPtr
+	err := phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := embeddedPtr{&Base{1, "base"}, 2}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestUnmarshalEmbeddedStructPointerNotAllocated(t *testing.T) {
+	data := "O:11:\"embeddedPtr\":1:{s:5:\"value\";i:2;}"
+	var result embeddedPtr
+	err := phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Base != nil {
+		t.Errorf("Expected nil, got %+v", result.Base)
+	}
+}
+
+func TestUnmarshalEmbeddedStructTagged(t *testing.T) {
+	data := "O:14:\"embeddedTagged\":1:{s:4:\"base\";O:4:\"Base\":2:{s:2:\"iD\";i:1;s:4:\"name\";s:4:\"base\";}}"
+	var result embeddedTagged
+	err := phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := embeddedTagged{Base{1, "base"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestUnmarshalEmbeddedStructUnexported(t *testing.T) {
+	data := "O:18:\"embeddedUnexported\":2:{s:2:\"iD\";i:1;s:5:\"value\";i:2;}"
+	var result embeddedUnexported
+	err := phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := embeddedUnexported{base{1}, 2}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestUnmarshalRestField(t *testing.T) {
+	data := "O:10:\"structRest\":3:{s:3:\"zed\";s:1:\"z\";s:3:\"foo\";i:1;s:3:\"bar\";a:1:{i:0;i:2;}}"
+	var result structRest
+	err := phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Foo != 1 {
+		t.Errorf("Expected %v, got %v", 1, result.Foo)
+	}
+
+	if keys := orderedKeys(result.Rest); !equalKeys(keys, []any{"zed", "bar"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	// Encoding it again keeps the unknown properties.
+	encoded, err := phpserialize.Marshal(result, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := "O:10:\"structRest\":3:{s:3:\"foo\";i:1;s:3:\"zed\";s:1:\"z\";s:3:\"bar\";a:1:{i:0;i:2;}}"
+	if string(encoded) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, encoded)
+	}
+
+	// Nested objects are decoded with the options, like any other value.
+	data = "O:10:\"structRest\":1:{s:3:\"obj\";O:3:\"Foo\":1:{s:1:\"a\";i:1;}}"
+	result = structRest{}
+	err = phpserialize.Unmarshal([]byte(data), &result)
+	expectErrorToNotHaveOccurred(t, err)
+
+	obj, _ := result.Rest.Get("obj")
+	if m, ok := obj.(*orderedmap.OrderedMap[any, any]); !ok || m.GetOrDefault("a", nil) != int64(1) {
+		t.Errorf("Expected an ordered map, got %#v", obj)
+	}
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	result = structRest{}
+	err = phpserialize.UnmarshalWithOptions([]byte(data), &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	obj, _ = result.Rest.Get("obj")
+	if o, ok := obj.(*phpserialize.Object); !ok || o.ClassName != "Foo" {
+		t.Errorf("Expected an object, got %#v", obj)
+	}
+}
+
+func TestUnmarshalDisallowUnknownFields(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DisallowUnknownFields = true
+
+	data := "O:7:\"Struct2\":2:{s:3:\"qux\";d:1.5;s:3:\"foo\";i:1;}"
+	var result Struct2
+	err := phpserialize.UnmarshalWithOptions([]byte(data), &result, options)
+
+	expectErrorToEqual(t, err, errors.New("unknown property foo for type phpserialize_test.Struct2"))
+
+	// A nested object is checked too.
+	data = "O:7:\"struct1\":1:{s:3:\"bar\";O:7:\"Struct2\":1:{s:3:\"zzz\";d:1.5;}}"
+	var nested struct1
+	err = phpserialize.UnmarshalWithOptions([]byte(data), &nested, options)
+
+	expectErrorToEqual(t, err, errors.New("unknown property zzz for type phpserialize_test.Struct2"))
+
+	// Unknown properties are allowed if there is somewhere to put them.
+	data = "O:10:\"structRest\":1:{s:3:\"zed\";s:1:\"z\";}"
+	var rest structRest
+	err = phpserialize.UnmarshalWithOptions([]byte(data), &rest, options)
+	expectErrorToNotHaveOccurred(t, err)
+}
+
 var escapeTests = map[string]struct {
 	Unserialized, Serialized string
 }{
@@ -899,3 +1079,80 @@ func TestUnmarshalEscape(t *testing.T) {
 		})
 	}
 }
+
+type aliased struct {
+	UserID int    `php:"user_id,alias=userId|UserID"`
+	Name   string `php:"name"`
+}
+
+func TestUnmarshalAlias(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		output aliased
+	}{
+		"name":         {`O:7:"aliased":1:{s:7:"user_id";i:1;}`, aliased{UserID: 1}},
+		"first alias":  {`O:7:"aliased":1:{s:6:"userId";i:2;}`, aliased{UserID: 2}},
+		"second alias": {`O:7:"aliased":1:{s:6:"UserID";i:3;}`, aliased{UserID: 3}},
+		"name is preferred": {
+			`O:7:"aliased":2:{s:6:"userId";i:2;s:7:"user_id";i:1;}`,
+			aliased{UserID: 1},
+		},
+		"case sensitive": {`O:7:"aliased":1:{s:4:"NAME";s:1:"x";}`, aliased{}},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result aliased
+			err := phpserialize.Unmarshal([]byte(test.input), &result)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if result != test.output {
+				t.Errorf("Expected %+v, got %+v", test.output, result)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCaseInsensitive(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.CaseInsensitive = true
+
+	tests := map[string]struct {
+		input  string
+		output aliased
+	}{
+		"name":  {`O:7:"aliased":1:{s:4:"NAME";s:1:"x";}`, aliased{Name: "x"}},
+		"alias": {`O:7:"aliased":1:{s:6:"USERID";i:4;}`, aliased{UserID: 4}},
+		"exact match is preferred": {
+			`O:7:"aliased":2:{s:4:"Name";s:1:"x";s:4:"name";s:1:"y";}`,
+			aliased{Name: "y"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result aliased
+			err := phpserialize.UnmarshalWithOptions([]byte(test.input), &result, options)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if result != test.output {
+				t.Errorf("Expected %+v, got %+v", test.output, result)
+			}
+		})
+	}
+}
+
+func TestUnmarshalAliasIsNotUnknown(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DisallowUnknownFields = true
+	options.CaseInsensitive = true
+
+	var result aliased
+	err := phpserialize.UnmarshalWithOptions(
+		[]byte(`O:7:"aliased":2:{s:6:"userId";i:2;s:4:"Name";s:1:"x";}`), &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	err = phpserialize.UnmarshalWithOptions(
+		[]byte(`O:7:"aliased":2:{s:6:"userId";i:2;s:7:"user_id";i:1;}`), &result, options)
+	expectErrorToEqual(t, err, errors.New("unknown property userId for type phpserialize_test.aliased"))
+}
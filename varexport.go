@@ -0,0 +1,1126 @@
+package phpserialize
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// ParseVarExport parses a PHP expression in the form written by var_export(),
+// or a PHP array literal, and returns the value as UnmarshalWithOptions would
+// decode it into an interface{}. A nil options is the same as
+// DefaultUnmarshalOptions().
+//
+// The expression can use:
+//
+//   - null, true and false (in any case), and the INF, NAN, PHP_INT_MAX,
+//     PHP_INT_MIN and PHP_EOL constants.
+//   - Integers (including hexadecimal, octal and binary) and floats.
+//   - Single and double quoted strings, with the same escape sequences as PHP.
+//     Variables in double quoted strings are not supported.
+//   - array(...) and [...], with or without keys.
+//   - \Class::__set_state(array(...)) and (object) array(...) for objects.
+//   - The . operator between strings, and + and - between numbers, which
+//     var_export() uses for strings that contain a NUL byte and for
+//     PHP_INT_MIN.
+//
+// Comments are ignored. A whole PHP file that returns the value, such as
+// "<?php return [...];", can also be parsed.
+func ParseVarExport(data []byte, options *UnmarshalOptions) (interface{}, error) {
+	if options == nil {
+		options = DefaultUnmarshalOptions()
+	}
+
+	value, err := parseVarExport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return plainValue(value, options), nil
+}
+
+// UnmarshalVarExport works in the same way as UnmarshalWithOptions, but v is
+// decoded from PHP source that ParseVarExport can parse. A PHP null leaves v
+// unchanged, unless it is a pointer, slice, map or interface, which are set to
+// nil.
+func UnmarshalVarExport(data []byte, v interface{}, options *UnmarshalOptions) error {
+	if options == nil {
+		options = DefaultUnmarshalOptions()
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("can not unmarshal into non-pointer %T", v)
+	}
+
+	value, err := parseVarExport(data)
+	if err != nil {
+		return err
+	}
+
+	rv = rv.Elem()
+	if value == nil {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+
+		return nil
+	}
+
+	return setField(rv, value, options)
+}
+
+// parseVarExport returns the value of a PHP expression. Numbers are always a
+// Number and objects are always an *Object, so that they can be decoded into
+// any type.
+func parseVarExport(data []byte) (interface{}, error) {
+	p := &varExportParser{data: data}
+
+	// A PHP file that returns the value.
+	p.skipSpace()
+	if p.consumeWord("<?php") {
+		p.skipSpace()
+	}
+
+	if p.consumeWord("return") {
+		p.skipSpace()
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.consume(";") {
+		p.skipSpace()
+	}
+
+	if p.consume("?>") {
+		p.skipSpace()
+	}
+
+	if p.offset < len(p.data) {
+		return nil, p.unexpected()
+	}
+
+	return value, nil
+}
+
+type varExportParser struct {
+	data   []byte
+	offset int
+}
+
+// errorf returns an error that includes the line and column of the offset.
+func (p *varExportParser) errorf(offset int, format string, args ...interface{}) error {
+	line := bytes.Count(p.data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(p.data[:offset], '\n')
+
+	return fmt.Errorf("can not parse PHP at line %d, column %d: %s", line, column,
+		fmt.Sprintf(format, args...))
+}
+
+func (p *varExportParser) unexpected() error {
+	if p.offset >= len(p.data) {
+		return p.errorf(p.offset, "unexpected end of data")
+	}
+
+	r, _ := utf8.DecodeRune(p.data[p.offset:])
+
+	return p.errorf(p.offset, "unexpected %q", r)
+}
+
+// skipSpace moves past any whitespace and comments.
+func (p *varExportParser) skipSpace() {
+	for p.offset < len(p.data) {
+		switch c := p.data[p.offset]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			p.offset++
+
+		case c == '#' || bytes.HasPrefix(p.data[p.offset:], []byte("//")):
+			end := bytes.IndexByte(p.data[p.offset:], '\n')
+			if end < 0 {
+				p.offset = len(p.data)
+			} else {
+				p.offset += end + 1
+			}
+
+		case bytes.HasPrefix(p.data[p.offset:], []byte("/*")):
+			end := bytes.Index(p.data[p.offset+2:], []byte("*/"))
+			if end < 0 {
+				p.offset = len(p.data)
+			} else {
+				p.offset += end + 4
+			}
+
+		default:
+			return
+		}
+	}
+}
+
+func (p *varExportParser) peek() byte {
+	if p.offset >= len(p.data) {
+		return 0
+	}
+
+	return p.data[p.offset]
+}
+
+// consume moves past s if the data continues with it.
+func (p *varExportParser) consume(s string) bool {
+	if !bytes.HasPrefix(p.data[p.offset:], []byte(s)) {
+		return false
+	}
+
+	p.offset += len(s)
+
+	return true
+}
+
+// consumeWord moves past a keyword, in any case, if it is not followed by
+// more of a name.
+func (p *varExportParser) consumeWord(word string) bool {
+	end := p.offset + len(word)
+	if end > len(p.data) || !strings.EqualFold(string(p.data[p.offset:end]), word) {
+		return false
+	}
+
+	if end < len(p.data) && isNameByte(p.data[end]) {
+		return false
+	}
+
+	p.offset = end
+
+	return true
+}
+
+func (p *varExportParser) expect(s string) error {
+	p.skipSpace()
+	if !p.consume(s) {
+		if p.offset >= len(p.data) {
+			return p.unexpected()
+		}
+
+		return p.errorf(p.offset, "expected %q", s)
+	}
+
+	return nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// parseValue parses an expression, which is a value that may be joined to
+// others with the . operator.
+func (p *varExportParser) parseValue() (interface{}, error) {
+	value, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		start := p.offset
+		if !p.consume(".") {
+			return value, nil
+		}
+
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		left, ok := coerceString(value, CoercePHP)
+		if !ok {
+			return nil, p.errorf(start, "can not join PHP %s", phpTypeName(value))
+		}
+
+		s, ok := coerceString(right, CoercePHP)
+		if !ok {
+			return nil, p.errorf(start, "can not join PHP %s", phpTypeName(right))
+		}
+
+		value = left + s
+	}
+}
+
+// parseSum parses numbers that are added or subtracted.
+func (p *varExportParser) parseSum() (interface{}, error) {
+	value, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		start := p.offset
+
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return value, nil
+		}
+
+		p.offset++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err = addNumbers(value, right, op == '-')
+		if err != nil {
+			return nil, p.errorf(start, "%v", err)
+		}
+	}
+}
+
+// addNumbers adds or subtracts two numbers in the same way as PHP. The result
+// is a float if either number is a float, or if the result does not fit into
+// an int64.
+func addNumbers(a, b interface{}, subtract bool) (interface{}, error) {
+	x, ok := a.(Number)
+	if !ok {
+		return nil, fmt.Errorf("can not add PHP %s", phpTypeName(a))
+	}
+
+	y, ok := b.(Number)
+	if !ok {
+		return nil, fmt.Errorf("can not add PHP %s", phpTypeName(b))
+	}
+
+	if i, ok := x.value().(int64); ok {
+		if j, ok := y.value().(int64); ok {
+			if subtract {
+				j = -j
+			}
+
+			sum := i + j
+			if (j >= 0) == (sum >= i) && !(subtract && j == math.MinInt64) {
+				return Number(strconv.FormatInt(sum, 10)), nil
+			}
+		}
+	}
+
+	f, _ := x.Float64()
+	g, _ := y.Float64()
+	if subtract {
+		g = -g
+	}
+
+	return floatNumber(f + g), nil
+}
+
+// floatNumber returns a Number that is always a float.
+func floatNumber(f float64) Number {
+	s := strconv.FormatFloat(f, 'E', -1, 64)
+	switch {
+	case math.IsInf(f, 1):
+		s = "INF"
+	case math.IsInf(f, -1):
+		s = "-INF"
+	case math.IsNaN(f):
+		s = "NAN"
+	}
+
+	return Number(s)
+}
+
+// parseUnary parses a single value, including a sign or a cast.
+func (p *varExportParser) parseUnary() (interface{}, error) {
+	p.skipSpace()
+	start := p.offset
+
+	switch c := p.peek(); {
+	case c == '-' || c == '+':
+		p.offset++
+		value, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		n, ok := value.(Number)
+		if !ok {
+			return nil, p.errorf(start, "can not use %c with PHP %s", c, phpTypeName(value))
+		}
+
+		if c == '+' {
+			return n, nil
+		}
+
+		return negateNumber(n), nil
+
+	case c == '(':
+		p.offset++
+		p.skipSpace()
+		if p.consumeWord("object") {
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+
+			value, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+
+			return castToObject(value), nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		return value, p.expect(")")
+
+	case c == '[':
+		p.offset++
+		return p.parseArray(']')
+
+	case c == '\'':
+		return p.parseSingleQuoted()
+
+	case c == '"':
+		return p.parseDoubleQuoted()
+
+	case c >= '0' && c <= '9' || c == '.':
+		return p.parseNumber()
+
+	case c == '\\' || isNameByte(c):
+		return p.parseName()
+	}
+
+	return nil, p.unexpected()
+}
+
+func negateNumber(n Number) Number {
+	if n.isInt() {
+		if i, err := n.Int64(); err == nil {
+			if i == math.MinInt64 {
+				return floatNumber(-float64(i))
+			}
+
+			return Number(strconv.FormatInt(-i, 10))
+		}
+	}
+
+	if s, ok := strings.CutPrefix(string(n), "-"); ok {
+		return Number(s)
+	}
+
+	return "-" + n
+}
+
+// castToObject converts a value in the same way as (object) in PHP.
+func castToObject(value interface{}) *Object {
+	switch v := value.(type) {
+	case *Object:
+		return v
+
+	case nil:
+		return NewObject("stdClass")
+
+	case []interface{}, *orderedmap.OrderedMap[any, any]:
+		return &Object{ClassName: "stdClass", Properties: arrayProperties(v)}
+	}
+
+	o := NewObject("stdClass")
+	o.Properties.Set("scalar", value)
+
+	return o
+}
+
+// arrayProperties returns the elements of a parsed array as an OrderedMap.
+func arrayProperties(array interface{}) *orderedmap.OrderedMap[any, any] {
+	if m, ok := array.(*orderedmap.OrderedMap[any, any]); ok {
+		return m
+	}
+
+	m := orderedmap.NewOrderedMap[any, any]()
+	for i, value := range array.([]interface{}) {
+		m.Set(int64(i), value)
+	}
+
+	return m
+}
+
+// parseName parses a constant, array(...) or \Class::__set_state(...).
+func (p *varExportParser) parseName() (interface{}, error) {
+	start := p.offset
+
+	for p.offset < len(p.data) && (p.data[p.offset] == '\\' || isNameByte(p.data[p.offset])) {
+		p.offset++
+	}
+
+	name := string(p.data[start:p.offset])
+	p.skipSpace()
+
+	if p.consume("::") {
+		p.skipSpace()
+		methodStart := p.offset
+		if !p.consumeWord("__set_state") {
+			for p.offset < len(p.data) && isNameByte(p.data[p.offset]) {
+				p.offset++
+			}
+
+			return nil, p.errorf(methodStart, "unsupported %s::%s", name, p.data[methodStart:p.offset])
+		}
+
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		propertiesStart := p.offset
+		properties, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		switch properties.(type) {
+		case []interface{}, *orderedmap.OrderedMap[any, any]:
+		default:
+			return nil, p.errorf(propertiesStart, "expected an array of properties")
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return &Object{
+			ClassName:  strings.TrimPrefix(name, "\\"),
+			Properties: arrayProperties(properties),
+		}, nil
+	}
+
+	if strings.EqualFold(name, "array") && p.consume("(") {
+		return p.parseArray(')')
+	}
+
+	switch strings.ToUpper(strings.TrimPrefix(name, "\\")) {
+	case "NULL":
+		return nil, nil
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	case "INF":
+		return Number("INF"), nil
+	case "NAN":
+		return Number("NAN"), nil
+	case "PHP_INT_MAX":
+		return Number(strconv.FormatInt(math.MaxInt64, 10)), nil
+	case "PHP_INT_MIN":
+		return Number(strconv.FormatInt(math.MinInt64, 10)), nil
+	case "PHP_EOL":
+		return "\n", nil
+	}
+
+	return nil, p.errorf(start, "unknown constant %s", name)
+}
+
+// parseArray parses the elements of an array up to the end byte. Arrays with
+// the keys 0, 1, 2 and so on are a []interface{}, as they are when they are
+// decoded.
+func (p *varExportParser) parseArray(end byte) (interface{}, error) {
+	list := []interface{}{}
+	var assoc *orderedmap.OrderedMap[any, any]
+
+	// next is the key of an element without a key. It is one more than the
+	// largest integer key so far.
+	var next int64
+	hasNext := false
+
+	for {
+		p.skipSpace()
+		if p.peek() == end {
+			p.offset++
+			break
+		}
+
+		keyStart := p.offset
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		var key interface{}
+
+		p.skipSpace()
+		if p.consume("=>") {
+			if key, err = normalizeKey(value); err != nil {
+				return nil, p.errorf(keyStart, "%v", err)
+			}
+
+			if value, err = p.parseValue(); err != nil {
+				return nil, err
+			}
+		} else {
+			if hasNext && next == math.MinInt64 {
+				return nil, p.errorf(keyStart, "can not add an element after the key %d", int64(math.MaxInt64))
+			}
+
+			key = next
+		}
+
+		if i, ok := key.(int64); ok && (!hasNext || i >= next) {
+			next, hasNext = i+1, true
+			if i == math.MaxInt64 {
+				// The next element without a key can not be added.
+				next = math.MinInt64
+			}
+		}
+
+		i, isInt := key.(int64)
+		switch {
+		case assoc == nil && isInt && i >= 0 && i < int64(len(list)):
+			list[i] = value
+
+		case assoc == nil && isInt && i == int64(len(list)):
+			list = append(list, value)
+
+		default:
+			if assoc == nil {
+				assoc = arrayProperties(list)
+			}
+
+			assoc.Set(key, value)
+		}
+
+		p.skipSpace()
+		if !p.consume(",") && p.peek() != end {
+			if p.offset >= len(p.data) {
+				return nil, p.unexpected()
+			}
+
+			return nil, p.errorf(p.offset, "expected \",\" or %q", end)
+		}
+	}
+
+	if assoc != nil {
+		return assoc, nil
+	}
+
+	return list, nil
+}
+
+func (p *varExportParser) parseSingleQuoted() (string, error) {
+	start := p.offset
+	p.offset++
+
+	var buf []byte
+	for p.offset < len(p.data) {
+		c := p.data[p.offset]
+		p.offset++
+
+		switch {
+		case c == '\'':
+			return string(buf), nil
+
+		case c == '\\' && p.offset < len(p.data) && (p.data[p.offset] == '\\' || p.data[p.offset] == '\''):
+			buf = append(buf, p.data[p.offset])
+			p.offset++
+
+		default:
+			buf = append(buf, c)
+		}
+	}
+
+	return "", p.errorf(start, "unterminated string")
+}
+
+func (p *varExportParser) parseDoubleQuoted() (string, error) {
+	start := p.offset
+	p.offset++
+
+	var buf []byte
+	for p.offset < len(p.data) {
+		c := p.data[p.offset]
+		p.offset++
+
+		switch c {
+		case '"':
+			return string(buf), nil
+
+		case '$':
+			if p.offset < len(p.data) && (p.data[p.offset] == '{' || isNameByte(p.data[p.offset]) &&
+				!(p.data[p.offset] >= '0' && p.data[p.offset] <= '9')) {
+				return "", p.errorf(p.offset-1, "variables in strings are not supported")
+			}
+
+			buf = append(buf, c)
+
+		case '{':
+			if p.peek() == '$' {
+				return "", p.errorf(p.offset-1, "variables in strings are not supported")
+			}
+
+			buf = append(buf, c)
+
+		case '\\':
+			buf = p.appendEscape(buf)
+
+		default:
+			buf = append(buf, c)
+		}
+	}
+
+	return "", p.errorf(start, "unterminated string")
+}
+
+// appendEscape appends the character for the escape sequence after a
+// backslash in a double quoted string. Unknown escape sequences are kept as
+// they are.
+func (p *varExportParser) appendEscape(buf []byte) []byte {
+	if p.offset >= len(p.data) {
+		return append(buf, '\\')
+	}
+
+	c := p.data[p.offset]
+	p.offset++
+
+	switch c {
+	case 'n':
+		return append(buf, '\n')
+	case 't':
+		return append(buf, '\t')
+	case 'r':
+		return append(buf, '\r')
+	case 'v':
+		return append(buf, '\v')
+	case 'e':
+		return append(buf, 0x1b)
+	case 'f':
+		return append(buf, '\f')
+	case '\\', '$', '"':
+		return append(buf, c)
+
+	case 'x':
+		end := p.offset
+		for end < len(p.data) && end < p.offset+2 && isHexDigit(p.data[end]) {
+			end++
+		}
+
+		if end == p.offset {
+			return append(buf, '\\', 'x')
+		}
+
+		b, _ := strconv.ParseUint(string(p.data[p.offset:end]), 16, 8)
+		p.offset = end
+
+		return append(buf, byte(b))
+
+	case 'u':
+		if p.peek() != '{' {
+			return append(buf, '\\', 'u')
+		}
+
+		end := bytes.IndexByte(p.data[p.offset:], '}')
+		if end < 0 {
+			return append(buf, '\\', 'u')
+		}
+
+		r, err := strconv.ParseUint(string(p.data[p.offset+1:p.offset+end]), 16, 32)
+		if err != nil || r > utf8.MaxRune {
+			return append(buf, '\\', 'u')
+		}
+
+		p.offset += end + 1
+
+		return utf8.AppendRune(buf, rune(r))
+	}
+
+	if c >= '0' && c <= '7' {
+		end := p.offset - 1
+		for end < len(p.data) && end < p.offset+2 && p.data[end] >= '0' && p.data[end] <= '7' {
+			end++
+		}
+
+		b, _ := strconv.ParseUint(string(p.data[p.offset-1:end]), 8, 16)
+		p.offset = end
+
+		// PHP keeps the lowest byte of "\777".
+		return append(buf, byte(b))
+	}
+
+	return append(buf, '\\', c)
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// parseNumber parses an integer or float literal. The Number has the text of
+// the literal, except that integers in other bases are converted to decimal
+// and underscores are removed.
+func (p *varExportParser) parseNumber() (interface{}, error) {
+	start := p.offset
+	for p.offset < len(p.data) {
+		c := p.data[p.offset]
+		switch {
+		case isNameByte(c) || c == '.':
+			p.offset++
+
+		case (c == '+' || c == '-') && (p.data[p.offset-1] == 'e' || p.data[p.offset-1] == 'E') &&
+			!isPrefixedNumber(p.data[start:p.offset]):
+			p.offset++
+
+		default:
+			return p.number(start)
+		}
+	}
+
+	return p.number(start)
+}
+
+// isPrefixedNumber returns true for the start of a hexadecimal, binary or octal
+// literal with a prefix, such as "0x1e".
+func isPrefixedNumber(s []byte) bool {
+	if len(s) < 2 || s[0] != '0' {
+		return false
+	}
+
+	switch s[1] {
+	case 'x', 'X', 'b', 'B', 'o', 'O':
+		return true
+	}
+
+	return false
+}
+
+func (p *varExportParser) number(start int) (interface{}, error) {
+	literal := string(p.data[start:p.offset])
+	text := strings.ReplaceAll(literal, "_", "")
+
+	invalid := func() error {
+		return p.errorf(start, "invalid number %s", literal)
+	}
+
+	if strings.Contains(literal, "__") || strings.HasSuffix(literal, "_") {
+		return nil, invalid()
+	}
+
+	base := 10
+	digits := text
+	switch {
+	case isPrefixedNumber([]byte(text)):
+		base = map[byte]int{'x': 16, 'b': 2, 'o': 8}[text[1]|0x20]
+		digits = text[2:]
+
+	case len(text) > 1 && text[0] == '0' && !strings.ContainsAny(text, ".eE"):
+		base = 8
+		digits = text[1:]
+	}
+
+	if base != 10 {
+		i, ok := new(big.Int).SetString(digits, base)
+		if !ok || digits == "" {
+			return nil, invalid()
+		}
+
+		if i.IsInt64() {
+			return Number(i.String()), nil
+		}
+
+		f, _ := new(big.Float).SetInt(i).Float64()
+
+		return floatNumber(f), nil
+	}
+
+	if n := Number(text); n.isInt() {
+		return n, nil
+	}
+
+	if _, err := strconv.ParseFloat(text, 64); err != nil && !isRangeError(err) {
+		return nil, invalid()
+	}
+
+	return Number(text), nil
+}
+
+// VarExportOptions can be provided when invoking MarshalVarExport(). Use
+// DefaultVarExportOptions() for sensible defaults.
+type VarExportOptions struct {
+	// MarshalOptions are used to encode Go values (such as structs) in the
+	// same way as Marshal. The default value is nil, which is the same as
+	// DefaultMarshalOptions().
+	MarshalOptions *MarshalOptions
+
+	// If ShortArraySyntax is true then arrays are written as [...] instead
+	// of array(...). The default value is false.
+	ShortArraySyntax bool
+
+	// Indent is written once for each level of nesting before each element.
+	// If it is empty the layout is exactly the same as var_export(), which
+	// indents by two spaces (and object properties by three) and starts
+	// nested arrays on the line after their key. The default value is "".
+	Indent string
+
+	// If PHPFile is true then the output is a PHP file that returns the
+	// value, so that it can be loaded with include. The default value is
+	// false.
+	PHPFile bool
+}
+
+// DefaultVarExportOptions will create a new instance of VarExportOptions with
+// sensible defaults. See VarExportOptions for a full description of options.
+func DefaultVarExportOptions() *VarExportOptions {
+	options := new(VarExportOptions)
+	options.MarshalOptions = nil
+	options.ShortArraySyntax = false
+	options.Indent = ""
+	options.PHPFile = false
+
+	return options
+}
+
+// MarshalVarExport returns PHP source code for a value, in the same way as
+// var_export() in PHP. It accepts the same values as Marshal, and the result
+// can be read back with ParseVarExport.
+//
+// Objects are written as \Class::__set_state(array(...)), except for stdClass
+// (and structs without a name), which are written as (object) array(...).
+// References are written as the value that they refer to. An error is
+// returned if an array or object contains itself, or if a class name is not a
+// valid PHP name.
+func MarshalVarExport(input interface{}, options *VarExportOptions) ([]byte, error) {
+	if options == nil {
+		options = DefaultVarExportOptions()
+	}
+
+	value, err := newValueTree(options.MarshalOptions).convert(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var dst []byte
+	if options.PHPFile {
+		dst = append(dst, "<?php\n\nreturn "...)
+	}
+
+	e := &varExporter{options: options, visiting: map[interface{}]bool{}}
+	dst, err = e.appendValue(dst, value, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.PHPFile {
+		dst = append(dst, ";\n"...)
+	}
+
+	return dst, nil
+}
+
+// varExportKeyReplacer escapes array keys. Unlike values, var_export() does
+// not join NUL bytes to them.
+var varExportKeyReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+type varExporter struct {
+	options *VarExportOptions
+
+	// visiting holds the arrays and objects that are being written.
+	visiting map[interface{}]bool
+}
+
+// appendValue appends a value that has been converted by valueTree. The level
+// starts at 1 and increases by 2 for each level of nesting, in the same way
+// as var_export().
+func (e *varExporter) appendValue(dst []byte, value interface{}, level int) ([]byte, error) {
+	if ref, ok := value.(*Reference); ok {
+		value = ref.Value
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return append(dst, "NULL"...), nil
+
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+
+	case int64:
+		return appendVarExportInt(dst, v), nil
+
+	case float64:
+		start := len(dst)
+		dst = appendPHPFloat(dst, v, -1)
+
+		// Floats always look like floats, so that they are read back as one.
+		if !math.IsInf(v, 0) && !math.IsNaN(v) && !bytes.ContainsAny(dst[start:], ".E") {
+			dst = append(dst, ".0"...)
+		}
+
+		return dst, nil
+
+	case string:
+		return appendVarExportString(dst, v), nil
+
+	case []interface{}, *orderedmap.OrderedMap[any, any], *Object:
+		return e.appendContainer(dst, value, level)
+	}
+
+	return nil, fmt.Errorf("can not export %T", value)
+}
+
+// appendVarExportInt appends an integer. The smallest integer is written as
+// an expression because PHP would read the literal as a float.
+func appendVarExportInt(dst []byte, i int64) []byte {
+	if i == math.MinInt64 {
+		dst = strconv.AppendInt(dst, i+1, 10)
+		return append(dst, "-1"...)
+	}
+
+	return strconv.AppendInt(dst, i, 10)
+}
+
+// appendVarExportString appends a single quoted string. NUL bytes are joined
+// to it as "\0", in the same way as var_export().
+func appendVarExportString(dst []byte, s string) []byte {
+	dst = append(dst, '\'')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '\\':
+			dst = append(dst, '\\', s[i])
+		case 0:
+			dst = append(dst, `' . "\0" . '`...)
+		default:
+			dst = append(dst, s[i])
+		}
+	}
+
+	return append(dst, '\'')
+}
+
+func (e *varExporter) appendContainer(dst []byte, value interface{}, level int) ([]byte, error) {
+	if key := containerKey(value); key != nil {
+		if e.visiting[key] {
+			return nil, fmt.Errorf("can not export PHP %s that contains itself", phpTypeName(value))
+		}
+
+		e.visiting[key] = true
+		defer delete(e.visiting, key)
+	}
+
+	varExport := e.options.Indent == ""
+	o, isObject := value.(*Object)
+
+	// var_export() writes "array (" for arrays, but "array(" inside an
+	// object.
+	open, end := "array(", ")"
+	if e.options.ShortArraySyntax {
+		open, end = "[", "]"
+	} else if varExport && !isObject {
+		open = "array ("
+	}
+
+	if isObject {
+		if o.ClassName == "" || o.ClassName == "stdClass" {
+			open = "(object) " + open
+		} else {
+			// The name is written into PHP source code, so anything else
+			// could change what the code does when it is included.
+			if !isClassName(o.ClassName) {
+				return nil, fmt.Errorf("can not export invalid PHP class name %q", o.ClassName)
+			}
+
+			open = `\` + o.ClassName + "::__set_state(" + open
+			end += ")"
+		}
+	}
+
+	entries := dumpEntries(value)
+
+	if varExport {
+		if level > 1 {
+			dst = append(dst, '\n')
+			dst = appendSpaces(dst, level-1)
+		}
+	} else if len(entries) == 0 {
+		dst = append(dst, open...)
+		return append(dst, end...), nil
+	}
+
+	dst = append(dst, open...)
+	dst = append(dst, '\n')
+
+	for _, entry := range entries {
+		switch {
+		case !varExport:
+			dst = appendRepeated(dst, e.options.Indent, (level+1)/2)
+		case isObject:
+			dst = appendSpaces(dst, level+2)
+		default:
+			dst = appendSpaces(dst, level+1)
+		}
+
+		switch key := dumpKey(entry.key).(type) {
+		case int64:
+			dst = appendVarExportInt(dst, key)
+
+		case string:
+			// Private and protected properties are written with only
+			// their name.
+			if isObject {
+				key, _, _ = unmangleProperty(key)
+			}
+
+			dst = append(dst, '\'')
+			dst = append(dst, varExportKeyReplacer.Replace(key)...)
+			dst = append(dst, '\'')
+		}
+
+		dst = append(dst, " => "...)
+
+		var err error
+		dst, err = e.appendValue(dst, entry.value, level+2)
+		if err != nil {
+			return nil, err
+		}
+
+		dst = append(dst, ",\n"...)
+	}
+
+	if varExport {
+		if level > 1 {
+			dst = appendSpaces(dst, level-1)
+		}
+	} else {
+		dst = appendRepeated(dst, e.options.Indent, (level-1)/2)
+	}
+
+	return append(dst, end...), nil
+}
+
+// isClassName reports whether name is a qualified PHP class name, which is one
+// or more labels separated by backslashes.
+func isClassName(name string) bool {
+	for _, label := range strings.Split(name, `\`) {
+		if label == "" || label[0] >= '0' && label[0] <= '9' {
+			return false
+		}
+
+		for i := 0; i < len(label); i++ {
+			if !isNameByte(label[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func appendRepeated(dst []byte, s string, n int) []byte {
+	for ; n > 0; n-- {
+		dst = append(dst, s...)
+	}
+
+	return dst
+}
@@ -0,0 +1,454 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+// varExportData is the output of var_export() for an array with nested
+// arrays, objects and a string that contains a NUL byte.
+const varExportData = `array (
+  'name' => 'it\'s a \\ test',
+  'count' => 3,
+  'ratio' => 0.5,
+  'tags' =>
+  array (
+    0 => 'a',
+    1 => 'b',
+  ),
+  'owner' =>
+  \App\User::__set_state(array(
+     'id' => 7,
+     'email' => NULL,
+  )),
+  'extra' =>
+  (object) array(
+     'enabled' => true,
+  ),
+  'nul' => '' . "\0" . 'x',
+  'min' => -9223372036854775807-1,
+)`
+
+func TestParseVarExport(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	value, err := phpserialize.ParseVarExport([]byte(varExportData), options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	var buf strings.Builder
+	expectErrorToNotHaveOccurred(t, phpserialize.VarDump(&buf, value))
+
+	expected := "array(8) {\n" +
+		"  [\"name\"]=>\n  string(13) \"it's a \\ test\"\n" +
+		"  [\"count\"]=>\n  int(3)\n" +
+		"  [\"ratio\"]=>\n  float(0.5)\n" +
+		"  [\"tags\"]=>\n  array(2) {\n    [0]=>\n    string(1) \"a\"\n    [1]=>\n    string(1) \"b\"\n  }\n" +
+		"  [\"owner\"]=>\n  object(App\\User)#1 (2) {\n    [\"id\"]=>\n    int(7)\n    [\"email\"]=>\n    NULL\n  }\n" +
+		"  [\"extra\"]=>\n  object(stdClass)#2 (1) {\n    [\"enabled\"]=>\n    bool(true)\n  }\n" +
+		"  [\"nul\"]=>\n  string(2) \"\x00x\"\n" +
+		"  [\"min\"]=>\n  int(-9223372036854775808)\n" +
+		"}\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestParseVarExportOptions(t *testing.T) {
+	data := []byte(`[1.50, \Foo::__set_state(['a' => 1])]`)
+
+	value, err := phpserialize.ParseVarExport(data, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := orderedmap.NewOrderedMap[any, any]()
+	expected.Set("a", int64(1))
+	if !reflect.DeepEqual(value, []interface{}{1.5, expected}) {
+		t.Errorf("Unexpected result %#v", value)
+	}
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.UseNumber = true
+	options.DecodeObjects = true
+
+	value, err = phpserialize.ParseVarExport(data, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	o := phpserialize.NewObject("Foo")
+	o.Properties.Set("a", phpserialize.Number("1"))
+	if !reflect.DeepEqual(value, []interface{}{phpserialize.Number("1.50"), o}) {
+		t.Errorf("Unexpected result %#v", value)
+	}
+}
+
+func TestParseVarExportValues(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected interface{}
+	}{
+		"null":        {`NULL`, nil},
+		"lower null":  {`null`, nil},
+		"true":        {`TRUE`, true},
+		"false":       {`false`, false},
+		"int":         {`-42`, int64(-42)},
+		"hex":         {`0x1F`, int64(31)},
+		"octal":       {`017`, int64(15)},
+		"octal 0o":    {`0o17`, int64(15)},
+		"binary":      {`0b101`, int64(5)},
+		"underscores": {`1_000_000`, int64(1000000)},
+		"overflow":    {`9223372036854775808`, 9223372036854775808.0},
+		"int max":     {`PHP_INT_MAX`, int64(math.MaxInt64)},
+		"float":       {`1.0E+25`, 1e25},
+		"short float": {`.5`, 0.5},
+		"inf":         {`-INF`, math.Inf(-1)},
+		"sum":         {`1 + 2 - 4`, int64(-1)},
+		"parentheses": {`(1)`, int64(1)},
+		"single":      {`'a\'b\\c\d'`, `a'b\c\d`},
+		"double": {
+			`"\n\t\\\$\"\x41\101\u{e9}\q$1 {"`,
+			"\n\t\\$\"AAé\\q$1 {",
+		},
+		"join":     {`'a' . "b" . 1`, "ab1"},
+		"eol":      {`PHP_EOL`, "\n"},
+		"comments": {"/* a */ 1 # b\n// c", int64(1)},
+		"file":     {"<?php\n\nreturn 'x';\n", "x"},
+		"list":     {`[1, 'a', ]`, []interface{}{int64(1), "a"}},
+		"empty":    {`array()`, []interface{}{}},
+		"keyed list": {
+			`array(0 => 'a', '1' => 'b', 1 => 'c')`,
+			[]interface{}{"a", "c"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			value, err := phpserialize.ParseVarExport([]byte(test.input), nil)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if !reflect.DeepEqual(value, test.expected) {
+				t.Errorf("Expected %#v, got %#v", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestParseVarExportKeys(t *testing.T) {
+	value, err := phpserialize.ParseVarExport([]byte(`[
+		'a',
+		5 => 'b',
+		'c',
+		'07' => 'd',
+		true => 'e',
+		1.7 => 'f',
+		null => 'g',
+		-10 => 'h',
+		'i',
+		'a' => 'j',
+		0 => 'k',
+	]`), nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := orderedmap.NewOrderedMap[any, any]()
+	expected.Set(int64(0), "k")
+	expected.Set(int64(5), "b")
+	expected.Set(int64(6), "c")
+	expected.Set("07", "d")
+	expected.Set(int64(1), "f")
+	expected.Set("", "g")
+	expected.Set(int64(-10), "h")
+	expected.Set(int64(7), "i")
+	expected.Set("a", "j")
+
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, value)
+	}
+}
+
+func TestUnmarshalVarExport(t *testing.T) {
+	type User struct {
+		ID    int     `php:"id"`
+		Email *string `php:"email"`
+	}
+
+	type config struct {
+		Name  string            `php:"name"`
+		Count int               `php:"count"`
+		Ratio float64           `php:"ratio"`
+		Tags  []string          `php:"tags"`
+		Owner User              `php:"owner"`
+		Extra map[string]bool   `php:"extra"`
+		Nul   []byte            `php:"nul"`
+		Min   int64             `php:"min"`
+		Rest  map[string]string `php:",rest"`
+	}
+
+	var result config
+	err := phpserialize.UnmarshalVarExport([]byte(varExportData), &result, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	expected := config{
+		Name:  `it's a \ test`,
+		Count: 3,
+		Ratio: 0.5,
+		Tags:  []string{"a", "b"},
+		Owner: User{ID: 7},
+		Extra: map[string]bool{"enabled": true},
+		Nul:   []byte("\x00x"),
+		Min:   math.MinInt64,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	p := &result
+	expectErrorToNotHaveOccurred(t, phpserialize.UnmarshalVarExport([]byte(`NULL`), &p, nil))
+	if p != nil {
+		t.Errorf("Expected nil, got %+v", p)
+	}
+
+	err = phpserialize.UnmarshalVarExport([]byte(`'x'`), &result, nil)
+	expectErrorToEqual(t, err, errors.New("can not unmarshal PHP string into type phpserialize_test.config"))
+
+	err = phpserialize.UnmarshalVarExport([]byte(`1`), result, nil)
+	expectErrorToEqual(t, err, errors.New("can not unmarshal into non-pointer phpserialize_test.config"))
+}
+
+func TestParseVarExportErrors(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedError error
+	}{
+		"empty": {
+			``,
+			errors.New("can not parse PHP at line 1, column 1: unexpected end of data"),
+		},
+		"missing comma": {
+			"[\n  1\n  2\n]",
+			errors.New(`can not parse PHP at line 3, column 3: expected "," or ']'`),
+		},
+		"unterminated array": {
+			`array(1,`,
+			errors.New("can not parse PHP at line 1, column 9: unexpected end of data"),
+		},
+		"unterminated string": {
+			`['abc]`,
+			errors.New("can not parse PHP at line 1, column 2: unterminated string"),
+		},
+		"variable": {
+			`"a $b"`,
+			errors.New("can not parse PHP at line 1, column 4: variables in strings are not supported"),
+		},
+		"constant": {
+			`FOO`,
+			errors.New("can not parse PHP at line 1, column 1: unknown constant FOO"),
+		},
+		"enum": {
+			`\Suit::Hearts`,
+			errors.New(`can not parse PHP at line 1, column 8: unsupported \Suit::Hearts`),
+		},
+		"set_state": {
+			`Foo::__set_state(1)`,
+			errors.New("can not parse PHP at line 1, column 18: expected an array of properties"),
+		},
+		"array key": {
+			`[[] => 1]`,
+			errors.New("can not parse PHP at line 1, column 2: can not use PHP array as an array key"),
+		},
+		"number": {
+			`1__0`,
+			errors.New("can not parse PHP at line 1, column 1: invalid number 1__0"),
+		},
+		"sign": {
+			`-'a'`,
+			errors.New("can not parse PHP at line 1, column 1: can not use - with PHP string"),
+		},
+		"trailing": {
+			`1; 2`,
+			errors.New("can not parse PHP at line 1, column 4: unexpected '2'"),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := phpserialize.ParseVarExport([]byte(test.input), nil)
+			expectErrorToEqual(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestMarshalVarExport(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.DecodeObjects = true
+
+	value, err := phpserialize.ParseVarExport([]byte(varExportData), options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	result, err := phpserialize.MarshalVarExport(value, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	// var_export() leaves a space after the key of a nested array.
+	expected := strings.ReplaceAll(varExportData, "=>\n", "=> \n")
+	if string(result) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestMarshalVarExportLayout(t *testing.T) {
+	type Address struct {
+		City string
+	}
+
+	type Person struct {
+		Name    string
+		Tags    []string
+		Address *Address
+		Score   float64
+	}
+
+	value := Person{Name: "Bob", Tags: []string{}, Address: &Address{City: "Oslo"}, Score: 2}
+
+	tests := map[string]struct {
+		options  *phpserialize.VarExportOptions
+		expected string
+	}{
+		"var_export": {
+			nil,
+			"\\Person::__set_state(array(\n" +
+				"   'name' => 'Bob',\n" +
+				"   'tags' => \n" +
+				"  array (\n" +
+				"  ),\n" +
+				"   'address' => \n" +
+				"  \\Address::__set_state(array(\n" +
+				"     'city' => 'Oslo',\n" +
+				"  )),\n" +
+				"   'score' => 2.0,\n" +
+				"))",
+		},
+		"indent": {
+			&phpserialize.VarExportOptions{Indent: "\t"},
+			"\\Person::__set_state(array(\n" +
+				"\t'name' => 'Bob',\n" +
+				"\t'tags' => array(),\n" +
+				"\t'address' => \\Address::__set_state(array(\n" +
+				"\t\t'city' => 'Oslo',\n" +
+				"\t)),\n" +
+				"\t'score' => 2.0,\n" +
+				"))",
+		},
+		"short file": {
+			&phpserialize.VarExportOptions{
+				Indent:           "    ",
+				ShortArraySyntax: true,
+				PHPFile:          true,
+				MarshalOptions:   &phpserialize.MarshalOptions{OnlyStdClass: true},
+			},
+			"<?php\n\nreturn (object) [\n" +
+				"    'name' => 'Bob',\n" +
+				"    'tags' => [],\n" +
+				"    'address' => (object) [\n" +
+				"        'city' => 'Oslo',\n" +
+				"    ],\n" +
+				"    'score' => 2.0,\n" +
+				"];\n",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := phpserialize.MarshalVarExport(value, test.options)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestMarshalVarExportValues(t *testing.T) {
+	o := phpserialize.NewObject("Foo")
+	o.Properties.Set("\x00*\x00prot", 1)
+	o.Properties.Set("\x00Foo\x00priv", 2)
+
+	m := orderedmap.NewOrderedMap[any, any]()
+	m.Set("it's", "a\\b")
+	m.Set(-3, nil)
+
+	tests := map[string]struct {
+		value    interface{}
+		expected string
+	}{
+		"null":       {nil, "NULL"},
+		"bool":       {false, "false"},
+		"int":        {42, "42"},
+		"int min":    {int64(math.MinInt64), "-9223372036854775807-1"},
+		"float":      {1.5, "1.5"},
+		"whole":      {2.0, "2.0"},
+		"exponent":   {1e25, "1.0E+25"},
+		"inf":        {math.Inf(1), "INF"},
+		"nan":        {math.NaN(), "NAN"},
+		"number":     {phpserialize.Number("12"), "12"},
+		"string":     {"a\x00'", `'a' . "\0" . '\''`},
+		"reference":  {&phpserialize.Reference{Value: "x"}, "'x'"},
+		"namespace":  {phpserialize.NewObject(`App\Café_2`), "\\App\\Café_2::__set_state(array(\n))"},
+		"properties": {o, "\\Foo::__set_state(array(\n   'prot' => 1,\n   'priv' => 2,\n))"},
+		"keys":       {m, "array (\n  'it\\'s' => 'a\\\\b',\n  -3 => NULL,\n)"},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			result, err := phpserialize.MarshalVarExport(test.value, nil)
+			expectErrorToNotHaveOccurred(t, err)
+
+			if string(result) != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, result)
+			}
+
+			// The source is read back as the same value.
+			if _, err := phpserialize.ParseVarExport(result, nil); err != nil {
+				t.Errorf("Can not parse %s: %v", result, err)
+			}
+		})
+	}
+}
+
+func TestMarshalVarExportErrors(t *testing.T) {
+	o := phpserialize.NewObject("Node")
+	o.Properties.Set("self", o)
+
+	_, err := phpserialize.MarshalVarExport(o, nil)
+	expectErrorToEqual(t, err, errors.New("can not export PHP object of class Node that contains itself"))
+
+	ref := &phpserialize.Reference{}
+	ref.Value = []interface{}{ref}
+
+	_, err = phpserialize.MarshalVarExport(ref, nil)
+	expectErrorToEqual(t, err, errors.New("can not export PHP array that contains itself"))
+
+	_, err = phpserialize.MarshalVarExport(make(chan int), nil)
+	expectErrorToEqual(t, err, errors.New("can not encode: chan int"))
+
+	// Class names are checked so that they can not inject code.
+	hostile := phpserialize.NewObject("Foo::__set_state(array()); system('id'); \\Bar")
+	_, err = phpserialize.MarshalVarExport(hostile, &phpserialize.VarExportOptions{PHPFile: true})
+	expectErrorToEqual(t, err, errors.New(`can not export invalid PHP class name "Foo::__set_state(array()); system('id'); \\Bar"`))
+
+	for _, name := range []string{"1Foo", `Foo\`, `\Foo`, `Foo\\Bar`, "Foo Bar"} {
+		_, err = phpserialize.MarshalVarExport(phpserialize.NewObject(name), nil)
+		expectErrorToEqual(t, err, fmt.Errorf("can not export invalid PHP class name %q", name))
+	}
+
+	_, err = phpserialize.MarshalVarExport(genericPair[int]{}, nil)
+	expectErrorToEqual(t, err, errors.New(`can not export invalid PHP class name "genericPair[int]"`))
+}
+
+type genericPair[T any] struct {
+	A, B T
+}
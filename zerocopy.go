@@ -0,0 +1,38 @@
+package phpserialize
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// bytesToString returns a string that shares its memory with b. The bytes must
+// not be changed while the string is in use.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	return unsafe.String(&b[0], len(b))
+}
+
+// stringToBytes returns a []byte that shares its memory with s. The bytes must
+// never be changed, because s, or another string that shares its memory, may
+// still be in use.
+func stringToBytes(s string) []byte {
+	if s == "" {
+		return []byte{}
+	}
+
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// decodeString converts the raw bytes of a PHP string into a Go string. With
+// the ZeroCopy option a string that does not need to be unescaped uses the
+// same memory as the data being decoded, rather than a copy of it.
+func (d *decoder) decodeString(raw []byte) string {
+	if d.options.ZeroCopy && bytes.IndexByte(raw, '\\') < 0 {
+		return bytesToString(raw)
+	}
+
+	return DecodePHPString(raw)
+}
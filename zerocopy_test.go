@@ -0,0 +1,97 @@
+package phpserialize_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+	"github.com/jamteacoffee/phpserialize"
+)
+
+type zeroCopyStruct struct {
+	Name    string `php:"name"`
+	Payload []byte `php:"payload"`
+	Escaped string `php:"escaped"`
+}
+
+func TestUnmarshalZeroCopy(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.ZeroCopy = true
+
+	data := []byte(`O:14:"zeroCopyStruct":3:{s:4:"name";s:3:"bob";s:7:"payload";s:3:"abc";s:7:"escaped";s:4:"a\x41";}`)
+
+	var result zeroCopyStruct
+	err := phpserialize.UnmarshalWithOptions(data, &result, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if result.Name != "bob" || string(result.Payload) != "abc" || result.Escaped != "aA" {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	// The values share their memory with the data, so changing the data
+	// changes them too. Strings that had to be unescaped are copies.
+	for i := range data {
+		data[i] = 'z'
+	}
+
+	if result.Name != "zzz" || string(result.Payload) != "zzz" || result.Escaped != "aA" {
+		t.Errorf("Expected the values to share memory with the data, got %+v", result)
+	}
+}
+
+func TestUnmarshalZeroCopyTopLevel(t *testing.T) {
+	options := phpserialize.DefaultUnmarshalOptions()
+	options.ZeroCopy = true
+
+	data := []byte(`s:5:"hello";`)
+
+	var s string
+	err := phpserialize.UnmarshalWithOptions(data, &s, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	var b []byte
+	err = phpserialize.UnmarshalWithOptions(data, &b, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	data[6] = 'E'
+
+	if s != "hEllo" || string(b) != "hEllo" {
+		t.Errorf("Expected hEllo, got %q and %q", s, b)
+	}
+
+	var m *orderedmap.OrderedMap[any, any]
+	err = phpserialize.UnmarshalWithOptions([]byte(`a:1:{s:1:"k";s:0:"";}`), &m, options)
+	expectErrorToNotHaveOccurred(t, err)
+
+	if v, _ := m.Get("k"); v != "" {
+		t.Errorf("Expected an empty string, got %q", v)
+	}
+}
+
+func TestUnmarshalZeroCopyAllocations(t *testing.T) {
+	data, err := phpserialize.Marshal(benchmarkUserValue, nil)
+	expectErrorToNotHaveOccurred(t, err)
+
+	allocations := func(options *phpserialize.UnmarshalOptions) float64 {
+		return testing.AllocsPerRun(100, func() {
+			var result benchmarkUser
+			if err := phpserialize.UnmarshalWithOptions(data, &result, options); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	options := phpserialize.DefaultUnmarshalOptions()
+	copied := allocations(options)
+
+	options.ZeroCopy = true
+	if zeroCopy := allocations(options); zeroCopy >= copied {
+		t.Errorf("Expected fewer than %v allocations, got %v", copied, zeroCopy)
+	}
+}
+
+func TestUnmarshalStringTooLong(t *testing.T) {
+	var s string
+	err := phpserialize.Unmarshal([]byte(`s:10:"abc";`), &s)
+	expectErrorToEqual(t, err, errors.New("can not decode string with length 10"))
+}